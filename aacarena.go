@@ -0,0 +1,288 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"github.com/Comcast/gaad/bitreader"
+)
+
+// An arena hands out the values and slices of one type from a slab that is
+// kept from frame to frame.  The slab grows to what the largest frame so
+// far needed, so a stream of similar frames stops allocating after the
+// first.  Everything handed out is invalid once the arena is reset.
+type arena[T any] struct {
+	slab []T
+	used int // values of the slab handed out
+	need int // values handed out since the reset, across slabs
+}
+
+// A zeroed slice of length n with room for capacity values
+func (a *arena[T]) make_cap(n, capacity int) []T {
+	if a.slab == nil || a.used+capacity > len(a.slab) {
+		// The old slab stays with what was handed out from it
+		size := 2 * len(a.slab)
+		if size < capacity {
+			size = capacity
+		}
+		if size < 4 {
+			size = 4
+		}
+		a.slab = make([]T, size)
+		a.used = 0
+	}
+	s := a.slab[a.used : a.used+n : a.used+capacity]
+	var zero T
+	for i := range s {
+		s[i] = zero
+	}
+	a.used += capacity
+	a.need += capacity
+	return s
+}
+
+// A zeroed slice of length n, like make([]T, n)
+func (a *arena[T]) make(n int) []T {
+	return a.make_cap(n, n)
+}
+
+// A pointer to a zeroed value, like new(T)
+func (a *arena[T]) new() *T {
+	return &a.make_cap(1, 1)[0]
+}
+
+// Append to a slice of the arena like the builtin append, growing it in
+// the arena when it's full.  The slice handed out last grows in place while
+// the slab has room.
+func (a *arena[T]) append(s []T, v ...T) []T {
+	if len(s)+len(v) <= cap(s) {
+		return append(s, v...)
+	}
+	if len(v) == 0 {
+		return s
+	}
+	if c, extra := cap(s), len(s)+len(v)-cap(s); c > 0 && c <= a.used && a.used+extra <= len(a.slab) &&
+		&s[:c][c-1] == &a.slab[a.used-1] {
+		start := a.used - c
+		a.used += extra
+		a.need += extra
+		return append(a.slab[start:start+len(s):a.used], v...)
+	}
+	grown := a.make_cap(len(s), 2*len(s)+len(v))
+	copy(grown, s)
+	return append(grown, v...)
+}
+
+// Take back everything handed out, the slab is replaced by one that fits
+// all of it if it didn't
+func (a *arena[T]) reset() {
+	if a.need > len(a.slab) {
+		a.slab = make([]T, a.need)
+	}
+	a.used = 0
+	a.need = 0
+}
+
+// The memory an ADTS parses its frames into.  There is an arena for each
+// type of element and slice the parser makes.
+type frame_memory struct {
+	// The reader of the frame in memory and the ones of its elements
+	input           bitreader.BitReader
+	readers         arena[bitreader.BitReader]
+	limited_readers arena[bitreader.LimitedReader]
+
+	adts_error_checks                arena[adts_error_check]
+	adts_header_error_checks         arena[adts_header_error_check]
+	adts_raw_data_block_error_checks arena[adts_raw_data_block_error_check]
+	raw_data_blocks                  arena[raw_data_block]
+	single_channel_elements          arena[single_channel_element]
+	channel_pair_elements            arena[channel_pair_element]
+	coupling_channel_elements        arena[coupling_channel_element]
+	lfe_channel_elements             arena[lfe_channel_element]
+	data_stream_elements             arena[data_stream_element]
+	program_config_elements          arena[program_config_element]
+	fill_elements                    arena[fill_element]
+	end_elements                     arena[end_element]
+	ics_infos                        arena[ics_info]
+	individual_channel_streams       arena[individual_channel_stream]
+	pulse_datas                      arena[pulse_data]
+	gain_control_datas               arena[gain_control_data]
+	section_datas                    arena[section_data]
+	scale_factor_datas               arena[scale_factor_data]
+	tns_datas                        arena[tns_data]
+	ltp_datas                        arena[ltp_data]
+	spectral_datas                   arena[spectral_data]
+	extension_payloads               arena[extension_payload]
+	dynamic_range_infos              arena[dynamic_range_info]
+	excluded_channels                arena[excluded_channels]
+	sac_extension_datas              arena[sac_extension_data]
+	sbr_extension_datas              arena[sbr_extension_data]
+	sbr_headers                      arena[sbr_header]
+	sbr_datas                        arena[sbr_data]
+	sbr_single_channel_elements      arena[sbr_single_channel_element]
+	sbr_channel_pair_elements        arena[sbr_channel_pair_element]
+	sbr_channel_pair_base_elements   arena[sbr_channel_pair_base_element]
+	sbr_grids                        arena[sbr_grid]
+	sbr_dtdfs                        arena[sbr_dtdf]
+	sbr_invfs                        arena[sbr_invf]
+	sbr_envelopes                    arena[sbr_envelope]
+	sbr_noises                       arena[sbr_noise]
+	sbr_sinusoidal_codings           arena[sbr_sinusoidal_coding]
+	sbr_extensions                   arena[sbr_extension]
+	reordered_spectral_datas         arena[reordered_spectral_data]
+	rvlc_bands                       arena[rvlc_band]
+	hcr_codewords                    arena[hcr_codeword]
+
+	// The element lists of the ADTS and its raw_data_blocks
+	elements                      arena[Element]
+	raw_data_block_list           arena[*raw_data_block]
+	single_channel_element_list   arena[*single_channel_element]
+	channel_pair_element_list     arena[*channel_pair_element]
+	coupling_channel_element_list arena[*coupling_channel_element]
+	lfe_channel_element_list      arena[*lfe_channel_element]
+	data_stream_element_list      arena[*data_stream_element]
+	program_config_element_list   arena[*program_config_element]
+	fill_element_list             arena[*fill_element]
+	sbr_extension_list            arena[*sbr_extension]
+	hcr_codeword_list             arena[*hcr_codeword]
+
+	bools      arena[bool]
+	int8s      arena[int8]
+	uint8s     arena[uint8]
+	uint16s    arena[uint16]
+	int16s     arena[int16]
+	ints       arena[int]
+	uints      arena[uint]
+	bools_2d   arena[[]bool]
+	uint8s_2d  arena[[]uint8]
+	uint16s_2d arena[[]uint16]
+	int16s_2d  arena[[]int16]
+	ints_2d    arena[[]int]
+	uint8s_3d  arena[[][]uint8]
+	ints_3d    arena[[][]int]
+}
+
+func (mem *frame_memory) reset() {
+	mem.readers.reset()
+	mem.limited_readers.reset()
+
+	mem.adts_error_checks.reset()
+	mem.adts_header_error_checks.reset()
+	mem.adts_raw_data_block_error_checks.reset()
+	mem.raw_data_blocks.reset()
+	mem.single_channel_elements.reset()
+	mem.channel_pair_elements.reset()
+	mem.coupling_channel_elements.reset()
+	mem.lfe_channel_elements.reset()
+	mem.data_stream_elements.reset()
+	mem.program_config_elements.reset()
+	mem.fill_elements.reset()
+	mem.end_elements.reset()
+	mem.ics_infos.reset()
+	mem.individual_channel_streams.reset()
+	mem.pulse_datas.reset()
+	mem.gain_control_datas.reset()
+	mem.section_datas.reset()
+	mem.scale_factor_datas.reset()
+	mem.tns_datas.reset()
+	mem.ltp_datas.reset()
+	mem.spectral_datas.reset()
+	mem.extension_payloads.reset()
+	mem.dynamic_range_infos.reset()
+	mem.excluded_channels.reset()
+	mem.sac_extension_datas.reset()
+	mem.sbr_extension_datas.reset()
+	mem.sbr_headers.reset()
+	mem.sbr_datas.reset()
+	mem.sbr_single_channel_elements.reset()
+	mem.sbr_channel_pair_elements.reset()
+	mem.sbr_channel_pair_base_elements.reset()
+	mem.sbr_grids.reset()
+	mem.sbr_dtdfs.reset()
+	mem.sbr_invfs.reset()
+	mem.sbr_envelopes.reset()
+	mem.sbr_noises.reset()
+	mem.sbr_sinusoidal_codings.reset()
+	mem.sbr_extensions.reset()
+	mem.reordered_spectral_datas.reset()
+	mem.rvlc_bands.reset()
+	mem.hcr_codewords.reset()
+
+	mem.elements.reset()
+	mem.raw_data_block_list.reset()
+	mem.single_channel_element_list.reset()
+	mem.channel_pair_element_list.reset()
+	mem.coupling_channel_element_list.reset()
+	mem.lfe_channel_element_list.reset()
+	mem.data_stream_element_list.reset()
+	mem.program_config_element_list.reset()
+	mem.fill_element_list.reset()
+	mem.sbr_extension_list.reset()
+	mem.hcr_codeword_list.reset()
+
+	mem.bools.reset()
+	mem.int8s.reset()
+	mem.uint8s.reset()
+	mem.uint16s.reset()
+	mem.int16s.reset()
+	mem.ints.reset()
+	mem.uints.reset()
+	mem.bools_2d.reset()
+	mem.uint8s_2d.reset()
+	mem.uint16s_2d.reset()
+	mem.int16s_2d.reset()
+	mem.ints_2d.reset()
+	mem.uint8s_3d.reset()
+	mem.ints_3d.reset()
+}
+
+// Return n bits like ReadBitsToByteArray, in the memory of the frame
+func (adts *ADTS) read_bits_to_byte_array(n uint) ([]byte, error) {
+	var dst []byte
+	if n <= adts.reader.BitsLeft() {
+		dst = adts.mem.uint8s.make_cap(0, int((n+7)/8))
+	}
+	data, err := adts.reader.AppendBitsToByteArray(dst, n)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Make the ADTS ready to parse the frame in buf with the options it has,
+// reusing the elements and slices of the frames it parsed before.  Those
+// frames are invalid after the reset, copy what is needed of them first.
+func (adts *ADTS) Reset(buf []byte) {
+	adts.reset()
+	adts.mem.input = *bitreader.NewBitReader(buf)
+	adts.reader = &adts.mem.input
+}
+
+// Take back the frames before and their memory, keeping the options
+func (adts *ADTS) reset() {
+	*adts = ADTS{options: adts.options, mem: adts.mem}
+	adts.mem.reset()
+}
+
+// Parses the ADTS frame in buf into adts like ParseADTSWithOptions does
+// with the options of adts, a zero ADTS parses like ParseADTS.  Once the
+// memory of the frames before is big enough for the frames of a stream,
+// parsing them doesn't allocate.  The frame before is invalid after the
+// call.
+func (adts *ADTS) ParseInto(buf []byte) error {
+	adts.Reset(buf)
+	return adts.adts_frame()
+}
@@ -0,0 +1,291 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"encoding/base64"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// HE-AAC frames of the parser tests: SBR with a single_channel_element and
+// with a channel_pair_element, an EIGHT_SHORT_SEQUENCE and the
+// LONG_STOP_SEQUENCE following it.  decodeReuseFrames adds an ER AAC LC
+// raw_data_block with RVLC scale factors and reordered spectral data.
+var reuseFrames = []struct {
+	name  string
+	frame string
+}{
+	{"sce", "//lYsED//CEblJXelQhhoI7AOFHFc2cMsXe1xEy5V4Brm15v1sz4eh7l7KRzWGdAkVA2OrmHG7LgFU0bV955cbSq3cp2WaD3C6ffadAnTyO0LAp0eHtwGg+300aUm66XufR7VGTEoqJ5sZeCebnxe5OBC1RyIkjiTBsxfxmvQ+Pw07tWjs8/DRtAqLDkM31bHKwkz3XI4eYtj9QzqmKEVyCrRm0keaDTOBlxk0A+Li2lkrjBWyvwnGH2/8xm+DSPD3itzYvkJk8q1UkEpWfqSq+SfDtYuykL63NmMahaF9hsgrTsBOqhQcBQ1xbOlVJUpetZZUVLmRYCcCVxVVEqRcBIJgggLQErUzSqDmUJQ5SUZaoYLQB2ZFKun714ZTLTCoCECqL7Lw3Iz3ybsZMtpiG+RCrAs5DacKMpmNImR9MyprUFyhLKOZZEJzTBll39USy0y2nR1keZfnZGXeB2IGMlnGm+6kKJluBqAek2ov8kqUSRqnhOUhn5djRiGQKMubUzrSEzBprrSR/5/oQNqYUB3EBrjXU9RS0DJ7OwaKERWjsxysubUGKdAH3jeEb9iJ4EIIokxrt/vwAmK9z7/gB9H0AG8yAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAP"},
+	{"cpe", "//FYgD+BnCEbQ9uJooYaHEyKrWaAZUmYoqZdSkJUqh/ZoD0ZT3iVbMTgNe8FuI6HSt1vZoAcY13PUkjIqz2PXN82feqhznnAoQwAnAYzxDC3VbYX7x1oyZMJJDh0nozSuFKVaJUjeJVUi01puKLL3LdgXpQyDr0hZa9PAvAtQvxBLVNTXGa6FRWh2VU1r+fA3WhRZP1ChIYijIlCvUJgErbYRTWCgmIghE9cuudbSXxrNF5VNQ3LbkkuRBA6hl73rHJa8C2vUAhslyiC8nspS800OvMc3YzrTHnNlrcYMCXC/Bwha6I5KDVXi3O5UQaDWyinMlymVxOeNUp3FbdbWC4+KfCKGNPbI0aNSYxoo1b0xpUqoWRTZ1p8UU5gqnHFRzrmnThZGNyD8MZa7Y/NYOdthRkyZyD+S1nc0HDm2WoPc7O23AWWNkcLa/kTFekvbxFTk6DcJjxM3IQeqK2gKo5NynkRqIKyFspol79egkg3O8DIf68aWV/C4qjg0r5ODFsMHZQfTQI7ukK2Fh33vrHrN2OlhayZAQJp0gE2DCaInTHGgCpmmag8WmNqWBDZmRmZgmxZobHrmRPKCRtiEkAX8pJblEvdB61vNxNE3WJlvefJV7Y+FLJAXUA3gG8N37ATwNsYoCIAAAAAE/fwe/879/u5fmSNCBAA8w=="},
+	{"short", "//FYQC3AOAE2v+oTcrBNds4KmC34SXcuXJI78bpLJJjK3ElySygIWU7DiWttnUA6dGYOwhq8LCU2rjXxxe7LpiAHOb1NzwOwQog5lWi+c5WtG8WtmBaKhodeTPZfIWe9L1wVLMlnU7pUuNlysIHrnYyC3FsZxBb5gCkQMOi1UcJ/t32juqK2nUZli+uLL/P6MRvfVytWTOMZ3SJUwp0Ii+nw0L55xE1WcRERKKsxc6Qj2zz3JTdzZlYKKCgDSAAABBfRe173cWSt4FgGRuFKBAziNCf626dvrMZIgDLJkz0QDm1JwdWdZtrRdgr6q6c5IpGM7qekCaMx2SUf1hxKfX2p8P63evN0A+E1M4AUgExBq5UxcZP/WDjafvjZeem1R3Y9ke3uzwaGqQZDSma22qFYJApP73OsIBIjk6WU3Y7CBgITOe+hpkOaZ65KnAd4foEAJK9+3PsOPgdmRZfLySssSyVX+L1emNzzYAAH"},
+	{"stop", "//FYQCuAIAFO16CNdDsFCMVBsFBEgkRcSSEhEJIkACz6gkmNiYhmTsJzYTVy+WWjfODzgFMqE5tYKBkRkBgohZURlF7zzYdV1k602q7mAJCIVAKgnCnbeW7EaNkmwh2bqaEwWQDKZF/pnO+B4b5Po3lYegtuVHbWQHDZcPZnxE0m/Y6Iq3OrmJbR26tl/YJGgwoTMwDrLXjWufzZkYCkUi86I0bJWfMbbVe2hZjDlYyI5EoFtfLZ43d+Jb7t11DzIfOweMuNF8AGUJbMCo6vKAqDpBzTMlQySBUUApgYVHaqaabVmkHKy4FIrAiejnUl7le8Lakm7fp7rXqAhRjY3EUBL+wE7OgWVQapk2qiNr44RsLWOXVDQXkqr9TXQUdp9G58x7AHmcg8FJhhbMiC4cq55d1WL1ob3iegwAK+R9/jftT8bT/yXDhx8AsyLZUNwQssO3Xut7LNYAHA"},
+}
+
+type reuseFrame struct {
+	name string
+	buf  []byte
+
+	// Of a raw_data_block without ADTS header, nil for ADTS frames
+	config *AudioSpecificConfig
+}
+
+// Parses the frame like ParseADTS or ParseRawDataBlock
+func (f reuseFrame) parse() (*ADTS, error) {
+	if f.config != nil {
+		return ParseRawDataBlock(f.config, f.buf)
+	}
+	return ParseADTS(f.buf)
+}
+
+// Parses the frame into adts like ParseInto or ParseRawDataBlockInto
+func (f reuseFrame) parseInto(adts *ADTS) error {
+	if f.config != nil {
+		return adts.ParseRawDataBlockInto(f.config, f.buf)
+	}
+	return adts.ParseInto(f.buf)
+}
+
+func decodeReuseFrames(tb testing.TB) []reuseFrame {
+	var frames []reuseFrame
+	for _, test := range reuseFrames {
+		buf, err := base64.StdEncoding.DecodeString(test.frame)
+		if err != nil {
+			tb.Fatalf("DecodeString: %s", err)
+		}
+		frames = append(frames, reuseFrame{name: test.name, buf: buf})
+	}
+	config, buf := erReuseFrame(tb)
+	return append(frames, reuseFrame{name: "er", buf: buf, config: config})
+}
+
+// A single_channel_element of ER AAC LC with all error resilience tools: a
+// long window with an escape, a virtual codebook 11, 5 quad and a noise
+// band, RVLC scale factors with an escape and HCR spectral data
+func erReuseFrame(tb testing.TB) (*AudioSpecificConfig, []byte) {
+	config := &AudioSpecificConfig{
+		Audio_object_type:        AUDIO_OBJECT_TYPE_ER,
+		Sampling_frequency_index: 3,
+		Sampling_frequency:       48000,
+		Channel_configuration:    1,
+		Ga_specific_config: &ga_specific_config{
+			Extension_flag:                       true,
+			Aac_section_data_resilience_flag:     true,
+			Aac_scalefactor_data_resilience_flag: true,
+			Aac_spectral_data_resilience_flag:    true,
+		},
+	}
+
+	sfb_cb := []uint8{ESC_HCB, FIRST_VCB11, 1, 1, 1, 1, 1, NOISE_HCB}
+	info := &ics_info{Window_sequence: ONLY_LONG_SEQUENCE, Max_sfb: uint8(len(sfb_cb))}
+	window_grouping(new(frame_memory), info, 3, 1024)
+	info.sfb_cb = [][]uint8{sfb_cb}
+	sec_data := &section_data{
+		Sect_cb:    [][]uint8{{ESC_HCB, FIRST_VCB11, 1, NOISE_HCB}},
+		sect_start: [][]uint8{{0, 1, 2, 7}},
+		sect_end:   [][]uint16{{1, 2, 7, 8}},
+		num_sec:    []uint8{4},
+	}
+
+	// Random codewords for every tuple
+	rng := rand.New(rand.NewSource(1))
+	codewords := hcr_sort(new(frame_memory), info, sec_data)
+	code := map[*hcr_codeword][]byte{}
+	longest := uint8(0)
+	for _, cw := range codewords {
+		bits, _ := hcrTestCodeword(rng, cw.sect_cb)
+		code[cw] = bits
+		if uint8(len(bits)) > longest {
+			longest = uint8(len(bits))
+		}
+	}
+	bits := hcrEncode(tb, codewords, code, longest)
+	if len(codewords) < 2*len(bits)/int(longest) {
+		tb.Fatalf("%d codewords in %d bits don't leave non priority codewords", len(codewords), len(bits))
+	}
+
+	// The noise band's energy is PCM, it doesn't take a difference
+	diffs := []int{3, 17, -2, 1, 0, -1, 2}
+	seg := &bitWriter{}
+	esc := &bitWriter{}
+	writeRvlc(seg, esc, diffs)
+
+	w := &bitWriter{}
+	w.write(0, 4)   // element_instance_tag
+	w.write(100, 8) // global_gain
+	w.write(0, 1)   // ics_reserved_bit
+	w.write(ONLY_LONG_SEQUENCE, 2)
+	w.write(0, 1) // window_shape
+	w.write(uint64(info.Max_sfb), 6)
+	w.write(0, 1) // predictor_data_present
+
+	// section_data, codebooks 11 and 16 have no length
+	w.write(ESC_HCB, 5)
+	w.write(FIRST_VCB11, 5)
+	w.write(1, 5)
+	w.write(5, 5)
+	w.write(NOISE_HCB, 5)
+	w.write(1, 5)
+
+	// scale_factor_data
+	w.write(0, 1)                  // sf_concealment
+	w.write(120, 8)                // rev_global_gain
+	w.write(uint64(seg.bits+9), 9) // length_of_rvlc_sf
+	w.write(300, 9)                // first noise energy
+	w.write(1, 1)                  // sf_escapes_present
+	w.write(uint64(esc.bits), 8)   // length_of_rvlc_escapes
+	w.write(280, 9)                // dpcm_noise_last_position
+
+	w.write(0, 1) // pulse_data_present
+	w.write(0, 1) // tns_data_present
+	w.write(0, 1) // gain_control_data_present
+	w.write(uint64(len(bits)), 14)
+	w.write(uint64(longest), 6)
+	for _, v := range []*bitWriter{seg, esc} {
+		for i := uint(0); i < v.bits; i++ {
+			w.write(uint64(v.buf[i/8]>>(7-i%8))&1, 1)
+		}
+	}
+	for _, b := range bits {
+		w.write(uint64(b), 1)
+	}
+	w.byteAlign()
+	return config, w.buf
+}
+
+// The parsed frame without the memory it was parsed into
+func parsedFrame(adts *ADTS) ADTS {
+	frame := *adts
+	frame.reader = nil
+	frame.mem = frame_memory{}
+	return frame
+}
+
+func TestParseInto(t *testing.T) {
+	frames := decodeReuseFrames(t)
+
+	// Truncated frames in between, then each frame after every other
+	er := frames[len(frames)-1]
+	frames = append(frames,
+		reuseFrame{name: "truncated", buf: frames[1].buf[:200]},
+		reuseFrame{name: "er truncated", buf: er.buf[:len(er.buf)/2], config: er.config})
+	var order []reuseFrame
+	for i := range frames {
+		for j := range frames {
+			order = append(order, frames[i], frames[j])
+		}
+	}
+
+	adts := &ADTS{}
+	for i, f := range order {
+		want, wantErr := f.parse()
+		err := f.parseInto(adts)
+		if (err == nil) != (wantErr == nil) || err != nil && err.Error() != wantErr.Error() {
+			t.Fatalf("frame %d (%s): parsed into with %v, %v when parsed anew", i, f.name, err, wantErr)
+		}
+		if !reflect.DeepEqual(parsedFrame(adts), parsedFrame(want)) {
+			t.Fatalf("frame %d (%s): parsed into differs from parsed anew", i, f.name)
+		}
+	}
+}
+
+func TestErReuseFrame(t *testing.T) {
+	config, buf := erReuseFrame(t)
+	adts, err := ParseRawDataBlock(config, buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+
+	// The escape, virtual codebook 11 and quad bands as HCR tuples
+	s := adts.Single_channel_elements[0].Channel_stream
+	if s.Reordered_spectral_data == nil || len(s.Spectral_data.Hcod) != 2+2+5 {
+		t.Errorf("reordered_spectral_data must hold %d tuples", 2+2+5)
+	}
+	data := s.Scale_factor_data
+	for sfb, d := range []int{3, 17, -2, 1, 0, -1, 2} {
+		if got := int(data.Dcpm_sf[0][sfb]) - RVLC_DPCM_OFFSET; got != d {
+			t.Errorf("band %d difference (%d) must be %d", sfb, got, d)
+		}
+	}
+	if data.Dcpm_noise_nrg[0][7] != 300 || data.Rvlc_concealed {
+		t.Errorf("noise energy (%d) must be 300 without concealment", data.Dcpm_noise_nrg[0][7])
+	}
+}
+
+func TestParseIntoOptions(t *testing.T) {
+	frames := decodeReuseFrames(t)
+
+	// The options of the ADTS are kept
+	adts, err := ParseADTSWithOptions(frames[0].buf, Options{SkipSpectralData: true})
+	if err != nil {
+		t.Fatalf("ParseADTSWithOptions() returned %v", err)
+	}
+	if err = adts.ParseInto(frames[1].buf); err != nil {
+		t.Fatalf("ParseInto() returned %v", err)
+	}
+	if adts.Channel_pair_elements[0].Channel_stream1.Spectral_data != nil {
+		t.Errorf("Spectral_data must be nil, SkipSpectralData must be kept")
+	}
+}
+
+func TestParseIntoAllocs(t *testing.T) {
+	frames := decodeReuseFrames(t)
+	adts := &ADTS{}
+	for _, f := range frames {
+		f.parseInto(adts)
+	}
+
+	// Once the memory fits every frame they're parsed without allocating
+	allocs := testing.AllocsPerRun(10, func() {
+		for _, f := range frames {
+			if err := f.parseInto(adts); err != nil {
+				t.Fatalf("%s: ParseInto() returned %v", f.name, err)
+			}
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("ParseInto() made %v allocations, want 0", allocs)
+	}
+}
+
+func BenchmarkParseADTS(b *testing.B) {
+	for _, f := range decodeReuseFrames(b) {
+		f := f
+		b.Run(f.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(f.buf)))
+			for n := 0; n < b.N; n++ {
+				f.parse()
+			}
+		})
+	}
+}
+
+func BenchmarkParseInto(b *testing.B) {
+	for _, f := range decodeReuseFrames(b) {
+		f := f
+		b.Run(f.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(f.buf)))
+			adts := &ADTS{}
+			for n := 0; n < b.N; n++ {
+				f.parseInto(adts)
+			}
+		})
+	}
+}
@@ -0,0 +1,328 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import "fmt"
+
+// Speaker position of a decoded channel
+type ChannelLabel uint8
+
+const (
+	CHANNEL_UNKNOWN ChannelLabel = iota
+	CHANNEL_FL                   // front left
+	CHANNEL_FR                   // front right
+	CHANNEL_FC                   // front center
+	CHANNEL_LFE                  // low frequency effects
+	CHANNEL_BL                   // back left
+	CHANNEL_BR                   // back right
+	CHANNEL_FLC                  // front left of center
+	CHANNEL_FRC                  // front right of center
+	CHANNEL_BC                   // back center
+	CHANNEL_SL                   // side left
+	CHANNEL_SR                   // side right
+	CHANNEL_TC                   // top center
+	CHANNEL_TFL                  // top front left
+	CHANNEL_TFC                  // top front center
+	CHANNEL_TFR                  // top front right
+	CHANNEL_TBL                  // top back left
+	CHANNEL_TBC                  // top back center
+	CHANNEL_TBR                  // top back right
+	CHANNEL_LFE2                 // second low frequency effects
+	CHANNEL_TSL                  // top side left
+	CHANNEL_TSR                  // top side right
+	CHANNEL_BFC                  // bottom front center
+	CHANNEL_BFL                  // bottom front left
+	CHANNEL_BFR                  // bottom front right
+)
+
+var channelLabelNames = [...]string{
+	"?", "FL", "FR", "FC", "LFE", "BL", "BR", "FLC", "FRC", "BC", "SL", "SR",
+	"TC", "TFL", "TFC", "TFR", "TBL", "TBC", "TBR", "LFE2", "TSL", "TSR",
+	"BFC", "BFL", "BFR",
+}
+
+// WAVEFORMATEXTENSIBLE dwChannelMask bit for each label.  Positions without a
+// WAV speaker bit map to 0.
+var channelLabelMasks = [...]uint32{
+	0, 0x1, 0x2, 0x4, 0x8, 0x10, 0x20, 0x40, 0x80, 0x100, 0x200, 0x400,
+	0x800, 0x1000, 0x2000, 0x4000, 0x8000, 0x10000, 0x20000, 0, 0, 0,
+	0, 0, 0,
+}
+
+func (l ChannelLabel) String() string {
+	if int(l) < len(channelLabelNames) {
+		return channelLabelNames[l]
+	}
+	return channelLabelNames[CHANNEL_UNKNOWN]
+}
+
+// WAV channel mask bit for the speaker position
+func (l ChannelLabel) Mask() uint32 {
+	if int(l) < len(channelLabelMasks) {
+		return channelLabelMasks[l]
+	}
+	return 0
+}
+
+// WAV dwChannelMask for a channel layout
+func ChannelMask(labels []ChannelLabel) uint32 {
+	mask := uint32(0)
+	for _, l := range labels {
+		mask |= l.Mask()
+	}
+	return mask
+}
+
+// A channel element of a layout and the speakers it feeds
+type layout_element struct {
+	Id_syn_ele uint8
+	Tag        uint8
+	Labels     []ChannelLabel
+}
+
+// A decoded channel and the element it was decoded from
+type channel_position struct {
+	Label      ChannelLabel
+	Id_syn_ele uint8
+	Tag        uint8
+}
+
+func sce(l ChannelLabel) layout_element { return layout_element{ID_SCE, 0, []ChannelLabel{l}} }
+func cpe(l, r ChannelLabel) layout_element {
+	return layout_element{ID_CPE, 0, []ChannelLabel{l, r}}
+}
+func lfe(l ChannelLabel) layout_element { return layout_element{ID_LFE, 0, []ChannelLabel{l}} }
+
+////////////////////////////////////////////////////////////////////////////////
+// Table 1.19 – Channel Configuration, element order of each configuration
+////////////////////////////////////////////////////////////////////////////////
+var channelConfigurationElements = [][]layout_element{
+	nil,
+	{sce(CHANNEL_FC)},
+	{cpe(CHANNEL_FL, CHANNEL_FR)},
+	{sce(CHANNEL_FC), cpe(CHANNEL_FL, CHANNEL_FR)},
+	{sce(CHANNEL_FC), cpe(CHANNEL_FL, CHANNEL_FR), sce(CHANNEL_BC)},
+	{sce(CHANNEL_FC), cpe(CHANNEL_FL, CHANNEL_FR), cpe(CHANNEL_BL, CHANNEL_BR)},
+	{sce(CHANNEL_FC), cpe(CHANNEL_FL, CHANNEL_FR), cpe(CHANNEL_BL, CHANNEL_BR), lfe(CHANNEL_LFE)},
+	{
+		sce(CHANNEL_FC), cpe(CHANNEL_FLC, CHANNEL_FRC), cpe(CHANNEL_FL, CHANNEL_FR),
+		cpe(CHANNEL_BL, CHANNEL_BR), lfe(CHANNEL_LFE),
+	},
+	nil, nil, nil,
+	{
+		sce(CHANNEL_FC), cpe(CHANNEL_FL, CHANNEL_FR), cpe(CHANNEL_SL, CHANNEL_SR),
+		sce(CHANNEL_BC), lfe(CHANNEL_LFE),
+	},
+	{
+		sce(CHANNEL_FC), cpe(CHANNEL_FL, CHANNEL_FR), cpe(CHANNEL_SL, CHANNEL_SR),
+		cpe(CHANNEL_BL, CHANNEL_BR), lfe(CHANNEL_LFE),
+	},
+	{
+		sce(CHANNEL_FC), cpe(CHANNEL_FLC, CHANNEL_FRC), cpe(CHANNEL_FL, CHANNEL_FR),
+		cpe(CHANNEL_SL, CHANNEL_SR), cpe(CHANNEL_BL, CHANNEL_BR), sce(CHANNEL_BC),
+		lfe(CHANNEL_LFE), lfe(CHANNEL_LFE2), sce(CHANNEL_TFC), cpe(CHANNEL_TFL, CHANNEL_TFR),
+		cpe(CHANNEL_TSL, CHANNEL_TSR), sce(CHANNEL_TC), cpe(CHANNEL_TBL, CHANNEL_TBR),
+		sce(CHANNEL_TBC), sce(CHANNEL_BFC), cpe(CHANNEL_BFL, CHANNEL_BFR),
+	},
+	{
+		sce(CHANNEL_FC), cpe(CHANNEL_FL, CHANNEL_FR), cpe(CHANNEL_BL, CHANNEL_BR),
+		lfe(CHANNEL_LFE), cpe(CHANNEL_TFL, CHANNEL_TFR),
+	},
+	nil,
+}
+
+// Speaker positions of every channel decoded from the frame, in decode
+// order.  ChannelConfiguration 1-7 and 11-14 follow Table 1.19, a
+// ChannelConfiguration of 0 is resolved from the frame's
+// program_config_element.
+func (adts *ADTS) ChannelLayout() ([]ChannelLabel, error) {
+	positions, err := adts.channel_positions()
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]ChannelLabel, len(positions))
+	for i := range positions {
+		labels[i] = positions[i].Label
+	}
+	return labels, nil
+}
+
+func (adts *ADTS) channel_positions() ([]channel_position, error) {
+	return adts.block_channel_positions(0)
+}
+
+// Channels of the given raw_data_block
+func (adts *ADTS) block_channel_positions(block int) ([]channel_position, error) {
+	var elements []layout_element
+	match_tag := false
+	if adts.ChannelConfiguration == 0 {
+		if len(adts.Program_config_elements) == 0 {
+			return nil, fmt.Errorf("Error: ChannelConfiguration 0 requires a program_config_element")
+		}
+		elements = adts.Program_config_elements[0].layout()
+		match_tag = true
+	} else if int(adts.ChannelConfiguration) < len(channelConfigurationElements) {
+		elements = channelConfigurationElements[adts.ChannelConfiguration]
+	}
+	if elements == nil {
+		return nil, fmt.Errorf("Error: ChannelConfiguration (%d) is reserved", adts.ChannelConfiguration)
+	}
+
+	// Without parsed elements the layout is reported in its canonical order
+	if block >= len(adts.Raw_data_blocks) {
+		return layout_channels(elements, nil, false), nil
+	}
+	return layout_channels(elements, adts.Raw_data_blocks[block].Elements, match_tag), nil
+}
+
+// The individual_channel_stream of every channel an element decodes to
+func channel_streams(e Element) []*individual_channel_stream {
+	switch e := e.(type) {
+	case *single_channel_element:
+		return []*individual_channel_stream{e.Channel_stream}
+	case *channel_pair_element:
+		return []*individual_channel_stream{e.Channel_stream1, e.Channel_stream2}
+	case *lfe_channel_element:
+		return []*individual_channel_stream{e.Channel_stream}
+	}
+	return nil
+}
+
+// Maps the channel elements of a raw_data_block onto a layout.  A PCE
+// addresses elements by instance tag, the fixed channel configurations
+// address the n-th element of each type.
+func layout_channels(layout []layout_element, decoded []Element, match_tag bool) []channel_position {
+	var positions []channel_position
+	if decoded == nil {
+		for _, le := range layout {
+			for _, l := range le.Labels {
+				positions = append(positions, channel_position{l, le.Id_syn_ele, le.Tag})
+			}
+		}
+		return positions
+	}
+
+	used := make([]bool, len(layout))
+	for _, e := range decoded {
+		var tag uint8
+		var num_channels int
+		switch e := e.(type) {
+		case *single_channel_element:
+			tag, num_channels = e.Element_instance_tag, 1
+		case *channel_pair_element:
+			tag, num_channels = e.Element_instance_tag, 2
+		case *lfe_channel_element:
+			tag, num_channels = e.Element_instance_tag, 1
+		default:
+			continue
+		}
+
+		labels := make([]ChannelLabel, num_channels)
+		for i, le := range layout {
+			if used[i] || le.Id_syn_ele != e.ID() || (match_tag && le.Tag != tag) {
+				continue
+			}
+			used[i] = true
+			copy(labels, le.Labels)
+			break
+		}
+		for _, l := range labels {
+			positions = append(positions, channel_position{l, e.ID(), tag})
+		}
+	}
+	return positions
+}
+
+// Derives the speaker positions of a program_config_element from its front,
+// side, back and LFE element lists
+func (pce *program_config_element) layout() []layout_element {
+	var layout []layout_element
+
+	num_front_pairs := 0
+	for _, is_cpe := range pce.Front_element_is_cpe {
+		if is_cpe {
+			num_front_pairs++
+		}
+	}
+	// With more than one front pair the first is the inner (center) pair
+	front_pairs := [][2]ChannelLabel{{CHANNEL_FL, CHANNEL_FR}}
+	if num_front_pairs > 1 {
+		front_pairs = [][2]ChannelLabel{{CHANNEL_FLC, CHANNEL_FRC}, {CHANNEL_FL, CHANNEL_FR}}
+	}
+	front_center := CHANNEL_FC
+	for i, is_cpe := range pce.Front_element_is_cpe {
+		tag := pce.Front_element_tag_select[i]
+		if is_cpe {
+			pair := [2]ChannelLabel{CHANNEL_UNKNOWN, CHANNEL_UNKNOWN}
+			if len(front_pairs) > 0 {
+				pair, front_pairs = front_pairs[0], front_pairs[1:]
+			}
+			layout = append(layout, layout_element{ID_CPE, tag, []ChannelLabel{pair[0], pair[1]}})
+		} else {
+			layout = append(layout, layout_element{ID_SCE, tag, []ChannelLabel{front_center}})
+			front_center = CHANNEL_UNKNOWN
+		}
+	}
+
+	for i, is_cpe := range pce.Side_element_is_cpe {
+		tag := pce.Side_element_tag_select[i]
+		if is_cpe {
+			layout = append(layout, layout_element{ID_CPE, tag, []ChannelLabel{CHANNEL_SL, CHANNEL_SR}})
+		} else {
+			layout = append(layout, layout_element{ID_SCE, tag, []ChannelLabel{CHANNEL_UNKNOWN}})
+		}
+	}
+
+	num_back_pairs := 0
+	for _, is_cpe := range pce.Back_element_is_cpe {
+		if is_cpe {
+			num_back_pairs++
+		}
+	}
+	// Without side elements the first of two back pairs is the side pair,
+	// the usual 7.1 arrangement
+	back_pairs := [][2]ChannelLabel{{CHANNEL_BL, CHANNEL_BR}}
+	if num_back_pairs > 1 && pce.Num_side_channel_elements == 0 {
+		back_pairs = [][2]ChannelLabel{{CHANNEL_SL, CHANNEL_SR}, {CHANNEL_BL, CHANNEL_BR}}
+	}
+	back_center := CHANNEL_BC
+	for i, is_cpe := range pce.Back_element_is_cpe {
+		tag := pce.Back_element_tag_select[i]
+		if is_cpe {
+			pair := [2]ChannelLabel{CHANNEL_UNKNOWN, CHANNEL_UNKNOWN}
+			if len(back_pairs) > 0 {
+				pair, back_pairs = back_pairs[0], back_pairs[1:]
+			}
+			layout = append(layout, layout_element{ID_CPE, tag, []ChannelLabel{pair[0], pair[1]}})
+		} else {
+			layout = append(layout, layout_element{ID_SCE, tag, []ChannelLabel{back_center}})
+			back_center = CHANNEL_UNKNOWN
+		}
+	}
+
+	lfe_labels := []ChannelLabel{CHANNEL_LFE, CHANNEL_LFE2}
+	for i, tag := range pce.Lfe_element_tag_select {
+		l := CHANNEL_UNKNOWN
+		if i < len(lfe_labels) {
+			l = lfe_labels[i]
+		}
+		layout = append(layout, layout_element{ID_LFE, tag, []ChannelLabel{l}})
+	}
+
+	return layout
+}
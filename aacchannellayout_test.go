@@ -0,0 +1,114 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChannelConfigurationLayout(t *testing.T) {
+	tests := []struct {
+		config uint8
+		labels []ChannelLabel
+		mask   uint32
+	}{
+		{1, []ChannelLabel{CHANNEL_FC}, 0x4},
+		{2, []ChannelLabel{CHANNEL_FL, CHANNEL_FR}, 0x3},
+		{6, []ChannelLabel{CHANNEL_FC, CHANNEL_FL, CHANNEL_FR, CHANNEL_BL, CHANNEL_BR, CHANNEL_LFE}, 0x3f},
+		{7, []ChannelLabel{CHANNEL_FC, CHANNEL_FLC, CHANNEL_FRC, CHANNEL_FL, CHANNEL_FR, CHANNEL_BL, CHANNEL_BR, CHANNEL_LFE}, 0xff},
+		{11, []ChannelLabel{CHANNEL_FC, CHANNEL_FL, CHANNEL_FR, CHANNEL_SL, CHANNEL_SR, CHANNEL_BC, CHANNEL_LFE}, 0x70f},
+		{12, []ChannelLabel{CHANNEL_FC, CHANNEL_FL, CHANNEL_FR, CHANNEL_SL, CHANNEL_SR, CHANNEL_BL, CHANNEL_BR, CHANNEL_LFE}, 0x63f},
+		{14, []ChannelLabel{CHANNEL_FC, CHANNEL_FL, CHANNEL_FR, CHANNEL_BL, CHANNEL_BR, CHANNEL_LFE, CHANNEL_TFL, CHANNEL_TFR}, 0x503f},
+	}
+	for _, test := range tests {
+		adts := &ADTS{ChannelConfiguration: test.config}
+		labels, err := adts.ChannelLayout()
+		if err != nil {
+			t.Errorf("config %d: err (%s) must be nil", test.config, err)
+			continue
+		}
+		if !reflect.DeepEqual(labels, test.labels) {
+			t.Errorf("config %d: layout %v must be %v", test.config, labels, test.labels)
+		}
+		if mask := ChannelMask(labels); mask != test.mask {
+			t.Errorf("config %d: mask (%#x) must be %#x", test.config, mask, test.mask)
+		}
+	}
+
+	adts := &ADTS{ChannelConfiguration: 13}
+	labels, _ := adts.ChannelLayout()
+	if len(labels) != 24 {
+		t.Errorf("22.2 layout length (%d) must be 24", len(labels))
+	}
+
+	for _, config := range []uint8{0, 8, 15} {
+		adts := &ADTS{ChannelConfiguration: config}
+		if _, err := adts.ChannelLayout(); err == nil {
+			t.Errorf("config %d without a PCE must return an error", config)
+		}
+	}
+}
+
+func TestProgramConfigElementLayout(t *testing.T) {
+	// 5.1 signaled with a PCE, channel elements sent out of PCE order
+	pce := &program_config_element{
+		Num_front_channel_elements: 2,
+		Num_back_channel_elements:  1,
+		Num_lfe_channel_elements:   1,
+		Front_element_is_cpe:       []bool{false, true},
+		Front_element_tag_select:   []uint8{0, 0},
+		Back_element_is_cpe:        []bool{true},
+		Back_element_tag_select:    []uint8{1},
+		Lfe_element_tag_select:     []uint8{0},
+	}
+	adts := &ADTS{
+		ChannelConfiguration:    0,
+		Program_config_elements: []*program_config_element{pce},
+	}
+
+	labels, err := adts.ChannelLayout()
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	want := []ChannelLabel{CHANNEL_FC, CHANNEL_FL, CHANNEL_FR, CHANNEL_BL, CHANNEL_BR, CHANNEL_LFE}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("layout %v must be %v", labels, want)
+	}
+
+	adts.Raw_data_blocks = []*raw_data_block{{
+		Elements: []Element{
+			pce,
+			&channel_pair_element{Element_instance_tag: 1},
+			&single_channel_element{Element_instance_tag: 0},
+			&lfe_channel_element{Element_instance_tag: 0},
+			&channel_pair_element{Element_instance_tag: 0},
+			&end_element{},
+		},
+	}}
+	labels, err = adts.ChannelLayout()
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	want = []ChannelLabel{CHANNEL_BL, CHANNEL_BR, CHANNEL_FC, CHANNEL_LFE, CHANNEL_FL, CHANNEL_FR}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("layout %v must be %v", labels, want)
+	}
+	if mask := ChannelMask(labels); mask != 0x3f {
+		t.Errorf("mask (%#x) must be 0x3f", mask)
+	}
+}
@@ -0,0 +1,406 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"fmt"
+
+	"github.com/Comcast/gaad/bitreader"
+)
+
+// Signals an explicit 24 bit sampling frequency
+const SAMPLING_FREQUENCY_INDEX_ESCAPE = 0xf
+
+// eldExtType ending the extensions of an ELDSpecificConfig
+const ELDEXT_TERM = 0x0
+
+// syncExtensionType of the backward compatible SBR and PS signaling
+const (
+	SYNC_EXTENSION_TYPE_SBR = 0x2b7
+	SYNC_EXTENSION_TYPE_PS  = 0x548
+)
+
+// The decoder configuration of streams without ADTS headers, such as MP4
+// (esds), LATM, DAB+ and DRM
+type AudioSpecificConfig struct {
+	Audio_object_type        uint8
+	Sampling_frequency_index uint8
+	Sampling_frequency       uint32
+	Channel_configuration    uint8
+
+	// Explicit or backward compatible SBR and PS signaling
+	Extension_audio_object_type        uint8
+	Extension_sampling_frequency_index uint8
+	Extension_sampling_frequency       uint32
+	Sbr_present_flag                   bool
+	Ps_present_flag                    bool
+
+	Ga_specific_config  *ga_specific_config
+	Eld_specific_config *eld_specific_config
+	Ep_config           uint8
+}
+
+type ga_specific_config struct {
+	Frame_length_flag      bool
+	Depends_on_core_coder  bool
+	Core_coder_delay       uint16
+	Extension_flag         bool
+	Program_config_element *program_config_element
+
+	Layer_nr      uint8
+	Num_sub_frame uint8
+	Layer_length  uint16
+
+	Aac_section_data_resilience_flag     bool
+	Aac_scalefactor_data_resilience_flag bool
+	Aac_spectral_data_resilience_flag    bool
+	Extension_flag3                      bool
+}
+
+type eld_specific_config struct {
+	Frame_length_flag bool
+
+	Aac_section_data_resilience_flag     bool
+	Aac_scalefactor_data_resilience_flag bool
+	Aac_spectral_data_resilience_flag    bool
+
+	// Low delay SBR, at twice the core rate with Ld_sbr_sampling_rate
+	// set and at the core rate without
+	Ld_sbr_present_flag  bool
+	Ld_sbr_sampling_rate bool
+	Ld_sbr_crc_flag      bool
+	Ld_sbr_header        []*sbr_header
+
+	Eld_extensions []*eld_extension
+}
+
+type eld_extension struct {
+	Eld_ext_type uint8
+	Other_byte   []byte
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Table 1.15 – Syntax of AudioSpecificConfig()
+////////////////////////////////////////////////////////////////////////////////
+func ParseAudioSpecificConfig(byteArray []byte) (*AudioSpecificConfig, error) {
+	var err error
+	adts := &ADTS{}
+	adts.reader = bitreader.NewBitReader(byteArray)
+	config := &AudioSpecificConfig{}
+
+	config.Audio_object_type = adts.audio_object_type()
+	config.Sampling_frequency_index, config.Sampling_frequency = adts.sampling_frequency()
+	config.Channel_configuration, _ = adts.reader.ReadBitsAsUInt8(4)
+
+	if config.Audio_object_type == AUDIO_OBJECT_TYPE_SBR || config.Audio_object_type == AUDIO_OBJECT_TYPE_PS {
+		config.Extension_audio_object_type = AUDIO_OBJECT_TYPE_SBR
+		config.Sbr_present_flag = true
+		config.Ps_present_flag = config.Audio_object_type == AUDIO_OBJECT_TYPE_PS
+		config.Extension_sampling_frequency_index, config.Extension_sampling_frequency = adts.sampling_frequency()
+		config.Audio_object_type = adts.audio_object_type()
+		if config.Audio_object_type == AUDIO_OBJECT_TYPE_ER_BSAC {
+			adts.reader.SkipBits(4) // extensionChannelConfiguration
+		}
+	}
+	if err = adts.check("AudioSpecificConfig", nil); err != nil {
+		return config, err
+	}
+	if config.Sampling_frequency == 0 {
+		return config, adts.check("AudioSpecificConfig", parseErrorf(ErrReserved, "Error: Sampling Frequency Index (%d) is reserved", config.Sampling_frequency_index))
+	}
+
+	switch config.Audio_object_type {
+	case AUDIO_OBJECT_TYPE_AAC_MAIN, AUDIO_OBJECT_TYPE_AAC_LC, AUDIO_OBJECT_TYPE_SSR,
+		AUDIO_OBJECT_TYPE_LTP, AUDIO_OBJECT_TYPE_AAC_SCALABLE, AUDIO_OBJECT_TYPE_TWINVQ,
+		AUDIO_OBJECT_TYPE_ER, AUDIO_OBJECT_TYPE_ER_AAC_LTP, AUDIO_OBJECT_TYPE_ER_AAC_SCALABLE,
+		AUDIO_OBJECT_TYPE_ER_TWINVQ, AUDIO_OBJECT_TYPE_ER_BSAC, AUDIO_OBJECT_TYPE_ER_AAC_LD:
+		if config.Ga_specific_config, err = adts.ga_specific_config(config); err != nil {
+			return config, err
+		}
+	case AUDIO_OBJECT_TYPE_ER_AAC_ELD:
+		if config.Eld_specific_config, err = adts.eld_specific_config(config); err != nil {
+			return config, err
+		}
+	default:
+		return config, adts.check("AudioSpecificConfig", parseErrorf(ErrUnsupported, "Error: Audio Object Type (%d) is not supported", config.Audio_object_type))
+	}
+
+	switch config.Audio_object_type {
+	case AUDIO_OBJECT_TYPE_ER, AUDIO_OBJECT_TYPE_ER_AAC_LTP, AUDIO_OBJECT_TYPE_ER_AAC_SCALABLE,
+		AUDIO_OBJECT_TYPE_ER_TWINVQ, AUDIO_OBJECT_TYPE_ER_BSAC, AUDIO_OBJECT_TYPE_ER_AAC_LD,
+		AUDIO_OBJECT_TYPE_ER_AAC_ELD, AUDIO_OBJECT_TYPE_ER_CELP, AUDIO_OBJECT_TYPE_ER_HVXC, AUDIO_OBJECT_TYPE_ER_HILN,
+		AUDIO_OBJECT_TYPE_ER_PARAMETRIC:
+		config.Ep_config, _ = adts.reader.ReadBitsAsUInt8(2)
+	}
+	if err = adts.check("AudioSpecificConfig", nil); err != nil {
+		return config, err
+	}
+
+	// Implicit signaling appended for decoders that don't know SBR
+	if config.Extension_audio_object_type != AUDIO_OBJECT_TYPE_SBR && adts.reader.BitsLeft() >= 16 {
+		if sync, _ := adts.reader.PeekBits(11); sync == SYNC_EXTENSION_TYPE_SBR {
+			adts.reader.SkipBits(11)
+			config.Extension_audio_object_type = adts.audio_object_type()
+			if config.Extension_audio_object_type == AUDIO_OBJECT_TYPE_SBR {
+				config.Sbr_present_flag, _ = adts.reader.ReadBitAsBool()
+				if config.Sbr_present_flag {
+					config.Extension_sampling_frequency_index, config.Extension_sampling_frequency = adts.sampling_frequency()
+					if adts.reader.BitsLeft() >= 12 {
+						if sync, _ := adts.reader.PeekBits(11); sync == SYNC_EXTENSION_TYPE_PS {
+							adts.reader.SkipBits(11)
+							config.Ps_present_flag, _ = adts.reader.ReadBitAsBool()
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// Samples per channel of each raw_data_block
+func (config *AudioSpecificConfig) FrameLength() uint16 {
+	if eld := config.Eld_specific_config; eld != nil {
+		if eld.Frame_length_flag {
+			return 480
+		}
+		return 512
+	}
+	short := config.Ga_specific_config != nil && config.Ga_specific_config.Frame_length_flag
+	switch {
+	case config.Audio_object_type == AUDIO_OBJECT_TYPE_ER_AAC_LD && short:
+		return 480
+	case config.Audio_object_type == AUDIO_OBJECT_TYPE_ER_AAC_LD:
+		return 512
+	case short:
+		return 960
+	}
+	return 1024
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Table 1.16 – Syntax of GetAudioObjectType()
+////////////////////////////////////////////////////////////////////////////////
+func (adts *ADTS) audio_object_type() uint8 {
+	aot, _ := adts.reader.ReadBitsAsUInt8(5)
+	if aot == 31 {
+		ext, _ := adts.reader.ReadBitsAsUInt8(6)
+		aot = 32 + ext
+	}
+	return aot
+}
+
+// samplingFrequencyIndex, followed by the frequency itself for the escape
+// value
+func (adts *ADTS) sampling_frequency() (uint8, uint32) {
+	sfi, _ := adts.reader.ReadBitsAsUInt8(4)
+	if sfi == SAMPLING_FREQUENCY_INDEX_ESCAPE {
+		freq, _ := adts.reader.ReadBitsAsUInt32(24)
+		return sfi, freq
+	}
+	if int(sfi) < len(SamplingFrequency) {
+		return sfi, SamplingFrequency[sfi]
+	}
+	return sfi, 0
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Table 4.1 – Syntax of GASpecificConfig()
+////////////////////////////////////////////////////////////////////////////////
+func (adts *ADTS) ga_specific_config(config *AudioSpecificConfig) (*ga_specific_config, error) {
+	var err error
+	data := &ga_specific_config{}
+
+	data.Frame_length_flag, _ = adts.reader.ReadBitAsBool()
+	data.Depends_on_core_coder, _ = adts.reader.ReadBitAsBool()
+	if data.Depends_on_core_coder {
+		data.Core_coder_delay, _ = adts.reader.ReadBitsAsUInt16(14)
+	}
+	data.Extension_flag, _ = adts.reader.ReadBitAsBool()
+
+	if config.Channel_configuration == 0 {
+		if data.Program_config_element, err = adts.program_config_element(); err != nil {
+			return data, err
+		}
+	}
+	if config.Audio_object_type == AUDIO_OBJECT_TYPE_AAC_SCALABLE ||
+		config.Audio_object_type == AUDIO_OBJECT_TYPE_ER_AAC_SCALABLE {
+		data.Layer_nr, _ = adts.reader.ReadBitsAsUInt8(3)
+	}
+
+	if data.Extension_flag {
+		if config.Audio_object_type == AUDIO_OBJECT_TYPE_ER_BSAC {
+			data.Num_sub_frame, _ = adts.reader.ReadBitsAsUInt8(5)
+			data.Layer_length, _ = adts.reader.ReadBitsAsUInt16(11)
+		}
+		switch config.Audio_object_type {
+		case AUDIO_OBJECT_TYPE_ER, AUDIO_OBJECT_TYPE_ER_AAC_LTP,
+			AUDIO_OBJECT_TYPE_ER_AAC_SCALABLE, AUDIO_OBJECT_TYPE_ER_AAC_LD:
+			data.Aac_section_data_resilience_flag, _ = adts.reader.ReadBitAsBool()
+			data.Aac_scalefactor_data_resilience_flag, _ = adts.reader.ReadBitAsBool()
+			data.Aac_spectral_data_resilience_flag, _ = adts.reader.ReadBitAsBool()
+		}
+		data.Extension_flag3, _ = adts.reader.ReadBitAsBool()
+	}
+
+	return data, adts.check("GASpecificConfig", nil)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Syntax of ELDSpecificConfig()
+////////////////////////////////////////////////////////////////////////////////
+func (adts *ADTS) eld_specific_config(config *AudioSpecificConfig) (*eld_specific_config, error) {
+	data := &eld_specific_config{}
+
+	data.Frame_length_flag, _ = adts.reader.ReadBitAsBool()
+	data.Aac_section_data_resilience_flag, _ = adts.reader.ReadBitAsBool()
+	data.Aac_scalefactor_data_resilience_flag, _ = adts.reader.ReadBitAsBool()
+	data.Aac_spectral_data_resilience_flag, _ = adts.reader.ReadBitAsBool()
+
+	data.Ld_sbr_present_flag, _ = adts.reader.ReadBitAsBool()
+	if data.Ld_sbr_present_flag {
+		data.Ld_sbr_sampling_rate, _ = adts.reader.ReadBitAsBool()
+		data.Ld_sbr_crc_flag, _ = adts.reader.ReadBitAsBool()
+
+		// ld_sbr_header(), one sbr_header() per SCE and CPE
+		num_sbr_header := 0
+		switch config.Channel_configuration {
+		case 1, 2:
+			num_sbr_header = 1
+		case 3:
+			num_sbr_header = 2
+		case 4, 5, 6:
+			num_sbr_header = 3
+		case 7:
+			num_sbr_header = 4
+		}
+		for el := 0; el < num_sbr_header; el++ {
+			_, header, err := adts.sbr_header()
+			if err != nil {
+				return data, adts.check("ELDSpecificConfig", err)
+			}
+			data.Ld_sbr_header = append(data.Ld_sbr_header, header)
+		}
+
+		config.Extension_audio_object_type = AUDIO_OBJECT_TYPE_SBR
+		config.Sbr_present_flag = true
+		config.Extension_sampling_frequency = config.Sampling_frequency
+		if data.Ld_sbr_sampling_rate {
+			config.Extension_sampling_frequency *= 2
+		}
+	}
+
+	for {
+		ext_type, err := adts.reader.ReadBitsAsUInt8(4) // eldExtType
+		if err != nil {
+			return data, adts.check("ELDSpecificConfig", err)
+		}
+		if ext_type == ELDEXT_TERM {
+			break
+		}
+
+		length, _ := adts.reader.ReadBitsAsUInt(4) // eldExtLen
+		if length == 15 {
+			add, _ := adts.reader.ReadBitsAsUInt(8) // eldExtLenAdd
+			length += add
+			if add == 255 {
+				add, _ = adts.reader.ReadBitsAsUInt(16) // eldExtLenAddAdd
+				length += add
+			}
+		}
+
+		// No extension types are defined yet
+		ext := &eld_extension{Eld_ext_type: ext_type, Other_byte: make([]byte, length)}
+		for i := range ext.Other_byte {
+			if ext.Other_byte[i], err = adts.reader.ReadBitsAsUInt8(8); err != nil {
+				return data, adts.check("ELDSpecificConfig", err)
+			}
+		}
+		data.Eld_extensions = append(data.Eld_extensions, ext)
+	}
+
+	return data, adts.check("ELDSpecificConfig", nil)
+}
+
+// Sampling frequency index whose tables are used for an explicitly signaled
+// frequency, the index of the nearest standard rate
+func sampling_frequency_index(freq uint32) uint8 {
+	bounds := [...]uint32{92017, 75132, 55426, 46009, 37566, 27713, 23004, 18783, 13856, 11502, 9391}
+	for i, bound := range bounds {
+		if freq >= bound {
+			return uint8(i)
+		}
+	}
+	return uint8(len(bounds))
+}
+
+// Parses one raw_data_block() of a stream described by config, as carried
+// without ADTS headers.  The returned ADTS holds the block and the stream
+// parameters the config signals.
+func ParseRawDataBlock(config *AudioSpecificConfig, byteArray []byte) (*ADTS, error) {
+	if config.Ga_specific_config == nil && config.Eld_specific_config == nil {
+		return nil, fmt.Errorf("Error: Audio Object Type (%d) is not supported", config.Audio_object_type)
+	}
+
+	adts := &ADTS{}
+	err := adts.ParseRawDataBlockInto(config, byteArray)
+	return adts, err
+}
+
+// Parses the raw_data_block() in buf into adts like ParseRawDataBlock does,
+// reusing the memory of the frames before the way ParseInto does.  The
+// frame before is invalid after the call.
+func (adts *ADTS) ParseRawDataBlockInto(config *AudioSpecificConfig, buf []byte) error {
+	if config.Ga_specific_config == nil && config.Eld_specific_config == nil {
+		return fmt.Errorf("Error: Audio Object Type (%d) is not supported", config.Audio_object_type)
+	}
+
+	adts.Reset(buf)
+	adts.ChannelConfiguration = config.Channel_configuration
+	adts.Profile = config.Audio_object_type
+	adts.SamplingFrequency = config.Sampling_frequency
+	adts.Frame_length = config.FrameLength()
+	adts.sfi = config.Sampling_frequency_index
+	adts.protection_absent = true
+	if adts.sfi == SAMPLING_FREQUENCY_INDEX_ESCAPE {
+		adts.sfi = sampling_frequency_index(adts.SamplingFrequency)
+	}
+	if ga := config.Ga_specific_config; ga != nil {
+		adts.aac_section_data_resilience_flag = ga.Aac_section_data_resilience_flag
+		adts.aac_scalefactor_data_resilience_flag = ga.Aac_scalefactor_data_resilience_flag
+		adts.aac_spectral_data_resilience_flag = ga.Aac_spectral_data_resilience_flag
+		if pce := ga.Program_config_element; pce != nil {
+			adts.Program_config_elements = adts.mem.program_config_element_list.append(adts.Program_config_elements, pce)
+		}
+	}
+	if eld := config.Eld_specific_config; eld != nil {
+		adts.aac_section_data_resilience_flag = eld.Aac_section_data_resilience_flag
+		adts.aac_scalefactor_data_resilience_flag = eld.Aac_scalefactor_data_resilience_flag
+		adts.aac_spectral_data_resilience_flag = eld.Aac_spectral_data_resilience_flag
+		adts.ld_sbr_header = eld.Ld_sbr_header
+		adts.ld_sbr_sampling_rate = eld.Ld_sbr_sampling_rate
+	}
+
+	var err error
+	if adts.er_syntax() {
+		_, err = adts.er_raw_data_block()
+	} else {
+		_, err = adts.raw_data_block()
+	}
+	return adts.check("raw_data_block", err)
+}
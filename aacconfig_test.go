@@ -0,0 +1,463 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Comcast/gaad/bitreader"
+)
+
+func TestAudioSpecificConfigLC(t *testing.T) {
+	// AAC LC, 44100 Hz, stereo
+	config, err := ParseAudioSpecificConfig([]byte{0x12, 0x10})
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	if config.Audio_object_type != AUDIO_OBJECT_TYPE_AAC_LC {
+		t.Errorf("Audio_object_type (%d) must be AAC LC", config.Audio_object_type)
+	}
+	if config.Sampling_frequency != 44100 || config.Channel_configuration != 2 {
+		t.Errorf("Sampling_frequency/Channel_configuration (%d, %d) must be (44100, 2)",
+			config.Sampling_frequency, config.Channel_configuration)
+	}
+	if config.FrameLength() != 1024 || config.Sbr_present_flag {
+		t.Errorf("FrameLength (%d) must be 1024 without SBR", config.FrameLength())
+	}
+}
+
+func TestAudioSpecificConfig960(t *testing.T) {
+	// DAB+ style: AAC LC, 48000 Hz, mono, frameLengthFlag set
+	w := &bitWriter{}
+	w.write(AUDIO_OBJECT_TYPE_AAC_LC, 5)
+	w.write(3, 4) // samplingFrequencyIndex
+	w.write(1, 4) // channelConfiguration
+	w.write(1, 1) // frameLengthFlag
+	w.write(0, 1) // dependsOnCoreCoder
+	w.write(0, 1) // extensionFlag
+	w.byteAlign()
+
+	config, err := ParseAudioSpecificConfig(w.buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	if config.FrameLength() != 960 {
+		t.Errorf("FrameLength (%d) must be 960", config.FrameLength())
+	}
+}
+
+func TestAudioSpecificConfigSBR(t *testing.T) {
+	// Explicit HE-AAC v2: PS, 24000 Hz core upsampled to 48000 Hz
+	w := &bitWriter{}
+	w.write(AUDIO_OBJECT_TYPE_PS, 5)
+	w.write(6, 4) // samplingFrequencyIndex
+	w.write(1, 4) // channelConfiguration
+	w.write(3, 4) // extensionSamplingFrequencyIndex
+	w.write(AUDIO_OBJECT_TYPE_AAC_LC, 5)
+	w.write(0, 3) // GASpecificConfig
+	w.byteAlign()
+
+	config, err := ParseAudioSpecificConfig(w.buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	if config.Audio_object_type != AUDIO_OBJECT_TYPE_AAC_LC || !config.Sbr_present_flag || !config.Ps_present_flag {
+		t.Errorf("(%d, %t, %t) must be AAC LC with SBR and PS", config.Audio_object_type, config.Sbr_present_flag, config.Ps_present_flag)
+	}
+	if config.Sampling_frequency != 24000 || config.Extension_sampling_frequency != 48000 {
+		t.Errorf("frequencies (%d, %d) must be (24000, 48000)", config.Sampling_frequency, config.Extension_sampling_frequency)
+	}
+
+	// Backward compatible signaling after an explicit 22050 Hz frequency
+	w = &bitWriter{}
+	w.write(AUDIO_OBJECT_TYPE_AAC_LC, 5)
+	w.write(SAMPLING_FREQUENCY_INDEX_ESCAPE, 4)
+	w.write(22050, 24)
+	w.write(2, 4) // channelConfiguration
+	w.write(0, 3) // GASpecificConfig
+	w.write(SYNC_EXTENSION_TYPE_SBR, 11)
+	w.write(AUDIO_OBJECT_TYPE_SBR, 5)
+	w.write(1, 1) // sbrPresentFlag
+	w.write(3, 4) // extensionSamplingFrequencyIndex
+	w.byteAlign()
+
+	config, err = ParseAudioSpecificConfig(w.buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	if config.Sampling_frequency != 22050 || !config.Sbr_present_flag || config.Extension_sampling_frequency != 48000 {
+		t.Errorf("(%d, %t, %d) must be 22050 Hz with SBR at 48000 Hz",
+			config.Sampling_frequency, config.Sbr_present_flag, config.Extension_sampling_frequency)
+	}
+	if sampling_frequency_index(config.Sampling_frequency) != 7 {
+		t.Errorf("sampling_frequency_index (%d) must be 7", sampling_frequency_index(config.Sampling_frequency))
+	}
+
+	if _, err := ParseAudioSpecificConfig([]byte{0x12}); err == nil {
+		t.Errorf("truncated config must return an error")
+	}
+}
+
+func TestParseRawDataBlock960(t *testing.T) {
+	config := &AudioSpecificConfig{
+		Audio_object_type:        AUDIO_OBJECT_TYPE_AAC_LC,
+		Sampling_frequency_index: 3,
+		Sampling_frequency:       48000,
+		Channel_configuration:    1,
+		Ga_specific_config:       &ga_specific_config{Frame_length_flag: true},
+	}
+
+	for _, window_sequence := range []uint64{ONLY_LONG_SEQUENCE, EIGHT_SHORT_SEQUENCE} {
+		w := &bitWriter{}
+		w.write(ID_SCE, 3)
+		w.write(0, 4)   // element_instance_tag
+		w.write(100, 8) // global_gain
+		w.write(0, 1)   // ics_reserved_bit
+		w.write(window_sequence, 2)
+		w.write(0, 1) // window_shape
+		if window_sequence == EIGHT_SHORT_SEQUENCE {
+			w.write(0, 4)    // max_sfb
+			w.write(0x7f, 7) // scale_factor_grouping
+		} else {
+			w.write(0, 6) // max_sfb
+			w.write(0, 1) // predictor_data_present
+		}
+		w.write(0, 1) // pulse_data_present
+		w.write(0, 1) // tns_data_present
+		w.write(0, 1) // gain_control_data_present
+		w.write(ID_END, 3)
+		w.byteAlign()
+
+		adts, err := ParseRawDataBlock(config, w.buf)
+		if err != nil {
+			t.Fatalf("err (%s) must be nil", err)
+		}
+		if adts.Frame_length != 960 || adts.Samples() != 960 {
+			t.Errorf("Frame_length/Samples (%d, %d) must be 960", adts.Frame_length, adts.Samples())
+		}
+		info := adts.Single_channel_elements[0].Channel_stream.Ics_info
+		if window_sequence == EIGHT_SHORT_SEQUENCE {
+			if info.num_swb != 14 || info.swb_offset[len(info.swb_offset)-1] != 120 {
+				t.Errorf("short windows must have 14 bands up to 120, have %d up to %d", info.num_swb, info.swb_offset[info.num_swb])
+			}
+		} else if info.num_swb != 49 || info.swb_offset[len(info.swb_offset)-1] != 960 {
+			t.Errorf("long windows must have 49 bands up to 960, have %d up to %d", info.num_swb, info.swb_offset[info.num_swb])
+		}
+	}
+}
+
+func TestSbrEnvelopeBorders(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		time_slots uint8
+		grid       *sbr_grid
+		borders    []uint8
+	}{
+		{"FIXFIX", SBR_NUM_TIME_SLOTS, &sbr_grid{bs_num_env: []uint8{4}}, []uint8{0, 4, 8, 12, 16}},
+		{"FIXFIX 960", SBR_NUM_TIME_SLOTS_960, &sbr_grid{bs_num_env: []uint8{2}}, []uint8{0, 8, 15}},
+		{"FIXVAR", SBR_NUM_TIME_SLOTS, &sbr_grid{
+			Bs_frame_class: [2]uint8{FIXVAR},
+			Bs_var_bord_1:  []uint8{2},
+			bs_num_env:     []uint8{3},
+			bs_rel_bord_1:  [][]uint8{{4, 2}},
+		}, []uint8{0, 12, 14, 18}},
+		{"VARVAR 960", SBR_NUM_TIME_SLOTS_960, &sbr_grid{
+			Bs_frame_class: [2]uint8{VARVAR},
+			Bs_var_bord_0:  []uint8{1},
+			Bs_var_bord_1:  []uint8{1},
+			bs_num_env:     []uint8{3},
+			bs_rel_bord_0:  [][]uint8{{4}},
+			bs_rel_bord_1:  [][]uint8{{6}},
+		}, []uint8{1, 5, 10, 16}},
+	} {
+		test.grid.num_time_slots = test.time_slots
+		if borders := test.grid.EnvelopeBorders(0); !reflect.DeepEqual(borders, test.borders) {
+			t.Errorf("%s: borders %v must be %v", test.name, borders, test.borders)
+		}
+	}
+
+	adts := &ADTS{Frame_length: 960}
+	if adts.sbr_num_time_slots() != 15 {
+		t.Errorf("numTimeSlots (%d) must be 15 for 960 sample frames", adts.sbr_num_time_slots())
+	}
+}
+
+// The relative borders of sbr_grid() are 2*tmp + 2 time slots, the grids
+// of TestSbrEnvelopeBorders parsed from their bits
+func TestSbrGridParse(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		frame_length uint16
+		bits         func(w *bitWriter)
+		rel_bord_0   []uint8
+		rel_bord_1   []uint8
+		borders      []uint8
+	}{
+		{"FIXVAR", 1024, func(w *bitWriter) {
+			w.write(FIXVAR, 2)
+			w.write(2, 2) // bs_var_bord_1
+			w.write(2, 2) // bs_num_rel_1
+			w.write(1, 2) // bs_rel_bord_1[0]
+			w.write(0, 2) // bs_rel_bord_1[1]
+			w.write(0, 2) // bs_pointer
+			w.write(0, 3) // bs_freq_res
+		}, []uint8{}, []uint8{4, 2}, []uint8{0, 12, 14, 18}},
+		{"VARVAR 960", 960, func(w *bitWriter) {
+			w.write(VARVAR, 2)
+			w.write(1, 2) // bs_var_bord_0
+			w.write(1, 2) // bs_var_bord_1
+			w.write(1, 2) // bs_num_rel_0
+			w.write(1, 2) // bs_num_rel_1
+			w.write(1, 2) // bs_rel_bord_0[0]
+			w.write(2, 2) // bs_rel_bord_1[0]
+			w.write(0, 2) // bs_pointer
+			w.write(0, 3) // bs_freq_res
+		}, []uint8{4}, []uint8{6}, []uint8{1, 5, 10, 16}},
+	} {
+		w := &bitWriter{}
+		test.bits(w)
+		adts := &ADTS{Profile: AUDIO_OBJECT_TYPE_AAC_LC, Frame_length: test.frame_length}
+		adts.reader = bitreader.NewBitReader(w.buf)
+		grid := adts.new_sbr_grid()
+		if err := adts.sbr_grid(0, grid, &sbr_header{}); err != nil {
+			t.Fatalf("%s: err (%s) must be nil", test.name, err)
+		}
+		if len(grid.bs_rel_bord_0[0]) != len(test.rel_bord_0) ||
+			(len(test.rel_bord_0) > 0 && !reflect.DeepEqual(grid.bs_rel_bord_0[0], test.rel_bord_0)) {
+			t.Errorf("%s: bs_rel_bord_0 %v must be %v", test.name, grid.bs_rel_bord_0[0], test.rel_bord_0)
+		}
+		if !reflect.DeepEqual(grid.bs_rel_bord_1[0], test.rel_bord_1) {
+			t.Errorf("%s: bs_rel_bord_1 %v must be %v", test.name, grid.bs_rel_bord_1[0], test.rel_bord_1)
+		}
+		if borders := grid.EnvelopeBorders(0); !reflect.DeepEqual(borders, test.borders) {
+			t.Errorf("%s: borders %v must be %v", test.name, borders, test.borders)
+		}
+	}
+}
+
+// The LD_TRAN grids of low delay SBR, whose envelopes come from the LD
+// envelope table of the time slots
+func TestSbrGridLdTran(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		frame_length uint16
+		dual_rate    bool
+		position     uint8
+		freq_res     []uint8
+		borders      []uint8
+	}{
+		{"512 dual rate", 512, true, 5, []uint8{1, 0, 1}, []uint8{0, 5, 9, 16}},
+		{"512 single rate", 512, false, 0, []uint8{0, 1}, []uint8{0, 4, 16}},
+		{"480 dual rate", 480, true, 9, []uint8{0, 1, 1}, []uint8{0, 9, 13, 15}},
+		{"480 single rate", 480, false, 10, []uint8{1, 1}, []uint8{0, 10, 15}},
+	} {
+		w := &bitWriter{}
+		w.write(1, 1) // bs_frame_class
+		w.write(uint64(test.position), 4)
+		for _, res := range test.freq_res {
+			w.write(uint64(res), 1)
+		}
+		adts := &ADTS{Profile: AUDIO_OBJECT_TYPE_ER_AAC_ELD, Frame_length: test.frame_length, ld_sbr_sampling_rate: test.dual_rate}
+		adts.reader = bitreader.NewBitReader(w.buf)
+		grid := adts.new_sbr_grid()
+		if err := adts.sbr_grid(0, grid, &sbr_header{}); err != nil {
+			t.Fatalf("%s: err (%s) must be nil", test.name, err)
+		}
+		if grid.Bs_frame_class[0] != LD_TRAN || grid.Bs_transient_position[0] != test.position {
+			t.Errorf("%s: (%d, %d) must be LD_TRAN at %d", test.name, grid.Bs_frame_class[0], grid.Bs_transient_position[0], test.position)
+		}
+		if !reflect.DeepEqual(grid.Bs_freq_res[0], test.freq_res) || grid.bs_num_noise[0] != 2 {
+			t.Errorf("%s: Bs_freq_res %v must be %v with 2 noise floors, have %d", test.name, grid.Bs_freq_res[0], test.freq_res, grid.bs_num_noise[0])
+		}
+		if adts.reader.BitsLeft() != uint(len(w.buf)*8-5-len(test.freq_res)) {
+			t.Errorf("%s: %d bits must be left", test.name, adts.reader.BitsLeft())
+		}
+		if borders := grid.EnvelopeBorders(0); !reflect.DeepEqual(borders, test.borders) {
+			t.Errorf("%s: borders %v must be %v", test.name, borders, test.borders)
+		}
+	}
+
+	// 15 time slots have no transient in the last one
+	w := &bitWriter{}
+	w.write(1, 1)
+	w.write(15, 4)
+	w.write(0, 3)
+	adts := &ADTS{Profile: AUDIO_OBJECT_TYPE_ER_AAC_ELD, Frame_length: 480}
+	adts.reader = bitreader.NewBitReader(w.buf)
+	if err := adts.sbr_grid(0, adts.new_sbr_grid(), &sbr_header{}); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("err (%v) must be ErrOutOfRange for bs_transient_position 15 of 15 time slots", err)
+	}
+}
+
+func TestAudioSpecificConfigELD(t *testing.T) {
+	// ER AAC ELD, 24000 Hz, mono, 480 sample frames with dual rate SBR
+	w := &bitWriter{}
+	w.write(31, 5)
+	w.write(AUDIO_OBJECT_TYPE_ER_AAC_ELD-32, 6)
+	w.write(6, 4) // samplingFrequencyIndex
+	w.write(1, 4) // channelConfiguration
+	w.write(1, 1) // frameLengthFlag
+	w.write(0, 3) // resilience flags
+	w.write(1, 1) // ldSbrPresentFlag
+	w.write(1, 1) // ldSbrSamplingRate
+	w.write(0, 1) // ldSbrCrcFlag
+	w.write(0, 1) // bs_amp_res
+	w.write(5, 4) // bs_start_freq
+	w.write(9, 4) // bs_stop_freq
+	w.write(0, 3) // bs_xover_band
+	w.write(0, 2) // bs_reserved
+	w.write(0, 2) // bs_header_extra_1, bs_header_extra_2
+	w.write(2, 4) // eldExtType
+	w.write(1, 4) // eldExtLen
+	w.write(0xab, 8)
+	w.write(ELDEXT_TERM, 4)
+	w.write(0, 2) // epConfig
+	w.byteAlign()
+
+	config, err := ParseAudioSpecificConfig(w.buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	eld := config.Eld_specific_config
+	if eld == nil || config.Audio_object_type != AUDIO_OBJECT_TYPE_ER_AAC_ELD {
+		t.Fatalf("Audio_object_type (%d) must be ER AAC ELD with an ELDSpecificConfig", config.Audio_object_type)
+	}
+	if config.FrameLength() != 480 {
+		t.Errorf("FrameLength (%d) must be 480", config.FrameLength())
+	}
+	if !config.Sbr_present_flag || config.Extension_sampling_frequency != 48000 {
+		t.Errorf("(%t, %d) must be SBR at 48000 Hz", config.Sbr_present_flag, config.Extension_sampling_frequency)
+	}
+	if len(eld.Ld_sbr_header) != 1 || eld.Ld_sbr_header[0].Bs_start_freq != 5 || eld.Ld_sbr_header[0].Bs_stop_freq != 9 {
+		t.Errorf("ld_sbr_header must hold one sbr_header with start 5 and stop 9")
+	}
+	if len(eld.Eld_extensions) != 1 || !reflect.DeepEqual(eld.Eld_extensions[0].Other_byte, []byte{0xab}) {
+		t.Errorf("Eld_extensions must hold the one extension byte")
+	}
+
+	adts := &ADTS{Profile: AUDIO_OBJECT_TYPE_ER_AAC_ELD, Frame_length: 480, ld_sbr_sampling_rate: true}
+	if adts.sbr_num_time_slots() != 15 {
+		t.Errorf("numTimeSlots (%d) must be 15 for dual rate 480 sample frames", adts.sbr_num_time_slots())
+	}
+
+	// Single rate halves the QMF bands, not the time slots
+	for _, frame_length := range []uint16{512, 480} {
+		adts = &ADTS{Profile: AUDIO_OBJECT_TYPE_ER_AAC_ELD, Frame_length: frame_length}
+		if adts.sbr_num_time_slots() != uint8(frame_length/32) {
+			t.Errorf("numTimeSlots (%d) must be %d for single rate %d sample frames", adts.sbr_num_time_slots(), frame_length/32, frame_length)
+		}
+	}
+}
+
+func TestErRawDataBlockLD(t *testing.T) {
+	// ER AAC LD, 48000 Hz, stereo in 512 sample frames
+	config := &AudioSpecificConfig{
+		Audio_object_type:        AUDIO_OBJECT_TYPE_ER_AAC_LD,
+		Sampling_frequency_index: 3,
+		Sampling_frequency:       48000,
+		Channel_configuration:    2,
+		Ga_specific_config:       &ga_specific_config{},
+	}
+
+	w := &bitWriter{}
+	w.write(5, 4) // element_instance_tag
+	w.write(0, 1) // common_window
+	for ch := 0; ch < 2; ch++ {
+		w.write(100, 8) // global_gain
+		w.write(0, 1)   // ics_reserved_bit
+		w.write(ONLY_LONG_SEQUENCE, 2)
+		w.write(0, 1) // window_shape
+		w.write(0, 6) // max_sfb
+		w.write(0, 1) // predictor_data_present
+		w.write(0, 1) // pulse_data_present
+		w.write(0, 1) // tns_data_present
+		w.write(0, 1) // gain_control_data_present
+	}
+	w.byteAlign()
+
+	adts, err := ParseRawDataBlock(config, w.buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	if len(adts.Channel_pair_elements) != 1 || adts.Channel_pair_elements[0].Element_instance_tag != 5 {
+		t.Fatalf("er_raw_data_block must hold one CPE with tag 5")
+	}
+	info := adts.Channel_pair_elements[0].Channel_stream1.Ics_info
+	if adts.Frame_length != 512 || info.num_swb != 36 || info.swb_offset[info.num_swb] != 512 {
+		t.Errorf("512 sample frames must have 36 bands up to 512, have %d up to %d", info.num_swb, info.swb_offset[info.num_swb])
+	}
+
+	// Short windows don't exist in ER AAC LD
+	w = &bitWriter{}
+	w.write(0, 4)   // element_instance_tag
+	w.write(0, 1)   // common_window
+	w.write(100, 8) // global_gain
+	w.write(0, 1)   // ics_reserved_bit
+	w.write(EIGHT_SHORT_SEQUENCE, 2)
+	w.byteAlign()
+	if _, err := ParseRawDataBlock(config, w.buf); err == nil {
+		t.Errorf("EIGHT_SHORT_SEQUENCE must return an error in ER AAC LD")
+	}
+}
+
+func TestErRawDataBlockELD(t *testing.T) {
+	// ER AAC ELD, 48000 Hz, mono in 480 sample frames
+	config := &AudioSpecificConfig{
+		Audio_object_type:        AUDIO_OBJECT_TYPE_ER_AAC_ELD,
+		Sampling_frequency_index: 3,
+		Sampling_frequency:       48000,
+		Channel_configuration:    1,
+		Eld_specific_config:      &eld_specific_config{Frame_length_flag: true},
+	}
+
+	w := &bitWriter{}
+	w.write(100, 8) // global_gain
+	w.write(1, 6)   // max_sfb
+	w.write(1, 4)   // sect_cb
+	w.write(1, 5)   // sect_len
+	w.write(0, 1)   // hcod_sf of no difference
+	w.write(1, 1)   // tns_data_present
+	w.write(1, 2)   // n_filt
+	w.write(0, 1)   // coef_res
+	w.write(0, 6)   // length
+	w.write(0, 5)   // order
+	w.write(0, 1)   // hcod of a zero quad
+	w.write(EXT_FILL_DATA, 4)
+	w.write(0, 4) // fill_nibble
+	w.write(0xa5, 8)
+	w.byteAlign()
+
+	adts, err := ParseRawDataBlock(config, w.buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	if len(adts.Single_channel_elements) != 1 {
+		t.Fatalf("er_raw_data_block must hold one SCE")
+	}
+	s := adts.Single_channel_elements[0].Channel_stream
+	if s.Ics_info.num_swb != 35 || s.Ics_info.swb_offset[s.Ics_info.num_swb] != 480 {
+		t.Errorf("480 sample frames must have 35 bands up to 480, have %d", s.Ics_info.num_swb)
+	}
+	if !s.Tns_data_present || s.Tns_data == nil || len(s.Spectral_data.Hcod) != 1 {
+		t.Errorf("tns_data and one spectral quad must follow the side information")
+	}
+	if len(adts.Fill_elements) != 1 || !reflect.DeepEqual(adts.Fill_elements[0].Extension_payload.Fill_byte, []byte{0xa5}) {
+		t.Errorf("the extension payload after the SCE must be kept as a fill element")
+	}
+}
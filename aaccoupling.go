@@ -0,0 +1,217 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"fmt"
+	"math"
+)
+
+// Where in the decode a coupling channel is added to its targets
+const (
+	COUPLING_BEFORE_TNS            = 0 // dependent, spectral domain
+	COUPLING_BETWEEN_TNS_AND_IMDCT = 1 // dependent, spectral domain
+	COUPLING_AFTER_IMDCT           = 3 // independently switched, time domain
+)
+
+// Gain step of each gain_element_scale
+var cce_scale = [4]float64{
+	math.Pow(2, 1.0/8),
+	math.Pow(2, 1.0/4),
+	math.Sqrt2,
+	2,
+}
+
+// Decode stage the element couples into: independently switched CCEs are
+// added to the time domain output, dependent ones to the spectrum before or
+// after TNS depending on cc_domain.  An independently switched CCE without
+// cc_domain set is invalid, ApplyCoupling rejects it.
+func (e *coupling_channel_element) CouplingPoint() uint8 {
+	if e.Ind_sw_cce_flag {
+		return COUPLING_AFTER_IMDCT
+	}
+	if e.Cc_domain {
+		return COUPLING_BETWEEN_TNS_AND_IMDCT
+	}
+	return COUPLING_BEFORE_TNS
+}
+
+// Gain of every gain element list.  Independently switched elements have a
+// single gain per list, dependent ones a gain per [g*max_sfb+sfb] band, 0 for
+// bands coded with ZERO_HCB.  The first list is always unity gain.
+func (e *coupling_channel_element) Gains() [][]float32 {
+	scale := cce_scale[e.Gain_element_scale&3]
+	info := e.Channel_stream.Ics_info
+
+	gains := make([][]float32, len(e.Common_gain_element))
+	for c := range gains {
+		cge := c == 0 || e.Ind_sw_cce_flag || e.Common_gain_element_present[c]
+		gain := 0
+		if c > 0 && cge {
+			gain = int(e.Common_gain_element[c]) - 60
+		}
+		gain_cache := float32(math.Pow(scale, float64(-gain)))
+
+		if e.Ind_sw_cce_flag {
+			gains[c] = []float32{gain_cache}
+			continue
+		}
+
+		gains[c] = make([]float32, int(info.num_window_groups)*int(info.Max_sfb))
+		idx := 0
+		for g := 0; g < int(info.num_window_groups); g++ {
+			for sfb := 0; sfb < int(info.Max_sfb); sfb++ {
+				if info.sfb_cb[g][sfb] != ZERO_HCB {
+					if !cge {
+						// dpcm_gain_element is differential across bands, with
+						// the sign in the LSB when gain_element_sign is set
+						if t := int(e.DCPM_gain_element[c][g][sfb]) - 60; t != 0 {
+							s := float64(1)
+							gain += t
+							t = gain
+							if e.Gain_element_sign {
+								s -= float64(2 * (t & 1))
+								t >>= 1
+							}
+							gain_cache = float32(math.Pow(scale, float64(-t)) * s)
+						}
+					}
+					gains[c][idx] = gain_cache
+				}
+				idx++
+			}
+		}
+	}
+	return gains
+}
+
+// Adds a coupling channel into the channels it targets.  src is the CCE's
+// own decoded signal and channels[channel] the raw_data_block's channels in
+// ChannelLayout order, both at the decode stage given by point: dequantized
+// spectra (one window after the other) for the dependent coupling points,
+// time domain output for COUPLING_AFTER_IMDCT.  Nothing is done when the
+// element couples at a different point.
+//
+// Targets and gain lists are matched as decoders do: a CPE target with both
+// cc_l and cc_r uses one gain list per channel, with neither both channels
+// share one list.
+func (adts *ADTS) ApplyCoupling(block int, point uint8, cce *coupling_channel_element, src []float32, channels [][]float32) error {
+	// Time domain coupling has to be signaled in cc_domain too
+	if cce.Ind_sw_cce_flag && !cce.Cc_domain {
+		return fmt.Errorf("Error: independently switched coupling_channel_element without cc_domain")
+	}
+	if cce.CouplingPoint() != point {
+		return nil
+	}
+	if block < 0 || block >= len(adts.Raw_data_blocks) {
+		return fmt.Errorf("Error: raw_data_block (%d) out of range", block)
+	}
+	positions, err := adts.block_channel_positions(block)
+	if err != nil {
+		return err
+	}
+	if len(channels) != len(positions) {
+		return fmt.Errorf("Error: %d channels given, layout has %d", len(channels), len(positions))
+	}
+
+	gains := cce.Gains()
+	index := 0
+	for c := range cce.Cc_target_is_cpe {
+		id := uint8(ID_SCE)
+		// cc_l/cc_r as a 2 bit ch_select, an SCE target is a left only CPE
+		ch_select := 2
+		if cce.Cc_target_is_cpe[c] {
+			id = ID_CPE
+			ch_select = 0
+			if cce.Cc_l[c] {
+				ch_select |= 2
+			}
+			if cce.Cc_r[c] {
+				ch_select |= 1
+			}
+		}
+
+		target := -1
+		for ch, p := range positions {
+			if p.Id_syn_ele == id && p.Tag == cce.Cc_target_tag_select[c] {
+				target = ch
+				break
+			}
+		}
+		if target < 0 {
+			index++
+			if ch_select == 3 {
+				index++
+			}
+			continue
+		}
+
+		if ch_select != 1 {
+			if index >= len(gains) {
+				return fmt.Errorf("Error: coupling gain list (%d) out of range", index)
+			}
+			cce.couple(point, gains[index], src, channels[target])
+			if ch_select != 0 {
+				index++
+			}
+		}
+		if ch_select != 2 {
+			if index >= len(gains) || target+1 >= len(channels) {
+				return fmt.Errorf("Error: coupling gain list (%d) out of range", index)
+			}
+			cce.couple(point, gains[index], src, channels[target+1])
+			index++
+		}
+	}
+	return nil
+}
+
+func (cce *coupling_channel_element) couple(point uint8, gains []float32, src []float32, dest []float32) {
+	if point == COUPLING_AFTER_IMDCT {
+		gain := gains[0]
+		for i := 0; i < len(src) && i < len(dest); i++ {
+			dest[i] += gain * src[i]
+		}
+		return
+	}
+
+	info := cce.Channel_stream.Ics_info
+	window_length := len(dest)
+	if info.Window_sequence == EIGHT_SHORT_SEQUENCE {
+		window_length /= 8
+	}
+
+	idx := 0
+	window := 0
+	for g := 0; g < int(info.num_window_groups); g++ {
+		for sfb := 0; sfb < int(info.Max_sfb); sfb++ {
+			if info.sfb_cb[g][sfb] != ZERO_HCB {
+				gain := gains[idx]
+				for w := window; w < window+int(info.window_group_length[g]); w++ {
+					for k := int(info.swb_offset[sfb]); k < int(info.swb_offset[sfb+1]); k++ {
+						i := w*window_length + k
+						if i < len(src) && i < len(dest) {
+							dest[i] += gain * src[i]
+						}
+					}
+				}
+			}
+			idx++
+		}
+		window += int(info.window_group_length[g])
+	}
+}
@@ -0,0 +1,134 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import "testing"
+
+// A CCE coupling into the SCE and both channels of the CPE of a 3 channel
+// frame, with a long window whose second band is ZERO_HCB
+func couplingTestFrame(ind_sw bool) (*ADTS, *coupling_channel_element) {
+	info := &ics_info{
+		Window_sequence:     ONLY_LONG_SEQUENCE,
+		Max_sfb:             2,
+		num_windows:         1,
+		num_window_groups:   1,
+		window_group_length: []uint8{1},
+		swb_offset:          []uint16{0, 4, 8, 12},
+		sfb_cb:              [][]uint8{{1, ZERO_HCB}},
+	}
+	cce := &coupling_channel_element{
+		Ind_sw_cce_flag:             ind_sw,
+		Cc_domain:                   ind_sw,
+		Num_coupled_elements:        1,
+		Cc_target_is_cpe:            []bool{false, true},
+		Cc_target_tag_select:        []uint8{0, 0},
+		Cc_l:                        []bool{false, true},
+		Cc_r:                        []bool{false, true},
+		Gain_element_scale:          3,
+		Channel_stream:              &individual_channel_stream{Ics_info: info},
+		Common_gain_element_present: []bool{false, true, false},
+		Common_gain_element:         []uint8{0, 59, 61},
+		DCPM_gain_element:           [][][]uint8{nil, nil, {{62, 60}}},
+	}
+	adts := &ADTS{
+		ChannelConfiguration: 3,
+		Raw_data_blocks: []*raw_data_block{{
+			Elements: []Element{
+				&single_channel_element{Element_instance_tag: 0},
+				&channel_pair_element{Element_instance_tag: 0},
+				cce,
+				&end_element{},
+			},
+		}},
+	}
+	return adts, cce
+}
+
+func TestDependentCoupling(t *testing.T) {
+	adts, cce := couplingTestFrame(false)
+	if cce.CouplingPoint() != COUPLING_BEFORE_TNS {
+		t.Fatalf("CouplingPoint (%d) must be COUPLING_BEFORE_TNS", cce.CouplingPoint())
+	}
+
+	src := make([]float32, 1024)
+	for i := range src {
+		src[i] = 1
+	}
+	channels := [][]float32{make([]float32, 1024), make([]float32, 1024), make([]float32, 1024)}
+
+	// Wrong coupling point is a no-op
+	adts.ApplyCoupling(0, COUPLING_AFTER_IMDCT, cce, src, channels)
+	if channels[0][0] != 0 {
+		t.Errorf("coupling at the wrong point must not change the channels")
+	}
+
+	if err := adts.ApplyCoupling(0, COUPLING_BEFORE_TNS, cce, src, channels); err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	for ch, want := range []float64{1, 2, 0.25} {
+		if !approx(channels[ch][0], want) || !approx(channels[ch][3], want) {
+			t.Errorf("channel %d band 0 (%f) must be %f", ch, channels[ch][0], want)
+		}
+		if channels[ch][4] != 0 || channels[ch][1023] != 0 {
+			t.Errorf("channel %d outside the coded bands (%f) must be 0", ch, channels[ch][4])
+		}
+	}
+}
+
+func TestIndependentCoupling(t *testing.T) {
+	adts, cce := couplingTestFrame(true)
+	if cce.CouplingPoint() != COUPLING_AFTER_IMDCT {
+		t.Fatalf("CouplingPoint (%d) must be COUPLING_AFTER_IMDCT", cce.CouplingPoint())
+	}
+
+	src := make([]float32, 1024)
+	for i := range src {
+		src[i] = 1
+	}
+	channels := [][]float32{make([]float32, 1024), make([]float32, 1024), make([]float32, 1024)}
+	if err := adts.ApplyCoupling(0, COUPLING_AFTER_IMDCT, cce, src, channels); err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	for ch, want := range []float64{1, 2, 0.5} {
+		if !approx(channels[ch][0], want) || !approx(channels[ch][1023], want) {
+			t.Errorf("channel %d (%f) must be %f", ch, channels[ch][1023], want)
+		}
+	}
+
+	// Independently switched without cc_domain is invalid at any point
+	cce.Cc_domain = false
+	for _, point := range []uint8{COUPLING_BEFORE_TNS, COUPLING_BETWEEN_TNS_AND_IMDCT, COUPLING_AFTER_IMDCT} {
+		if err := adts.ApplyCoupling(0, point, cce, src, channels); err == nil {
+			t.Errorf("coupling point %d: an independently switched CCE without cc_domain must return an error", point)
+		}
+	}
+}
+
+func TestCouplingGainSign(t *testing.T) {
+	_, cce := couplingTestFrame(false)
+	cce.Gain_element_sign = true
+	cce.DCPM_gain_element[2][0][0] = 63
+
+	gains := cce.Gains()
+	// gain 3: LSB set flips the sign, the remaining 1 is the exponent
+	if !approx(gains[2][0], -0.5) {
+		t.Errorf("signed gain (%f) must be -0.5", gains[2][0])
+	}
+	if gains[2][1] != 0 {
+		t.Errorf("ZERO_HCB band gain (%f) must be 0", gains[2][1])
+	}
+}
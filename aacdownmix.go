@@ -0,0 +1,224 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"fmt"
+	"math"
+)
+
+// Matrix-mixdown coefficient a, indexed by matrix_mixdown_idx (4.5.1.2.2)
+var matrix_mixdown_coefficient = [4]float64{
+	1 / math.Sqrt2,
+	1.0 / 2,
+	1 / (2 * math.Sqrt2),
+	0,
+}
+
+// ITU-R BS.775 downmix gains of each speaker position into the left and
+// right outputs.  The LFE is discarded.
+var bs775_downmix_coefficients = map[ChannelLabel][2]float64{
+	CHANNEL_FL:   {1, 0},
+	CHANNEL_FR:   {0, 1},
+	CHANNEL_FC:   {1 / math.Sqrt2, 1 / math.Sqrt2},
+	CHANNEL_FLC:  {1, 0},
+	CHANNEL_FRC:  {0, 1},
+	CHANNEL_SL:   {1 / math.Sqrt2, 0},
+	CHANNEL_SR:   {0, 1 / math.Sqrt2},
+	CHANNEL_BL:   {1 / math.Sqrt2, 0},
+	CHANNEL_BR:   {0, 1 / math.Sqrt2},
+	CHANNEL_BC:   {0.5, 0.5},
+	CHANNEL_TFL:  {1 / math.Sqrt2, 0},
+	CHANNEL_TFR:  {0, 1 / math.Sqrt2},
+	CHANNEL_TFC:  {0.5, 0.5},
+	CHANNEL_TSL:  {0.5, 0},
+	CHANNEL_TSR:  {0, 0.5},
+	CHANNEL_TC:   {0.5, 0.5},
+	CHANNEL_TBL:  {0.5, 0},
+	CHANNEL_TBR:  {0, 0.5},
+	CHANNEL_TBC:  {0.5 / math.Sqrt2, 0.5 / math.Sqrt2},
+	CHANNEL_BFL:  {1 / math.Sqrt2, 0},
+	CHANNEL_BFR:  {0, 1 / math.Sqrt2},
+	CHANNEL_BFC:  {0.5, 0.5},
+	CHANNEL_LFE:  {0, 0},
+	CHANNEL_LFE2: {0, 0},
+}
+
+// Downmixes decoded PCM, one slice of samples per channel in ChannelLayout
+// order, to stereo (channels == 2) or mono (channels == 1).
+//
+// The mixdown signaled by the frame's program_config_element is honored
+// first: a dedicated stereo or mono mixdown element is used as-is, otherwise
+// a 3/2 layout with matrix_mixdown_idx is mixed with the matrix-mixdown
+// coefficients.  Everything else falls back to ITU-R BS.775.
+func (adts *ADTS) Downmix(pcm [][]float32, channels int) ([][]float32, error) {
+	if channels != 1 && channels != 2 {
+		return nil, fmt.Errorf("Error: cannot downmix to %d channels", channels)
+	}
+
+	positions, err := adts.channel_positions()
+	if err != nil {
+		return nil, err
+	}
+	if len(pcm) != len(positions) {
+		return nil, fmt.Errorf("Error: pcm has %d channels, layout has %d", len(pcm), len(positions))
+	}
+	num_samples := 0
+	if len(pcm) > 0 {
+		num_samples = len(pcm[0])
+	}
+	for ch := range pcm {
+		if len(pcm[ch]) != num_samples {
+			return nil, fmt.Errorf("Error: channel %d has %d samples, expected %d", ch, len(pcm[ch]), num_samples)
+		}
+	}
+
+	var pce *program_config_element
+	if len(adts.Program_config_elements) > 0 {
+		pce = adts.Program_config_elements[0]
+	}
+
+	if pce != nil {
+		if channels == 2 && pce.Stereo_mixdown_present {
+			if out := mixdown_element(pcm, positions, ID_CPE, pce.Stereo_mixdown_element_num); out != nil {
+				return out, nil
+			}
+		}
+		if channels == 1 && pce.Mono_mixdown_present {
+			if out := mixdown_element(pcm, positions, ID_SCE, pce.Mono_mixdown_element_num); out != nil {
+				return out, nil
+			}
+		}
+	}
+
+	coefs := make([][2]float64, len(positions))
+	if pce == nil || !pce.Matrix_mixdown_idx_present || !matrix_mixdown_coefficients(positions, pce, coefs) {
+		bs775_coefficients(positions, coefs)
+	}
+
+	out := make([][]float32, channels)
+	for i := range out {
+		out[i] = make([]float32, num_samples)
+	}
+	for ch := range pcm {
+		l, r := coefs[ch][0], coefs[ch][1]
+		if channels == 1 {
+			l, r = (l+r)/2, 0
+		}
+		if l == 0 && r == 0 {
+			continue
+		}
+		for i, s := range pcm[ch] {
+			out[0][i] += float32(l * float64(s))
+			if channels == 2 {
+				out[1][i] += float32(r * float64(s))
+			}
+		}
+	}
+	return out, nil
+}
+
+// Copies out the channels of the mixdown element with the given id and tag,
+// nil if the frame doesn't carry it
+func mixdown_element(pcm [][]float32, positions []channel_position, id uint8, tag uint8) [][]float32 {
+	var out [][]float32
+	for ch, p := range positions {
+		if p.Id_syn_ele == id && p.Tag == tag {
+			out = append(out, append([]float32(nil), pcm[ch]...))
+		}
+	}
+	return out
+}
+
+// Matrix-mixdown of a 3/2 layout, 4.5.1.2.2.  Returns false when the layout
+// isn't 3/2 (with an optional LFE) and the coefficients don't apply.
+func matrix_mixdown_coefficients(positions []channel_position, pce *program_config_element, coefs [][2]float64) bool {
+	var num_front_center, num_front, num_surround int
+	for _, p := range positions {
+		switch p.Label {
+		case CHANNEL_FC:
+			num_front_center++
+		case CHANNEL_FL, CHANNEL_FR:
+			num_front++
+		case CHANNEL_SL, CHANNEL_SR, CHANNEL_BL, CHANNEL_BR:
+			num_surround++
+		case CHANNEL_LFE, CHANNEL_UNKNOWN:
+		default:
+			return false
+		}
+	}
+	if num_front_center != 1 || num_front != 2 || num_surround != 2 {
+		return false
+	}
+
+	a := matrix_mixdown_coefficient[pce.Matrix_mixdown_idx]
+	var norm, left_surround, right_surround float64
+	if pce.Pseudo_surround_enable {
+		// L' = (L + C/sqrt(2) - a*(Ls+Rs)) / (1 + 1/sqrt(2) + 2a)
+		// R' = (R + C/sqrt(2) + a*(Ls+Rs)) / (1 + 1/sqrt(2) + 2a)
+		norm = 1 / (1 + 1/math.Sqrt2 + 2*a)
+		left_surround, right_surround = -a, a
+	} else {
+		// L' = (L + C/sqrt(2) + a*Ls) / (1 + 1/sqrt(2) + a)
+		// R' = (R + C/sqrt(2) + a*Rs) / (1 + 1/sqrt(2) + a)
+		norm = 1 / (1 + 1/math.Sqrt2 + a)
+	}
+
+	for ch, p := range positions {
+		switch p.Label {
+		case CHANNEL_FL:
+			coefs[ch] = [2]float64{norm, 0}
+		case CHANNEL_FR:
+			coefs[ch] = [2]float64{0, norm}
+		case CHANNEL_FC:
+			coefs[ch] = [2]float64{norm / math.Sqrt2, norm / math.Sqrt2}
+		case CHANNEL_SL, CHANNEL_BL:
+			if pce.Pseudo_surround_enable {
+				coefs[ch] = [2]float64{norm * left_surround, norm * right_surround}
+			} else {
+				coefs[ch] = [2]float64{norm * a, 0}
+			}
+		case CHANNEL_SR, CHANNEL_BR:
+			if pce.Pseudo_surround_enable {
+				coefs[ch] = [2]float64{norm * left_surround, norm * right_surround}
+			} else {
+				coefs[ch] = [2]float64{0, norm * a}
+			}
+		default:
+			coefs[ch] = [2]float64{0, 0}
+		}
+	}
+	return true
+}
+
+// ITU-R BS.775 gains, normalized so that full scale on every channel can't
+// clip the outputs
+func bs775_coefficients(positions []channel_position, coefs [][2]float64) {
+	var sum [2]float64
+	for ch, p := range positions {
+		coefs[ch] = bs775_downmix_coefficients[p.Label]
+		sum[0] += coefs[ch][0]
+		sum[1] += coefs[ch][1]
+	}
+	for ch := range coefs {
+		for i := range sum {
+			if sum[i] > 0 {
+				coefs[ch][i] /= sum[i]
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"math"
+	"testing"
+)
+
+// One full scale sample on every channel
+func unitChannels(n int) [][]float32 {
+	pcm := make([][]float32, n)
+	for ch := range pcm {
+		pcm[ch] = []float32{1}
+	}
+	return pcm
+}
+
+func approx(a float32, b float64) bool {
+	return math.Abs(float64(a)-b) < 1e-6
+}
+
+func TestDownmixBS775(t *testing.T) {
+	adts := &ADTS{ChannelConfiguration: 6}
+
+	// FC FL FR BL BR LFE, full scale everywhere must not clip
+	out, err := adts.Downmix(unitChannels(6), 2)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("output channels (%d) must be 2", len(out))
+	}
+	if !approx(out[0][0], 1) || !approx(out[1][0], 1) {
+		t.Errorf("full scale downmix (%f, %f) must be (1, 1)", out[0][0], out[1][0])
+	}
+
+	// Center alone lands equally in both outputs at -3dB relative to L
+	pcm := [][]float32{{1}, {0}, {0}, {0}, {0}, {1}}
+	out, _ = adts.Downmix(pcm, 2)
+	norm := 1 / (1 + math.Sqrt2)
+	if !approx(out[0][0], norm/math.Sqrt2) || !approx(out[1][0], norm/math.Sqrt2) {
+		t.Errorf("center downmix (%f, %f) must be %f", out[0][0], out[1][0], norm/math.Sqrt2)
+	}
+
+	out, _ = adts.Downmix(unitChannels(6), 1)
+	if len(out) != 1 || !approx(out[0][0], 1) {
+		t.Errorf("full scale mono downmix must be 1")
+	}
+
+	if _, err := adts.Downmix(unitChannels(2), 2); err == nil {
+		t.Errorf("channel count mismatch must return an error")
+	}
+	if _, err := adts.Downmix(unitChannels(6), 6); err == nil {
+		t.Errorf("downmix to 6 channels must return an error")
+	}
+}
+
+func TestDownmixMatrixMixdown(t *testing.T) {
+	pce := &program_config_element{
+		Num_front_channel_elements: 2,
+		Num_back_channel_elements:  1,
+		Front_element_is_cpe:       []bool{false, true},
+		Front_element_tag_select:   []uint8{0, 0},
+		Back_element_is_cpe:        []bool{true},
+		Back_element_tag_select:    []uint8{1},
+		Matrix_mixdown_idx_present: true,
+		Matrix_mixdown_idx:         1,
+	}
+	adts := &ADTS{Program_config_elements: []*program_config_element{pce}}
+
+	// FC FL FR BL BR with only the left surround active
+	pcm := [][]float32{{0}, {0}, {0}, {1}, {0}}
+	out, err := adts.Downmix(pcm, 2)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	a := 0.5
+	if want := a / (1 + 1/math.Sqrt2 + a); !approx(out[0][0], want) || !approx(out[1][0], 0) {
+		t.Errorf("matrix mixdown (%f, %f) must be (%f, 0)", out[0][0], out[1][0], want)
+	}
+
+	pce.Pseudo_surround_enable = true
+	out, _ = adts.Downmix(pcm, 2)
+	if want := a / (1 + 1/math.Sqrt2 + 2*a); !approx(out[0][0], -want) || !approx(out[1][0], want) {
+		t.Errorf("pseudo surround mixdown (%f, %f) must be (%f, %f)", out[0][0], out[1][0], -want, want)
+	}
+}
+
+func TestDownmixStereoMixdownElement(t *testing.T) {
+	pce := &program_config_element{
+		Num_front_channel_elements: 2,
+		Num_back_channel_elements:  1,
+		Front_element_is_cpe:       []bool{false, true},
+		Front_element_tag_select:   []uint8{0, 0},
+		Back_element_is_cpe:        []bool{true},
+		Back_element_tag_select:    []uint8{1},
+		Stereo_mixdown_present:     true,
+		Stereo_mixdown_element_num: 2,
+	}
+	adts := &ADTS{
+		Program_config_elements: []*program_config_element{pce},
+		Raw_data_blocks: []*raw_data_block{{
+			Elements: []Element{
+				pce,
+				&single_channel_element{Element_instance_tag: 0},
+				&channel_pair_element{Element_instance_tag: 0},
+				&channel_pair_element{Element_instance_tag: 1},
+				&channel_pair_element{Element_instance_tag: 2},
+				&end_element{},
+			},
+		}},
+	}
+
+	pcm := [][]float32{{1}, {1}, {1}, {1}, {1}, {0.25}, {0.75}}
+	out, err := adts.Downmix(pcm, 2)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	if !approx(out[0][0], 0.25) || !approx(out[1][0], 0.75) {
+		t.Errorf("stereo mixdown element (%f, %f) must be (0.25, 0.75)", out[0][0], out[1][0])
+	}
+
+	// Mono requested without a mono mixdown element, the stereo mixdown
+	// element must not leak into the BS.775 mix
+	out, _ = adts.Downmix(pcm, 1)
+	if !approx(out[0][0], 1) {
+		t.Errorf("mono downmix (%f) must be 1", out[0][0])
+	}
+}
@@ -0,0 +1,163 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"fmt"
+	"math"
+)
+
+// Reference level DRC gains are normalized to when no target is given,
+// -20 dB in the 0.25 dB steps of prog_ref_level
+const DRC_REF_LEVEL = 80
+
+// Dynamic range control settings, 4.5.2.7
+type DRC struct {
+	// Fraction (0..1) of the signaled compression (dyn_rng_sgn == 1) to apply
+	Cut float64
+	// Fraction (0..1) of the signaled boost (dyn_rng_sgn == 0) to apply
+	Boost float64
+	// Target reference level in 0.25 dB steps below full scale, like
+	// prog_ref_level
+	Target_level uint8
+}
+
+// Full compression and boost normalized to DRC_REF_LEVEL, which is what
+// night mode listening wants
+func NewDRC() *DRC {
+	return &DRC{Cut: 1, Boost: 1, Target_level: DRC_REF_LEVEL}
+}
+
+// Applies the dynamic range control of a raw_data_block to its dequantized
+// spectral coefficients, spec[channel][line] in ChannelLayout order.  Gains
+// are scaled by the DRC cut and boost factors, channels flagged in
+// excluded_channels() are left alone, and DRC addressed to another program
+// via pce_instance_tag is ignored.
+func (adts *ADTS) ApplyDRC(drc *DRC, block int, spec [][]float32) error {
+	if block < 0 || block >= len(adts.Raw_data_blocks) {
+		return fmt.Errorf("Error: raw_data_block (%d) out of range", block)
+	}
+	positions, err := adts.block_channel_positions(block)
+	if err != nil {
+		return err
+	}
+	if len(spec) != len(positions) {
+		return fmt.Errorf("Error: spec has %d channels, layout has %d", len(spec), len(positions))
+	}
+
+	var streams []*individual_channel_stream
+	var infos []*dynamic_range_info
+	for _, e := range adts.Raw_data_blocks[block].Elements {
+		streams = append(streams, channel_streams(e)...)
+		if fil, ok := e.(*fill_element); ok && fil.Extension_payload != nil &&
+			fil.Extension_payload.Dynamic_range_info != nil {
+			infos = append(infos, fil.Extension_payload.Dynamic_range_info)
+		}
+	}
+
+	for _, info := range infos {
+		if !adts.drc_applies_to_program(info) {
+			continue
+		}
+		for ch := range spec {
+			if info.excluded(ch) {
+				continue
+			}
+			window_sequence := uint8(ONLY_LONG_SEQUENCE)
+			if ch < len(streams) && streams[ch] != nil && streams[ch].Ics_info != nil {
+				window_sequence = streams[ch].Ics_info.Window_sequence
+			}
+			drc.apply(info, window_sequence, spec[ch])
+		}
+	}
+	return nil
+}
+
+// DRC with a pce_instance_tag only applies to the program of that PCE.
+// Frames using a fixed channel configuration are program 0.
+func (adts *ADTS) drc_applies_to_program(info *dynamic_range_info) bool {
+	if !info.Pce_tag_present {
+		return true
+	}
+	if len(adts.Program_config_elements) == 0 {
+		return info.Pce_instance_tag == 0
+	}
+	for _, pce := range adts.Program_config_elements {
+		if pce.Element_instance_tag == info.Pce_instance_tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (info *dynamic_range_info) excluded(ch int) bool {
+	if !info.Excluded_chns_present || info.Excluded_chns == nil {
+		return false
+	}
+	return ch < len(info.Excluded_chns.Exclude_mask) && info.Excluded_chns.Exclude_mask[ch]
+}
+
+// Gain factor of a DRC band, level normalization to the target included.
+// Both dyn_rng_ctl and the reference levels are in 0.25 dB steps, so 24
+// steps double or halve the amplitude.
+func (drc *DRC) band_gain(info *dynamic_range_info, band int) float64 {
+	prog_ref_level := float64(drc.Target_level)
+	if info.Prog_ref_level_present {
+		prog_ref_level = float64(info.Prog_ref_level)
+	}
+
+	ctl := float64(info.Dyn_range_cnt[band])
+	if info.Dyn_range_sign[band] == 1 {
+		ctl *= -drc.Cut
+	} else {
+		ctl *= drc.Boost
+	}
+	return math.Pow(2, (ctl-(float64(drc.Target_level)-prog_ref_level))/24)
+}
+
+// Scales the lines of each DRC band.  drc_band_top[] is in units of 4 long
+// window lines, short windows use the same frequency split.
+func (drc *DRC) apply(info *dynamic_range_info, window_sequence uint8, spec []float32) {
+	num_windows := 1
+	if window_sequence == EIGHT_SHORT_SEQUENCE {
+		num_windows = 8
+	}
+	frame_length := len(spec)
+	window_length := frame_length / num_windows
+
+	bottom := 0
+	for band := range info.Dyn_range_cnt {
+		top := frame_length
+		if band < len(info.Drc_band_top) {
+			top = 4 * (int(info.Drc_band_top[band]) + 1)
+		}
+		top = minInt(top, frame_length)
+		if top <= bottom {
+			continue
+		}
+
+		gain := float32(drc.band_gain(info, band))
+		for w := 0; w < num_windows; w++ {
+			start := w*window_length + bottom/num_windows
+			end := w*window_length + top/num_windows
+			for i := start; i < end; i++ {
+				spec[i] *= gain
+			}
+		}
+		bottom = top
+	}
+}
@@ -0,0 +1,183 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func drcTestFrame(info *dynamic_range_info, window_sequence uint8) *ADTS {
+	ics := &ics_info{Window_sequence: window_sequence}
+	return &ADTS{
+		ChannelConfiguration: 2,
+		Raw_data_blocks: []*raw_data_block{{
+			Elements: []Element{
+				&channel_pair_element{
+					Common_window:   true,
+					Ics_info:        ics,
+					Channel_stream1: &individual_channel_stream{Ics_info: ics},
+					Channel_stream2: &individual_channel_stream{Ics_info: ics},
+				},
+				&fill_element{Extension_payload: &extension_payload{
+					Extension_type:     EXT_DYNAMIC_RANGE,
+					Dynamic_range_info: info,
+				}},
+				&end_element{},
+			},
+		}},
+	}
+}
+
+func flatSpectrum(channels int) [][]float32 {
+	spec := make([][]float32, channels)
+	for ch := range spec {
+		spec[ch] = make([]float32, 1024)
+		for i := range spec[ch] {
+			spec[ch][i] = 1
+		}
+	}
+	return spec
+}
+
+func TestApplyDRC(t *testing.T) {
+	// Two bands split at line 256, 6 dB cut below and 6 dB boost above,
+	// right channel excluded
+	info := &dynamic_range_info{
+		Excluded_chns_present: true,
+		Excluded_chns:         &excluded_channels{Exclude_mask: []bool{false, true, false, false, false, false, false}},
+		Drc_bands_present:     true,
+		Drc_band_incr:         1,
+		Drc_band_top:          []byte{63, 255},
+		Dyn_range_sign:        []uint8{1, 0},
+		Dyn_range_cnt:         []uint8{24, 24},
+	}
+	adts := drcTestFrame(info, ONLY_LONG_SEQUENCE)
+
+	spec := flatSpectrum(2)
+	if err := adts.ApplyDRC(NewDRC(), 0, spec); err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	if !approx(spec[0][0], 0.5) || !approx(spec[0][255], 0.5) {
+		t.Errorf("cut band (%f) must be 0.5", spec[0][0])
+	}
+	if !approx(spec[0][256], 2) || !approx(spec[0][1023], 2) {
+		t.Errorf("boost band (%f) must be 2", spec[0][256])
+	}
+	if !approx(spec[1][0], 1) || !approx(spec[1][1023], 1) {
+		t.Errorf("excluded channel (%f) must be untouched", spec[1][0])
+	}
+
+	// Half the cut, no boost
+	spec = flatSpectrum(2)
+	adts.ApplyDRC(&DRC{Cut: 0.5, Boost: 0, Target_level: DRC_REF_LEVEL}, 0, spec)
+	if !approx(spec[0][0], math.Pow(2, -0.5)) || !approx(spec[0][512], 1) {
+		t.Errorf("scaled gains (%f, %f) must be (%f, 1)", spec[0][0], spec[0][512], math.Pow(2, -0.5))
+	}
+
+	// A program at -26 dB is brought up 6 dB to the -20 dB target
+	info.Prog_ref_level_present = true
+	info.Prog_ref_level = 104
+	spec = flatSpectrum(2)
+	adts.ApplyDRC(&DRC{Target_level: DRC_REF_LEVEL}, 0, spec)
+	if !approx(spec[0][0], 2) || !approx(spec[0][1023], 2) {
+		t.Errorf("level normalization (%f) must be 2", spec[0][0])
+	}
+
+	// DRC for another program is ignored
+	info.Pce_tag_present = true
+	info.Pce_instance_tag = 3
+	spec = flatSpectrum(2)
+	adts.ApplyDRC(NewDRC(), 0, spec)
+	if !approx(spec[0][0], 1) {
+		t.Errorf("DRC for another program (%f) must not apply", spec[0][0])
+	}
+
+	if err := adts.ApplyDRC(NewDRC(), 0, flatSpectrum(1)); err == nil {
+		t.Errorf("channel count mismatch must return an error")
+	}
+}
+
+func TestApplyDRCShortWindows(t *testing.T) {
+	info := &dynamic_range_info{
+		Drc_bands_present: true,
+		Drc_band_incr:     1,
+		Drc_band_top:      []byte{63, 255},
+		Dyn_range_sign:    []uint8{1, 0},
+		Dyn_range_cnt:     []uint8{24, 0},
+	}
+	adts := drcTestFrame(info, EIGHT_SHORT_SEQUENCE)
+
+	spec := flatSpectrum(2)
+	adts.ApplyDRC(NewDRC(), 0, spec)
+	for w := 0; w < 8; w++ {
+		if !approx(spec[0][w*128], 0.5) || !approx(spec[0][w*128+31], 0.5) {
+			t.Errorf("window %d low band (%f) must be 0.5", w, spec[0][w*128])
+		}
+		if !approx(spec[0][w*128+32], 1) {
+			t.Errorf("window %d high band (%f) must be 1", w, spec[0][w*128+32])
+		}
+	}
+}
+
+// Every byte of dynamic_range_info() counts against the fill_element, a
+// short count would parse another extension_payload from the ID_END
+func TestParseDRCBands(t *testing.T) {
+	buf := adtsFrame(1, 3, 2, func(w *bitWriter) {
+		w.write(ID_FIL, 3)
+		w.write(8, 4) // count
+		w.write(EXT_DYNAMIC_RANGE, 4)
+		w.write(1, 1)   // pce_tag_present
+		w.write(5, 4)   // pce_instance_tag
+		w.write(0, 4)   // drc_tag_reserved_bits
+		w.write(0, 1)   // excluded_chns_present
+		w.write(1, 1)   // drc_bands_present
+		w.write(1, 4)   // drc_band_incr
+		w.write(0, 4)   // drc_interpolation_scheme
+		w.write(63, 8)  // drc_band_top[0]
+		w.write(255, 8) // drc_band_top[1]
+		w.write(1, 1)   // prog_ref_level_present
+		w.write(80, 7)  // prog_ref_level
+		w.write(0, 1)   // prog_ref_level_reserved_bits
+		w.write(1, 1)   // dyn_rng_sgn[0]
+		w.write(24, 7)  // dyn_rng_ctl[0]
+		w.write(0, 1)   // dyn_rng_sgn[1]
+		w.write(12, 7)  // dyn_rng_ctl[1]
+	})
+
+	adts, err := ParseADTS(buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err.Error())
+	}
+	if len(adts.Fill_elements) != 1 || adts.Fill_elements[0].Extension_payload == nil {
+		t.Fatalf("there must be 1 fill_element with an extension_payload")
+	}
+	info := adts.Fill_elements[0].Extension_payload.Dynamic_range_info
+	if info == nil {
+		t.Fatalf("Dynamic_range_info must not be nil")
+	}
+	if info.Pce_instance_tag != 5 || info.Prog_ref_level != 80 {
+		t.Errorf("pce_instance_tag/prog_ref_level (%d, %d) must be (5, 80)", info.Pce_instance_tag, info.Prog_ref_level)
+	}
+	if !reflect.DeepEqual(info.Drc_band_top, []uint8{63, 255}) {
+		t.Errorf("Drc_band_top (%v) must be [63 255]", info.Drc_band_top)
+	}
+	if !reflect.DeepEqual(info.Dyn_range_sign, []uint8{1, 0}) || !reflect.DeepEqual(info.Dyn_range_cnt, []uint8{24, 12}) {
+		t.Errorf("dyn_rng_sgn/dyn_rng_ctl (%v, %v) must be ([1 0], [24 12])", info.Dyn_range_sign, info.Dyn_range_cnt)
+	}
+}
@@ -0,0 +1,203 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Comcast/gaad/bitreader"
+)
+
+// Categories of a ParseError, test for them with errors.Is
+var (
+	// ErrTruncated is the category of a frame that ends inside a syntax
+	// element, the ParseError also matches bitreader.ErrReaderOutOfBounds
+	ErrTruncated = errors.New("truncated")
+	// ErrOutOfRange is the category of a value the syntax doesn't allow or
+	// a codeword that isn't in its codebook
+	ErrOutOfRange = errors.New("out of range")
+	// ErrUnsupported is the category of valid syntax the parser doesn't
+	// implement
+	ErrUnsupported = errors.New("unsupported")
+	// ErrReserved is the category of a reserved bit or value that is set
+	ErrReserved = errors.New("reserved bit set")
+)
+
+// ParseError is returned by the parse functions for a frame or config that
+// can't be parsed
+type ParseError struct {
+	// The spec table of the syntax element, e.g. "Table 4.52 section_data"
+	Table string
+	// The syntax element within the frame, e.g.
+	// "raw_data_block[0]/CPE[1]/ics[1]/section_data"
+	Path string
+	// The bit the reader had reached when the error was found, for a
+	// truncated frame the start of the read that ran out, counted from the
+	// start of the data
+	Offset uint64
+	// One of ErrTruncated, ErrOutOfRange, ErrUnsupported or ErrReserved
+	Kind error
+	// What went wrong
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Error: %s (%s) at bit %d: %v: %s", e.Path, e.Table, e.Offset, e.Kind,
+		strings.TrimPrefix(e.Err.Error(), "Error: "))
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the category of e
+func (e *ParseError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// An error of the given category, check places it in the frame
+func parseErrorf(kind error, format string, a ...interface{}) error {
+	return &ParseError{Kind: kind, Err: fmt.Errorf(format, a...)}
+}
+
+// Spec tables of the syntax elements passed to check
+var syntaxTables = map[string]string{
+	"AudioSpecificConfig":             "Table 1.15",
+	"adts_frame":                      "Table 1.A.5",
+	"adts_fixed_header":               "Table 1.A.6",
+	"adts_variable_header":            "Table 1.A.7",
+	"adts_error_check":                "Table 1.A.8",
+	"adts_header_error_check":         "Table 1.A.9",
+	"adts_raw_data_block_error_check": "Table 1.A.10",
+	"GASpecificConfig":                "Table 4.1",
+	"program_config_element":          "Table 4.2",
+	"raw_data_block":                  "Table 4.3",
+	"single_channel_element":          "Table 4.4",
+	"channel_pair_element":            "Table 4.5",
+	"ics_info":                        "Table 4.6",
+	"pulse_data":                      "Table 4.7",
+	"coupling_channel_element":        "Table 4.8",
+	"lfe_channel_element":             "Table 4.9",
+	"data_stream_element":             "Table 4.10",
+	"fill_element":                    "Table 4.11",
+	"gain_control_data":               "Table 4.12",
+	"individual_channel_stream":       "Table 4.50",
+	"reordered_spectral_data":         "Table 4.51",
+	"section_data":                    "Table 4.52",
+	"scale_factor_data":               "Table 4.53",
+	"tns_data":                        "Table 4.54",
+	"ltp_data":                        "Table 4.55",
+	"spectral_data":                   "Table 4.56",
+	"extension_payload":               "Table 4.57",
+	"dynamic_range_info":              "Table 4.58",
+	"sac_extension_data":              "Table 4.61",
+	"sbr_extension_data":              "Table 4.62",
+	"sbr_header":                      "Table 4.63",
+	"sbr_data":                        "Table 4.64",
+	"sbr_single_channel_element":      "Table 4.65",
+	"sbr_channel_pair_element":        "Table 4.66",
+	"sbr_channel_pair_base_element":   "Table 4.67",
+	"sbr_grid":                        "Table 4.69",
+	"sbr_extension":                   "Table 8.A.1",
+}
+
+// The syntax element's error, or the first read past the end of the data,
+// as a ParseError placed at the element.  Fields read past the end are
+// zero, so whatever error they lead to is only misleading.  Errors already
+// placed by an element further down are returned as they are.
+func (adts *ADTS) check(element string, err error) error {
+	if err == nil && adts.reader.Err() == nil {
+		return nil
+	}
+
+	var perr *ParseError
+	if errors.As(err, &perr) && perr.Path != "" {
+		return err
+	}
+
+	if rerr := adts.reader.Err(); rerr != nil {
+		perr = &ParseError{Kind: ErrTruncated, Err: rerr, Offset: adts.reader.ErrOffset()}
+	} else if err == nil {
+		return nil
+	} else if perr == nil {
+		perr = &ParseError{Kind: ErrOutOfRange, Err: err}
+	}
+	if perr.Offset == 0 {
+		perr.Offset = adts.reader.BitPos()
+	}
+
+	// Elements that are counted, like raw_data_block[0], are named with
+	// their index in the path
+	name := element
+	if i := strings.IndexByte(element, '['); i >= 0 {
+		name = element[:i]
+	}
+	perr.Path = element
+	perr.Table = name
+	if table, ok := syntaxTables[name]; ok {
+		perr.Table = table + " " + name
+	}
+	return perr
+}
+
+// Runs parse with the reader bounded to the next n bits, or the rest of the
+// data if it's shorter, and moves on to where it stopped.  A read past the
+// bound fails as ErrOutOfRange rather than read into the elements after it.
+func (adts *ADTS) limit(n uint, parse func() error) error {
+	reader := adts.reader
+	bounded := n <= reader.BitsLeft()
+	if !bounded {
+		n = reader.BitsLeft()
+	}
+	limited := adts.mem.limited_readers.new()
+	*limited = *bitreader.NewLimitedReader(reader, n)
+	adts.reader = limited
+	err := parse()
+	adts.reader = reader
+
+	if bounded && limited.Err() != nil {
+		var perr *ParseError
+		if errors.As(err, &perr) && perr.Kind == ErrTruncated {
+			perr.Kind = ErrOutOfRange
+			perr.Err = fmt.Errorf("Error: read past the %d bits the element is limited to", n)
+		}
+	}
+	return err
+}
+
+// Adds the element containing the syntax element of a ParseError to its
+// path, e.g. which channel of a channel_pair_element() it is in
+func within(element string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var perr *ParseError
+	if errors.As(err, &perr) && perr.Path != "" {
+		perr.Path = element + "/" + perr.Path
+	}
+	return err
+}
+
+// The name of an element within its raw_data_block(), indexed by how many
+// elements of its type came before it
+func elementPath(id_syn_ele uint8, index int) string {
+	names := [...]string{"SCE", "CPE", "CCE", "LFE", "DSE", "PCE", "FIL", "END"}
+	return fmt.Sprintf("%s[%d]", names[id_syn_ele&7], index)
+}
@@ -0,0 +1,132 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"errors"
+	"testing"
+)
+
+// individual_channel_stream() of a long window with 2 ZERO_HCB bands, not
+// sharing its ics_info()
+func writeLcIcs(w *bitWriter, ics_reserved_bit uint64) {
+	w.write(100, 8) // global_gain
+	w.write(ics_reserved_bit, 1)
+	w.write(ONLY_LONG_SEQUENCE, 2)
+	w.write(0, 1) // window_shape
+	w.write(2, 6) // max_sfb
+	w.write(0, 1) // predictor_data_present
+	w.write(ZERO_HCB, 4)
+	w.write(2, 5) // sect_len
+	w.write(0, 1) // pulse_data_present
+	w.write(0, 1) // tns_data_present
+	w.write(0, 1) // gain_control_data_present
+}
+
+func checkParseError(t *testing.T, err error, kind error, table string, path string) *ParseError {
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("err (%v) must be a ParseError", err)
+	}
+	if !errors.Is(err, kind) {
+		t.Errorf("err (%v) must be of category %v", err, kind)
+	}
+	for _, other := range []error{ErrTruncated, ErrOutOfRange, ErrUnsupported, ErrReserved} {
+		if other != kind && errors.Is(err, other) {
+			t.Errorf("err (%v) must not be of category %v", err, other)
+		}
+	}
+	if perr.Table != table {
+		t.Errorf("Table (%s) must be %s", perr.Table, table)
+	}
+	if perr.Path != path {
+		t.Errorf("Path (%s) must be %s", perr.Path, path)
+	}
+	return perr
+}
+
+func TestParseErrorPath(t *testing.T) {
+	buf := adtsFrame(1, 3, 2, func(w *bitWriter) {
+		for i := 0; i < 2; i++ {
+			w.write(ID_CPE, 3)
+			w.write(0, 4) // element_instance_tag
+			w.write(0, 1) // common_window
+			writeLcIcs(w, 0)
+			writeLcIcs(w, uint64(i))
+		}
+	})
+
+	_, err := ParseADTS(buf)
+	perr := checkParseError(t, err, ErrReserved, "Table 4.6 ics_info", "raw_data_block[0]/CPE[1]/ics[1]/ics_info")
+
+	// The header and the first CPE take 56+70 bits, the error is found
+	// after reading the second channel's ics_reserved_bit 8+31+8 bits on
+	if perr.Offset != 56+70+8+31+8+1 {
+		t.Errorf("Offset (%d) must be %d, after the ics_reserved_bit", perr.Offset, 56+70+8+31+8+1)
+	}
+}
+
+func TestParseErrorReserved(t *testing.T) {
+	buf := adtsFrame(1, 13, 2, func(w *bitWriter) {})
+
+	_, err := ParseADTS(buf)
+	perr := checkParseError(t, err, ErrReserved, "Table 1.A.6 adts_fixed_header", "adts_fixed_header")
+	if perr.Offset != 22 {
+		t.Errorf("Offset (%d) must be 22, after sampling_frequency_index", perr.Offset)
+	}
+}
+
+func TestParseErrorUnsupported(t *testing.T) {
+	// audioObjectType 8 (CELP), 44.1 kHz, 1 channel
+	_, err := ParseAudioSpecificConfig([]byte{0x42, 0x08})
+	checkParseError(t, err, ErrUnsupported, "Table 1.15 AudioSpecificConfig", "AudioSpecificConfig")
+}
+
+func TestParseErrorTruncated(t *testing.T) {
+	buf := adtsFrame(1, 3, 2, func(w *bitWriter) {
+		w.write(ID_CPE, 3)
+		w.write(0, 4) // element_instance_tag
+		w.write(0, 1) // common_window
+		writeLcIcs(w, 0)
+		writeLcIcs(w, 0)
+	})
+
+	// Cut inside the second channel's sect_len, which starts at bit
+	// 56+8+31+8+11+4
+	_, err := ParseADTS(buf[:15])
+	perr := checkParseError(t, err, ErrTruncated, "Table 4.52 section_data", "raw_data_block[0]/CPE[0]/ics[1]/section_data")
+	if perr.Offset != 118 {
+		t.Errorf("Offset (%d) must be 118, the start of sect_len", perr.Offset)
+	}
+}
+
+func TestParseErrorLimit(t *testing.T) {
+	buf := adtsFrame(1, 3, 1, func(w *bitWriter) {
+		w.write(ID_FIL, 3)
+		w.write(1, 4) // count
+		w.write(EXT_DYNAMIC_RANGE, 4)
+		w.write(0, 4) // no tags, excluded channels, bands or reference level
+	})
+
+	// dyn_rng_sgn and dyn_rng_ctl are past the 1 byte of the fill_element,
+	// they'd be read from the ID_END after it
+	_, err := ParseADTS(buf)
+	perr := checkParseError(t, err, ErrOutOfRange, "Table 4.58 dynamic_range_info", "raw_data_block[0]/FIL[0]/dynamic_range_info")
+	if perr.Offset != 56+3+4+8 {
+		t.Errorf("Offset (%d) must be %d, the end of the fill_element", perr.Offset, 56+3+4+8)
+	}
+}
@@ -0,0 +1,225 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// window_shape values
+const (
+	SINE_WINDOW = 0
+	KBD_WINDOW  = 1
+)
+
+// Rising half of the sine window of a 2*n sample transform
+func sine_window(n int) []float32 {
+	w := make([]float32, n)
+	for i := range w {
+		w[i] = float32(math.Sin(math.Pi / float64(2*n) * (float64(i) + 0.5)))
+	}
+	return w
+}
+
+// Rising half of the Kaiser-Bessel derived window of a 2*n sample
+// transform.  alpha is 4 for long and 6 for short windows.
+func kbd_window(n int, alpha float64) []float32 {
+	kernel := make([]float64, n+1)
+	sum := 0.0
+	for i := range kernel {
+		x := (float64(i) - float64(n)/2) / (float64(n) / 2)
+		kernel[i] = bessel_i0(math.Pi * alpha * math.Sqrt(1-x*x))
+		sum += kernel[i]
+	}
+
+	w := make([]float32, n)
+	acc := 0.0
+	for i := range w {
+		acc += kernel[i]
+		w[i] = float32(math.Sqrt(acc / sum))
+	}
+	return w
+}
+
+// Zeroth order modified Bessel function of the first kind
+func bessel_i0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < sum*1e-12 {
+			break
+		}
+	}
+	return sum
+}
+
+// Long (frame_length) and short (frame_length/8) rising window halves,
+// indexed by window_shape
+type filterbank_windows struct {
+	long  [2][]float32
+	short [2][]float32
+}
+
+func new_filterbank_windows(frame_length int) *filterbank_windows {
+	return &filterbank_windows{
+		long:  [2][]float32{sine_window(frame_length), kbd_window(frame_length, 4)},
+		short: [2][]float32{sine_window(frame_length / 8), kbd_window(frame_length/8, 6)},
+	}
+}
+
+// Windows 2*frame_length time samples for a long window_sequence the way
+// the synthesis filterbank does, previous frame's shape on the rising edge
+func (fw *filterbank_windows) window(window_sequence uint8, window_shape uint8, window_shape_prev uint8, in []float32, out []float32) {
+	nlong := len(fw.long[0])
+	nshort := len(fw.short[0])
+	nflat_ls := (nlong - nshort) / 2
+	long, long_prev := fw.long[window_shape&1], fw.long[window_shape_prev&1]
+	short, short_prev := fw.short[window_shape&1], fw.short[window_shape_prev&1]
+
+	switch window_sequence {
+	case LONG_START_SEQUENCE:
+		for i := 0; i < nlong; i++ {
+			out[i] = in[i] * long_prev[i]
+		}
+		for i := 0; i < nflat_ls; i++ {
+			out[i+nlong] = in[i+nlong]
+		}
+		for i := 0; i < nshort; i++ {
+			out[i+nlong+nflat_ls] = in[i+nlong+nflat_ls] * short[nshort-1-i]
+		}
+		for i := 0; i < nflat_ls; i++ {
+			out[i+nlong+nflat_ls+nshort] = 0
+		}
+	case LONG_STOP_SEQUENCE:
+		for i := 0; i < nflat_ls; i++ {
+			out[i] = 0
+		}
+		for i := 0; i < nshort; i++ {
+			out[i+nflat_ls] = in[i+nflat_ls] * short_prev[i]
+		}
+		for i := 0; i < nflat_ls; i++ {
+			out[i+nflat_ls+nshort] = in[i+nflat_ls+nshort]
+		}
+		for i := 0; i < nlong; i++ {
+			out[i+nlong] = in[i+nlong] * long[nlong-1-i]
+		}
+	default:
+		for i := 0; i < nlong; i++ {
+			out[i] = in[i] * long_prev[i]
+			out[i+nlong] = in[i+nlong] * long[nlong-1-i]
+		}
+	}
+}
+
+// Forward MDCT of len(in) = 2N samples into N coefficients,
+//
+//	X[k] = 2 * sum(x[n] * cos(2*pi/2N * (n + n0) * (k + 1/2))), n0 = N/2 + 1/2
+//
+// which imdct inverts.  The input is folded into a DCT-IV.
+func mdct(in []float32, out []float32) {
+	n := len(in) / 2
+	h := n / 2
+
+	// (a, b, c, d) folds to (-c_r - d, a - b_r)
+	u := make([]float64, n)
+	for i := 0; i < h; i++ {
+		u[i] = -float64(in[3*h-1-i]) - float64(in[3*h+i])
+		u[h+i] = float64(in[i]) - float64(in[n-1-i])
+	}
+
+	for k, x := range dct4(u) {
+		out[k] = float32(2 * x)
+	}
+}
+
+// Synthesis IMDCT of N coefficients into 2N samples,
+//
+//	x[n] = 1/N * sum(X[k] * cos(2*pi/2N * (n + n0) * (k + 1/2))), n0 = N/2 + 1/2
+//
+// the transpose of mdct's folding applied to a DCT-IV.
+func imdct(in []float32, out []float32) {
+	n := len(in)
+	h := n / 2
+
+	u := make([]float64, n)
+	for i, x := range in {
+		u[i] = float64(x)
+	}
+	v := dct4(u)
+
+	scale := 1 / float64(n)
+	for i := 0; i < h; i++ {
+		out[i] = float32(v[h+i] * scale)
+		out[n-1-i] = float32(-v[h+i] * scale)
+		out[3*h-1-i] = float32(-v[i] * scale)
+		out[3*h+i] = float32(-v[i] * scale)
+	}
+}
+
+// DCT-IV, X[k] = sum(u[n] * cos(pi/N * (n + 1/2) * (k + 1/2))), computed
+// with an N/2 point complex FFT
+func dct4(u []float64) []float64 {
+	n := len(u)
+	h := n / 2
+
+	v := make([]complex128, h)
+	for i := range v {
+		v[i] = complex(u[2*i], u[n-1-2*i]) * cmplx.Exp(complex(0, -math.Pi*(float64(i)+0.25)/float64(n)))
+	}
+	v = fft(v)
+
+	out := make([]float64, n)
+	for k := range v {
+		y := v[k] * cmplx.Exp(complex(0, -math.Pi*float64(k)/float64(n)))
+		out[2*k] = real(y)
+		out[n-1-2*k] = -imag(y)
+	}
+	return out
+}
+
+// Radix-2 decimation in time down to an odd length, which is transformed
+// directly.  Frame lengths of 960 leave 15 point DFTs.
+func fft(x []complex128) []complex128 {
+	n := len(x)
+	if n%2 != 0 {
+		out := make([]complex128, n)
+		for k := range out {
+			for j := range x {
+				out[k] += x[j] * cmplx.Exp(complex(0, -2*math.Pi*float64(j*k%n)/float64(n)))
+			}
+		}
+		return out
+	}
+
+	even := make([]complex128, n/2)
+	odd := make([]complex128, n/2)
+	for i := 0; i < n/2; i++ {
+		even[i] = x[2*i]
+		odd[i] = x[2*i+1]
+	}
+	even, odd = fft(even), fft(odd)
+
+	out := make([]complex128, n)
+	for k := 0; k < n/2; k++ {
+		t := cmplx.Exp(complex(0, -2*math.Pi*float64(k)/float64(n))) * odd[k]
+		out[k] = even[k] + t
+		out[k+n/2] = even[k] - t
+	}
+	return out
+}
@@ -0,0 +1,276 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"fmt"
+
+	"github.com/Comcast/gaad/bitreader"
+)
+
+// Limit of length_of_longest_codeword, the longest escape codeword
+const HCR_MAX_CODEWORD_LENGTH = 49
+
+// Longest codeword of each codebook including sign and escape bits, which
+// caps the segment width of its priority codewords
+var hcr_max_codeword_length = [...]uint8{
+	0, 11, 9, 20, 16, 13, 11, 14, 12, 17, 14, 49,
+	0, 0, 0, 0,
+	14, 17, 21, 21, 25, 25, 29, 29, 29, 29, 33, 33, 33, 37, 37, 41,
+}
+
+// Codebooks in the order their codewords are sorted, each taken with the
+// unsigned pair codebook after it.  Codewords of the largest codebooks are
+// the most likely to carry energy and become priority codewords.
+var hcr_presort_cb = []uint8{
+	ESC_HCB, 31, 30, 29, 28, 27, 26, 25, 24, 23, 22, 21, 20, 19, 18, 17, 16, 9, 7, 5, 3, 1,
+}
+
+type hcr_codeword struct {
+	sect_cb uint8
+	g       uint8
+	sp      uint16
+
+	// Leading bits read from earlier segments
+	bits    []byte
+	decoded bool
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Table 4.51 – Syntax of reordered_spectral_data ()
+////////////////////////////////////////////////////////////////////////////////
+// Huffman codeword reordering (4.6.16.3).  The codewords are sorted by
+// codebook and frequency, the first one of each segment is a priority
+// codeword (PCW) at its start, and the rest fill the remaining bits of the
+// segments set by set, each set read in the opposite direction of the one
+// before it.  A codeword running out of segment continues in the next
+// segment it's tried with.  Returns the raw data and the same tuples
+// spectral_data() would.
+func (adts *ADTS) reordered_spectral_data(info *ics_info, sec_data *section_data, length uint16, longest uint8) (*reordered_spectral_data, *spectral_data, error) {
+	var err error
+	reordered := adts.mem.reordered_spectral_datas.new()
+	if reordered.Data, err = adts.read_bits_to_byte_array(uint(length)); err != nil {
+		return reordered, nil, adts.check("reordered_spectral_data", err)
+	}
+	if longest > HCR_MAX_CODEWORD_LENGTH {
+		return reordered, nil, adts.check("reordered_spectral_data", fmt.Errorf("Error: length_of_longest_codeword (%d) exceeds %d", longest, HCR_MAX_CODEWORD_LENGTH))
+	}
+
+	spectrum := adts.mem.int16s_2d.make(int(info.num_window_groups))
+	for g := range spectrum {
+		spectrum[g] = adts.mem.int16s.make(int(info.sect_sfb_offset[g][len(info.sect_sfb_offset[g])-1]))
+	}
+	err = hcr_decode(&adts.mem, info, sec_data, hcr_bits(&adts.mem, reordered.Data, uint(length)), longest, spectrum)
+	return reordered, hcr_tuples(&adts.mem, info, sec_data, spectrum), adts.check("reordered_spectral_data", err)
+}
+
+// Decodes the bits of reordered_spectral_data into spectrum, indexed like
+// sect_sfb_offset.  The codewords and segments are taken from mem.
+func hcr_decode(mem *frame_memory, info *ics_info, sec_data *section_data, bits []byte, longest uint8, spectrum [][]int16) error {
+	codewords := hcr_sort(mem, info, sec_data)
+	if len(bits) == 0 {
+		return nil
+	}
+	if longest == 0 || len(bits) < int(longest) {
+		return fmt.Errorf("Error: length_of_longest_codeword (%d) invalid for %d bits", longest, len(bits))
+	}
+
+	// Priority codewords, one at the start of each segment
+	var values [4]int16
+	var segments [][]byte
+	pos := 0
+	for len(codewords) > 0 {
+		cw := codewords[0]
+		width := int(hcr_max_codeword_length[cw.sect_cb])
+		if width > int(longest) {
+			width = int(longest)
+		}
+		if pos+width > len(bits) {
+			// The bits short of a full segment belong to the last one
+			if len(segments) > 0 {
+				last := len(segments) - 1
+				segments[last] = mem.uint8s.append(hcr_reverse(mem, bits[pos:]), segments[last]...)
+			}
+			break
+		}
+
+		segment := bits[pos : pos+width]
+		pos += width
+		if tuple, used, ok := hcr_codeword_values(mem, cw.sect_cb, segment, values[:]); ok {
+			copy(spectrum[cw.g][cw.sp:], tuple)
+			segment = segment[used:]
+		}
+		segments = mem.uint8s_2d.append(segments, hcr_reverse(mem, segment))
+		codewords = codewords[1:]
+	}
+	if len(codewords) == 0 {
+		return nil
+	}
+	if len(segments) == 0 {
+		return fmt.Errorf("Error: reordered_spectral_data has no segments")
+	}
+
+	// Non priority codewords, a set of one per segment at a time
+	n := len(segments)
+	for set := 0; set*n < len(codewords); set++ {
+		for trial := 0; trial < n; trial++ {
+			for base := 0; base < n && set*n+base < len(codewords); base++ {
+				cw := codewords[set*n+base]
+				s := (trial + base) % n
+				if cw.decoded || len(segments[s]) == 0 {
+					continue
+				}
+
+				candidate := mem.uint8s.make_cap(0, len(cw.bits)+len(segments[s]))
+				candidate = append(append(candidate, cw.bits...), segments[s]...)
+				if tuple, used, ok := hcr_codeword_values(mem, cw.sect_cb, candidate, values[:]); ok {
+					copy(spectrum[cw.g][cw.sp:], tuple)
+					cw.decoded = true
+					segments[s] = candidate[used:]
+				} else {
+					cw.bits = candidate
+					segments[s] = nil
+				}
+			}
+		}
+		for s := range segments {
+			segments[s] = hcr_reverse(mem, segments[s])
+		}
+	}
+
+	for _, cw := range codewords {
+		if !cw.decoded {
+			return fmt.Errorf("Error: HCR codeword at line %d of group %d not decoded", cw.sp, cw.g)
+		}
+	}
+	return nil
+}
+
+// Codewords of the spectral data in HCR order: by codebook, then by units of
+// four lines of every window from the lowest band up
+func hcr_sort(mem *frame_memory, info *ics_info, sec_data *section_data) []*hcr_codeword {
+	var codewords []*hcr_codeword
+	for _, cb := range hcr_presort_cb {
+		for sfb := uint8(0); sfb < info.Max_sfb; sfb++ {
+			for unit := uint16(0); ; unit++ {
+				more := false
+				for g := uint8(0); g < info.num_window_groups; g++ {
+					group_length := uint16(info.window_group_length[g])
+					width := (info.sect_sfb_offset[g][sfb+1] - info.sect_sfb_offset[g][sfb]) / group_length
+					if 4*unit >= width {
+						continue
+					}
+					more = true
+
+					sect_cb := info.sfb_cb[g][sfb]
+					if sect_cb != cb && (cb >= ESC_HCB || sect_cb != cb+1) {
+						continue
+					}
+					inc := uint16(2)
+					if sect_cb < FIRST_PAIR_HCB {
+						inc = 4
+					}
+					for w := uint16(0); w < group_length; w++ {
+						for k := uint16(0); k < 4; k += inc {
+							cw := mem.hcr_codewords.new()
+							cw.sect_cb = sect_cb
+							cw.g = g
+							cw.sp = info.sect_sfb_offset[g][sfb] + w*width + 4*unit + k
+							codewords = mem.hcr_codeword_list.append(codewords, cw)
+						}
+					}
+				}
+				if !more {
+					break
+				}
+			}
+		}
+	}
+	return codewords
+}
+
+// Decodes one codeword from the start of bits into values, which has room
+// for a quad, reading it from mem.  Fails when the codeword doesn't fit,
+// the codeword then continues in another segment.
+func hcr_codeword_values(mem *frame_memory, sect_cb uint8, bits []byte, values []int16) ([]int16, int, bool) {
+	values = values[:2]
+	if sect_cb < FIRST_PAIR_HCB {
+		values = values[:4]
+	}
+
+	// No codeword is longer than HCR_MAX_CODEWORD_LENGTH bits, the zero
+	// padding is for the 2 step lookups
+	buf := mem.uint8s.make((HCR_MAX_CODEWORD_LENGTH+7)/8 + 2)
+	n := len(bits)
+	if n > HCR_MAX_CODEWORD_LENGTH {
+		n = HCR_MAX_CODEWORD_LENGTH
+	}
+	for i, b := range bits[:n] {
+		buf[i/8] |= b << uint(7-i%8)
+	}
+	reader := mem.readers.new()
+	*reader = *bitreader.NewBitReader(buf)
+	err := hcod_values(reader, sect_cb, values)
+	used := len(buf)*8 - int(reader.BitsLeft())
+	if err != nil || used > n {
+		return nil, 0, false
+	}
+	return values, used, true
+}
+
+// The tuples of spectrum in the order of spectral_data()
+func hcr_tuples(mem *frame_memory, info *ics_info, sec_data *section_data, spectrum [][]int16) *spectral_data {
+	data := mem.spectral_datas.new()
+	for g := uint8(0); g < info.num_window_groups; g++ {
+		for i := uint8(0); i < sec_data.num_sec[g]; i++ {
+			switch sec_data.Sect_cb[g][i] {
+			case ZERO_HCB, NOISE_HCB, INTENSITY_HCB, INTENSITY_HCB2:
+				continue
+			}
+			inc := uint16(4)
+			if sec_data.Sect_cb[g][i] >= FIRST_PAIR_HCB {
+				inc = 2
+			}
+			start := info.sect_sfb_offset[g][sec_data.sect_start[g][i]]
+			end := info.sect_sfb_offset[g][sec_data.sect_end[g][i]]
+			for k := start; k < end; k += inc {
+				tuple := mem.int16s.make(int(inc))
+				copy(tuple, spectrum[g][k:k+inc])
+				data.Hcod = mem.int16s_2d.append(data.Hcod, tuple)
+			}
+		}
+	}
+	return data
+}
+
+// One bit per byte of the n bits of buf, as returned by ReadBitsToByteArray
+func hcr_bits(mem *frame_memory, buf []byte, n uint) []byte {
+	bits := mem.uint8s.make(int(n))
+	for i := range bits {
+		from_end := n - 1 - uint(i)
+		bits[i] = (buf[len(buf)-1-int(from_end/8)] >> (from_end % 8)) & 1
+	}
+	return bits
+}
+
+func hcr_reverse(mem *frame_memory, bits []byte) []byte {
+	reversed := mem.uint8s.make(len(bits))
+	for i, b := range bits {
+		reversed[len(bits)-1-i] = b
+	}
+	return reversed
+}
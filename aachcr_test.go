@@ -0,0 +1,235 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// A codeword of the codebook drawn the way coded spectra are: the shorter,
+// the more likely
+func hcrTestCodeword(rng *rand.Rand, sect_cb uint8) ([]byte, []int16) {
+	for {
+		bits := make([]byte, 20)
+		for i := range bits {
+			bits[i] = byte(rng.Intn(2))
+		}
+		if values, used, ok := hcr_codeword_values(new(frame_memory), sect_cb, bits, make([]int16, 4)); ok {
+			return bits[:used], values
+		}
+	}
+}
+
+// Lays out the codewords the way hcr_decode reads them.  Returns the bits
+// of reordered_spectral_data, exactly as long as all codewords together.
+func hcrEncode(t testing.TB, codewords []*hcr_codeword, code map[*hcr_codeword][]byte, longest uint8) []byte {
+	length := 0
+	for _, cw := range codewords {
+		length += len(code[cw])
+	}
+	out := make([]byte, length)
+
+	// Free positions of each segment in reading order
+	var free [][]int
+	pos := 0
+	i := 0
+	for ; i < len(codewords); i++ {
+		cw := codewords[i]
+		width := int(hcr_max_codeword_length[cw.sect_cb])
+		if width > int(longest) {
+			width = int(longest)
+		}
+		if pos+width > length {
+			last := len(free) - 1
+			var tail []int
+			for p := length - 1; p >= pos; p-- {
+				tail = append(tail, p)
+			}
+			free[last] = append(tail, free[last]...)
+			break
+		}
+		copy(out[pos:], code[cw])
+		var left []int
+		for p := pos + width - 1; p >= pos+len(code[cw]); p-- {
+			left = append(left, p)
+		}
+		free = append(free, left)
+		pos += width
+	}
+
+	rest := make([][]byte, len(codewords))
+	for j := i; j < len(codewords); j++ {
+		rest[j] = code[codewords[j]]
+	}
+	non := rest[i:]
+	n := len(free)
+	for set := 0; set*n < len(non); set++ {
+		for trial := 0; trial < n; trial++ {
+			for base := 0; base < n && set*n+base < len(non); base++ {
+				s := (trial + base) % n
+				if len(non[set*n+base]) == 0 {
+					continue
+				}
+				k := len(non[set*n+base])
+				if k > len(free[s]) {
+					k = len(free[s])
+				}
+				for j := 0; j < k; j++ {
+					out[free[s][j]] = non[set*n+base][j]
+				}
+				free[s] = free[s][k:]
+				non[set*n+base] = non[set*n+base][k:]
+			}
+		}
+		for s := range free {
+			for a, b := 0, len(free[s])-1; a < b; a, b = a+1, b-1 {
+				free[s][a], free[s][b] = free[s][b], free[s][a]
+			}
+		}
+	}
+	for _, bits := range non {
+		if len(bits) != 0 {
+			t.Fatalf("codeword left over by the encoder")
+		}
+	}
+	return out
+}
+
+func TestReorderedSpectralData(t *testing.T) {
+	// Short windows in groups of 1, 3, 2, 1 and 1 windows
+	info := &ics_info{Window_sequence: EIGHT_SHORT_SEQUENCE, Max_sfb: 4, Scale_factor_grouping: 0x34}
+	window_grouping(new(frame_memory), info, 3, 1024)
+	sections := [][][2]uint8{
+		{{ESC_HCB, 1}, {1, 2}, {5, 1}},
+		{{ZERO_HCB, 1}, {9, 3}},
+		{{3, 4}},
+		{{2, 2}, {10, 2}},
+		{{7, 4}},
+	}
+	if int(info.num_window_groups) != len(sections) {
+		t.Fatalf("num_window_groups (%d) must be %d", info.num_window_groups, len(sections))
+	}
+
+	sec_data := &section_data{
+		Sect_cb:    make([][]uint8, len(sections)),
+		sect_start: make([][]uint8, len(sections)),
+		sect_end:   make([][]uint16, len(sections)),
+		num_sec:    make([]uint8, len(sections)),
+	}
+	info.sfb_cb = make([][]uint8, len(sections))
+	for g, secs := range sections {
+		k := uint8(0)
+		for _, sec := range secs {
+			sec_data.Sect_cb[g] = append(sec_data.Sect_cb[g], sec[0])
+			sec_data.sect_start[g] = append(sec_data.sect_start[g], k)
+			sec_data.sect_end[g] = append(sec_data.sect_end[g], uint16(k+sec[1]))
+			for j := uint8(0); j < sec[1]; j++ {
+				info.sfb_cb[g] = append(info.sfb_cb[g], sec[0])
+			}
+			k += sec[1]
+		}
+		sec_data.num_sec[g] = uint8(len(secs))
+	}
+
+	// Random codewords for every tuple
+	rng := rand.New(rand.NewSource(1))
+	codewords := hcr_sort(new(frame_memory), info, sec_data)
+	code := map[*hcr_codeword][]byte{}
+	spectrum := make([][]int16, len(sections))
+	for g := range spectrum {
+		spectrum[g] = make([]int16, info.sect_sfb_offset[g][len(info.sect_sfb_offset[g])-1])
+	}
+	longest := uint8(0)
+	for _, cw := range codewords {
+		bits, values := hcrTestCodeword(rng, cw.sect_cb)
+		code[cw] = bits
+		copy(spectrum[cw.g][cw.sp:], values)
+		if uint8(len(bits)) > longest {
+			longest = uint8(len(bits))
+		}
+	}
+	bits := hcrEncode(t, codewords, code, longest)
+	want := hcr_tuples(new(frame_memory), info, sec_data, spectrum)
+
+	config := &AudioSpecificConfig{
+		Audio_object_type:        AUDIO_OBJECT_TYPE_ER,
+		Sampling_frequency_index: 3,
+		Sampling_frequency:       48000,
+		Channel_configuration:    1,
+		Ga_specific_config: &ga_specific_config{
+			Extension_flag:                    true,
+			Aac_spectral_data_resilience_flag: true,
+		},
+	}
+	w := &bitWriter{}
+	w.write(0, 4)   // element_instance_tag
+	w.write(100, 8) // global_gain
+	w.write(0, 1)   // ics_reserved_bit
+	w.write(EIGHT_SHORT_SEQUENCE, 2)
+	w.write(0, 1) // window_shape
+	w.write(uint64(info.Max_sfb), 4)
+	w.write(uint64(info.Scale_factor_grouping), 7)
+	for _, secs := range sections {
+		for _, sec := range secs {
+			w.write(uint64(sec[0]), 4)
+			w.write(uint64(sec[1]), 3)
+		}
+	}
+	for _, cbs := range info.sfb_cb {
+		for _, cb := range cbs {
+			if cb != ZERO_HCB {
+				w.write(0, 1) // hcod_sf of no difference
+			}
+		}
+	}
+	w.write(0, 1) // pulse_data_present
+	w.write(0, 1) // tns_data_present
+	w.write(0, 1) // gain_control_data_present
+	w.write(uint64(len(bits)), 14)
+	w.write(uint64(longest), 6)
+	for _, b := range bits {
+		w.write(uint64(b), 1)
+	}
+	w.byteAlign()
+
+	adts, err := ParseRawDataBlock(config, w.buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	s := adts.Single_channel_elements[0].Channel_stream
+	if int(s.Length_of_reordered_spectral_data) != len(bits) || s.Length_of_longest_code_word != longest {
+		t.Errorf("lengths (%d, %d) must be (%d, %d)", s.Length_of_reordered_spectral_data,
+			s.Length_of_longest_code_word, len(bits), longest)
+	}
+	if len(s.Spectral_data.Hcod) != len(codewords) {
+		t.Fatalf("%d tuples must be decoded, have %d", len(codewords), len(s.Spectral_data.Hcod))
+	}
+	if !reflect.DeepEqual(s.Spectral_data.Hcod, want.Hcod) {
+		t.Errorf("tuples %v must be %v", s.Spectral_data.Hcod, want.Hcod)
+	}
+
+	// Most codewords are not priority codewords
+	if len(codewords) < 2*len(bits)/int(longest) {
+		t.Errorf("%d codewords in %d bits don't leave non priority codewords", len(codewords), len(bits))
+	}
+
+	if err := hcr_decode(new(frame_memory), info, sec_data, bits[:longest-1], longest, spectrum); err == nil {
+		t.Errorf("reordered_spectral_data shorter than its longest codeword must return an error")
+	}
+}
@@ -2584,7 +2584,7 @@ var hcb_table = [...]([]hcb_struct){
 // Each leaf node is indicated by a 0 in the 2nd position.  The first element and
 // non-zero 2nd elements are mathmatical offsets into the array. The value is
 // chosen  based on the value of the next bit in the Huffman encoding word from the bitstream.
-func hcod_sf(reader *bitreader.BitReader) (uint8, error) {
+func hcod_sf(reader bitreader.Reader) (uint8, error) {
 	Pos := uint8(0)
 	for huffman_sf[Pos][1] != 0 {
 		h, _ := reader.ReadBitsAsUInt8(1)
@@ -2598,14 +2598,10 @@ func hcod_sf(reader *bitreader.BitReader) (uint8, error) {
 }
 
 // 2 step lookup is used when possible
-func hcod_2step(reader *bitreader.BitReader, codebook uint8, values []int8) error {
-	toRead := hcb_2step_bits[codebook]
-	// Ensure we don't run off our buffer
-	if uint(toRead) > reader.BitsLeft() {
-		toRead = uint8(reader.BitsLeft())
-	}
-
-	codeWord, _ := reader.PeekBits(uint(toRead))
+func hcod_2step(reader bitreader.Reader, codebook uint8, values []int16) error {
+	// PeekBits pads with zeros past the end of the data, a truncated
+	// codeword is caught by the skip below
+	codeWord, _ := reader.PeekBits(uint(hcb_2step_bits[codebook]))
 	offset := uint(hcb_2step[codebook][codeWord].Offset)
 	extra := uint(hcb_2step[codebook][codeWord].Extra)
 
@@ -2626,13 +2622,13 @@ func hcod_2step(reader *bitreader.BitReader, codebook uint8, values []int8) erro
 	}
 
 	for i := range values {
-		values[i] = hcb_table[codebook][offset][i+1]
+		values[i] = int16(hcb_table[codebook][offset][i+1])
 	}
 	return nil
 }
 
 // binary seach is used when the 2 step lookup table would use tons of memory
-func hcod_binary(reader *bitreader.BitReader, codebook uint8, values []int8) error {
+func hcod_binary(reader bitreader.Reader, codebook uint8, values []int16) error {
 	offset := uint16(0)
 	for hcb_table[codebook][offset][0] == 0 {
 		bit, err := reader.ReadBit()
@@ -2648,46 +2644,61 @@ func hcod_binary(reader *bitreader.BitReader, codebook uint8, values []int8) err
 			offset, hcb_table[codebook])
 	}
 
-	for i := 0; i < cap(values); i++ {
-		values[i] = hcb_table[codebook][offset][i+1]
+	for i := range values {
+		values[i] = int16(hcb_table[codebook][offset][i+1])
 	}
 	return nil
 }
 
 // Select the correct lookup method based on the codebook
-func hcod(reader *bitreader.BitReader, sect_cb uint8) ([]int8, error) {
+func hcod(reader bitreader.Reader, sect_cb uint8) ([]int16, error) {
+	values := make([]int16, 2)
+	if sect_cb < FIRST_PAIR_HCB {
+		values = make([]int16, 4)
+	}
+	return values, hcod_values(reader, sect_cb, values)
+}
+
+// Decode a codeword of sect_cb into values, which holds 4 values for the
+// quad codebooks and 2 for the pair ones
+func hcod_values(reader bitreader.Reader, sect_cb uint8, values []int16) error {
 	var err error
-	var values []int8
+
+	// the virtual codebooks are codebook 11 with a smaller largest value
+	codebook := sect_cb
+	if sect_cb >= FIRST_VCB11 && sect_cb < FIRST_VCB11+uint8(len(vcb11_lav)) {
+		codebook = ESC_HCB
+	}
 
 	// call the optimal search method for each case
-	switch sect_cb {
+	switch codebook {
 	case 1, 2, 4:
-		values = make([]int8, 4)
-		err = hcod_2step(reader, sect_cb, values)
+		err = hcod_2step(reader, codebook, values)
 	case 3:
-		values = make([]int8, 4)
-		err = hcod_binary(reader, sect_cb, values)
+		err = hcod_binary(reader, codebook, values)
 	case 5, 7, 9:
-		values = make([]int8, 2)
-		err = hcod_binary(reader, sect_cb, values)
+		err = hcod_binary(reader, codebook, values)
 	case 6, 8, 10, 11:
-		values = make([]int8, 2)
-		err = hcod_2step(reader, sect_cb, values)
+		err = hcod_2step(reader, codebook, values)
 	default:
-		return values, fmt.Errorf("Error: codebook (%d) is unsupported", sect_cb)
+		return parseErrorf(ErrReserved, "Error: codebook (%d) is unsupported", sect_cb)
 	}
 
 	if err != nil {
-		return values, err
+		return err
 	}
 
 	// account for sign bits in the bitstream
-	switch sect_cb {
+	switch codebook {
 	case 1, 2, 5, 6:
 	default:
 		for i := range values {
 			if values[i] != 0 {
-				if sign, _ := reader.ReadBitAsBool(); sign {
+				sign, err := reader.ReadBitAsBool()
+				if err != nil {
+					return err
+				}
+				if sign {
 					values[i] = -values[i]
 				}
 			}
@@ -2695,19 +2706,13 @@ func hcod(reader *bitreader.BitReader, sect_cb uint8) ([]int8, error) {
 	}
 
 	// with escape
-	switch sect_cb {
-	case 11:
-		for i := 0; i < 2; i++ {
+	if codebook == ESC_HCB {
+		for i := range values {
 			if values[i] == 16 || values[i] == -16 {
-				esc := true
-				bitcount := uint(3)
-				for esc {
-					bitcount++
-					esc, _ = reader.ReadBitAsBool()
+				val, err := hcod_escape(reader)
+				if err != nil {
+					return err
 				}
-				offset, _ := reader.ReadBitsAsInt(bitcount)
-
-				val := int8(offset | (1 << bitcount))
 				if values[i] < 0 {
 					values[i] = -val
 				} else {
@@ -2717,7 +2722,48 @@ func hcod(reader *bitreader.BitReader, sect_cb uint8) ([]int8, error) {
 		}
 	}
 
-	return values, nil
+	if sect_cb >= FIRST_VCB11 {
+		lav := vcb11_lav[sect_cb-FIRST_VCB11]
+		for i := range values {
+			if values[i] > lav || values[i] < -lav {
+				return parseErrorf(ErrOutOfRange, "Error: spectral value (%d) exceeds the largest value (%d) of codebook %d",
+					values[i], lav, sect_cb)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Largest absolute value of the virtual codebooks 16 to 31 (Table 4.155)
+var vcb11_lav = [...]int16{16, 31, 47, 63, 95, 127, 159, 191, 223, 255, 319, 383, 511, 767, 1023, 2047}
+
+// Limit of the escape_prefix, escape values stay below 1 << 13
+const MAX_ESCAPE_PREFIX = 8
+
+// escape_sequence, the absolute value of a codebook 11 escape (4.6.3.3)
+func hcod_escape(reader bitreader.Reader) (int16, error) {
+	prefix := uint(0)
+	for {
+		esc, err := reader.ReadBitAsBool()
+		if err != nil {
+			return 0, parseErrorf(ErrTruncated, "Error: escape_sequence ran out of bits")
+		}
+		if !esc {
+			break
+		}
+		prefix++
+		if prefix > MAX_ESCAPE_PREFIX {
+			return 0, parseErrorf(ErrOutOfRange, "Error: escape_prefix exceeds %d bits", MAX_ESCAPE_PREFIX)
+		}
+	}
+
+	bitcount := prefix + 4
+	offset, err := reader.ReadBitsAsInt(bitcount)
+	if err != nil {
+		return 0, parseErrorf(ErrTruncated, "Error: escape_word ran out of bits")
+	}
+	return int16(offset | (1 << bitcount)), nil
 }
 
 // BEGIN SBR
@@ -2904,7 +2950,7 @@ var t_huffman_noise_bal_3_0dB = [][]int8{
 	{-57, -56}, {22, 23}, {-55, -54}, {-53, -52},
 }
 
-func sbr_huff_dec(reader *bitreader.BitReader, t_huff [][]int8) int {
+func sbr_huff_dec(reader bitreader.Reader, t_huff [][]int8) int {
 	index := 0
 
 	for index >= 0 {
@@ -0,0 +1,110 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Comcast/gaad/bitreader"
+)
+
+// Finds the codeword of the unsigned values in the codebook
+func hcodCodeword(t *testing.T, codebook uint8, values []int16) (uint64, uint) {
+	for length := uint(1); length <= 16; length++ {
+		for code := uint64(0); code < 1<<length; code++ {
+			w := &bitWriter{}
+			w.write(code, length)
+			w.write(0, 24)
+			reader := bitreader.NewBitReader(w.buf)
+			got := make([]int16, len(values))
+			var err error
+			if codebook == 3 || codebook == 5 || codebook == 7 || codebook == 9 {
+				err = hcod_binary(reader, codebook, got)
+			} else {
+				err = hcod_2step(reader, codebook, got)
+			}
+			if err == nil && uint(len(w.buf))*8-reader.BitsLeft() == length && reflect.DeepEqual(got, values) {
+				return code, length
+			}
+		}
+	}
+	t.Fatalf("codebook %d has no codeword of %v", codebook, values)
+	return 0, 0
+}
+
+func TestHcodEscape(t *testing.T) {
+	code, length := hcodCodeword(t, ESC_HCB, []int16{16, 3})
+
+	for _, test := range []struct {
+		name    string
+		sect_cb uint8
+		prefix  uint
+		word    uint64
+		want    []int16
+		err     bool
+	}{
+		{"above int8", ESC_HCB, 4, 0x2d, []int16{301, -3}, false},
+		{"largest escape", ESC_HCB, 8, 0xfff, []int16{8191, -3}, false},
+		{"escape_prefix too long", ESC_HCB, 9, 0, nil, true},
+		{"within the virtual codebook", FIRST_VCB11 + 1, 0, 0xf, []int16{31, -3}, false},
+		{"beyond the virtual codebook", FIRST_VCB11 + 1, 1, 0, nil, true},
+		{"beyond the last virtual codebook", FIRST_VCB11 + 15, 7, 0, nil, true},
+	} {
+		w := &bitWriter{}
+		w.write(code, length)
+		w.write(0, 1) // sign of 16
+		w.write(1, 1) // sign of 3
+		for i := uint(0); i < test.prefix; i++ {
+			w.write(1, 1)
+		}
+		w.write(0, 1)
+		w.write(test.word, test.prefix+4)
+		w.byteAlign()
+
+		values, err := hcod(bitreader.NewBitReader(w.buf), test.sect_cb)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: hcod must return an error, returned %v", test.name, values)
+			} else if !errors.Is(err, ErrOutOfRange) {
+				t.Errorf("%s: err (%s) must be of category %v", test.name, err, ErrOutOfRange)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: err (%s) must be nil", test.name, err)
+		}
+		if !reflect.DeepEqual(values, test.want) {
+			t.Errorf("%s: values %v must be %v", test.name, values, test.want)
+		}
+	}
+
+	// An escape_word cut short by the end of the data
+	w := &bitWriter{}
+	w.write(code, length)
+	w.write(0, 2)
+	w.write(0x1fe, 9)
+	w.write(0xfff, 12)
+	if _, err := hcod(bitreader.NewBitReader(w.buf[:(w.bits-12+7)/8]), ESC_HCB); !errors.Is(err, ErrTruncated) {
+		t.Errorf("a truncated escape_sequence must return an error of category %v, returned %v", ErrTruncated, err)
+	}
+
+	if _, err := hcod(bitreader.NewBitReader([]byte{0, 0}), FIRST_VCB11+16); err == nil {
+		t.Errorf("codebook %d must be unsupported", FIRST_VCB11+16)
+	}
+}
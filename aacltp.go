@@ -0,0 +1,110 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+// LTP gain, indexed by ltp_coef
+var ltp_coef = [8]float32{
+	0.570829, 0.696616, 0.813004, 0.911304,
+	0.984900, 1.067894, 1.194601, 1.369533,
+}
+
+// Long term prediction state of a channel, kept across frames so each
+// decoded channel needs its own LTPState.
+type LTPState struct {
+	frame_length int
+	windows      *filterbank_windows
+
+	// With N the frame length: [0, 2N) the last two frames of decoded
+	// output, [2N, 3N) the overlap the last IMDCT left for the next frame
+	// and [3N, 4N) zeros, so a lag reaching into the unknown future
+	// predicts silence.
+	history []float32
+
+	window_shape_prev uint8
+
+	// The lag of the last prediction, for ER AAC LD frames that don't
+	// update it
+	lag_prev uint
+}
+
+func NewLTPState(frame_length int) *LTPState {
+	return &LTPState{
+		frame_length: frame_length,
+		windows:      new_filterbank_windows(frame_length),
+		history:      make([]float32, 4*frame_length),
+	}
+}
+
+// Adds the long term prediction to a channel's dequantized spectrum.  The
+// lagged history, scaled by the LTP gain, is windowed with the frame's
+// window_sequence and shapes, transformed with the MDCT and added to the
+// bands flagged in ltp_long_used[].  If the channel uses TNS the estimate
+// has to be filtered the same way, which tns does when not nil.
+//
+// ltp is the channel's ics_info Ltp_data, or Ltp_data2 for the second
+// channel of a CPE with a common window.  ltp_data is only sent with long,
+// start and stop windows, eight short windows are never predicted.  Either
+// way the frame's output has to be fed back with Update.  An ltp without a
+// lag of its own predicts with the lag of the last prediction.
+func (s *LTPState) Predict(info *ics_info, ltp *ltp_data, spec []float32, tns func(est []float32)) {
+	if ltp == nil || info.Window_sequence == EIGHT_SHORT_SEQUENCE {
+		return
+	}
+
+	lag := ltp.Ltp_lag
+	if ltp.Ltp_lag_prev {
+		lag = s.lag_prev
+	}
+	s.lag_prev = lag
+
+	n := s.frame_length
+	gain := ltp_coef[ltp.Ltp_coef&7]
+	x_est := make([]float32, 2*n)
+	for i := range x_est {
+		if j := 2*n + i - int(lag); j >= 0 && j < len(s.history) {
+			x_est[i] = s.history[j] * gain
+		}
+	}
+
+	s.windows.window(info.Window_sequence, info.Window_shape, s.window_shape_prev, x_est, x_est)
+	X_est := make([]float32, n)
+	mdct(x_est, X_est)
+
+	if tns != nil {
+		tns(X_est)
+	}
+
+	for sfb, used := range ltp.Ltp_long_used {
+		if !used || sfb+1 >= len(info.swb_offset) {
+			continue
+		}
+		for k := int(info.swb_offset[sfb]); k < int(info.swb_offset[sfb+1]) && k < len(spec); k++ {
+			spec[k] += X_est[k]
+		}
+	}
+}
+
+// Feeds a decoded frame back into the history: output is the frame's
+// frame_length reconstructed samples and overlap the windowed second half
+// of its IMDCT, which will be added to the next frame.
+func (s *LTPState) Update(info *ics_info, output []float32, overlap []float32) {
+	n := s.frame_length
+	copy(s.history, s.history[n:2*n])
+	copy(s.history[n:2*n], output)
+	copy(s.history[2*n:3*n], overlap)
+	s.window_shape_prev = info.Window_shape
+}
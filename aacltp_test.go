@@ -0,0 +1,192 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"math"
+	"testing"
+)
+
+// MDCT straight from its definition
+func directMDCT(in []float32) []float64 {
+	n := len(in) / 2
+	n0 := float64(n)/2 + 0.5
+	out := make([]float64, n)
+	for k := range out {
+		for i, x := range in {
+			out[k] += 2 * float64(x) * math.Cos(math.Pi/float64(n)*(float64(i)+n0)*(float64(k)+0.5))
+		}
+	}
+	return out
+}
+
+// Synthesis IMDCT, 1/N scaled
+func directIMDCT(spec []float32) []float64 {
+	n := len(spec)
+	n0 := float64(n)/2 + 0.5
+	out := make([]float64, 2*n)
+	for i := range out {
+		for k, x := range spec {
+			out[i] += float64(x) * math.Cos(math.Pi/float64(n)*(float64(i)+n0)*(float64(k)+0.5))
+		}
+		out[i] /= float64(n)
+	}
+	return out
+}
+
+func TestMDCT(t *testing.T) {
+	// 64 exercises the radix-2 path, 120 the odd length DFT of 960 frames
+	for _, size := range []int{64, 120} {
+		in := make([]float32, size)
+		for i := range in {
+			in[i] = float32(math.Sin(float64(i)*0.7) + math.Cos(float64(i*i)*0.01))
+		}
+		out := make([]float32, size/2)
+		mdct(in, out)
+		want := directMDCT(in)
+		for k := range out {
+			if math.Abs(float64(out[k])-want[k]) > 1e-3 {
+				t.Fatalf("size %d: mdct[%d] (%f) must be %f", size, k, out[k], want[k])
+			}
+		}
+	}
+}
+
+func TestMDCTReconstruction(t *testing.T) {
+	// Two overlapping sine windowed transforms rebuild the shared half
+	const n = 32
+	fw := new_filterbank_windows(n)
+	signal := make([]float32, 3*n)
+	for i := range signal {
+		signal[i] = float32(math.Sin(float64(i) * 0.37))
+	}
+
+	var halves [2][]float64
+	for f := range halves {
+		windowed := make([]float32, 2*n)
+		fw.window(ONLY_LONG_SEQUENCE, SINE_WINDOW, SINE_WINDOW, signal[f*n:f*n+2*n], windowed)
+		spec := make([]float32, n)
+		mdct(windowed, spec)
+		halves[f] = directIMDCT(spec)
+		for i := range halves[f] {
+			if i < n {
+				halves[f][i] *= float64(fw.long[0][i])
+			} else {
+				halves[f][i] *= float64(fw.long[0][2*n-1-i])
+			}
+		}
+	}
+	for i := 0; i < n; i++ {
+		if got := halves[0][n+i] + halves[1][i]; math.Abs(got-float64(signal[n+i])) > 1e-4 {
+			t.Fatalf("sample %d (%f) must be %f", i, got, signal[n+i])
+		}
+	}
+}
+
+func TestKBDWindow(t *testing.T) {
+	// Princen-Bradley: w[i]^2 + w[n-1-i]^2 == 1 on the rising half
+	for _, w := range [][]float32{kbd_window(1024, 4), kbd_window(128, 6), sine_window(960)} {
+		n := len(w)
+		for i := 0; i < n; i++ {
+			if p := float64(w[i])*float64(w[i]) + float64(w[n-1-i])*float64(w[n-1-i]); math.Abs(p-1) > 1e-5 {
+				t.Fatalf("window of %d: w[%d]^2 + w[%d]^2 (%f) must be 1", n, i, n-1-i, p)
+			}
+		}
+	}
+}
+
+func TestLTPPredict(t *testing.T) {
+	const n = 1024
+	s := NewLTPState(n)
+	// 64 sample periodic signal, history known through the overlap
+	signal := func(i int) float32 { return float32(1000 * math.Sin(2*math.Pi*float64(i)/64)) }
+	for i := 0; i < 3*n; i++ {
+		s.history[i] = signal(i)
+	}
+
+	info := &ics_info{Window_sequence: ONLY_LONG_SEQUENCE, Window_shape: SINE_WINDOW}
+	window_grouping(new(frame_memory), info, 4, n)
+	ltp := &ltp_data{
+		Ltp_lag:       17 * 64,
+		Ltp_coef:      3,
+		Ltp_long_used: make([]bool, MAX_LTP_LONG_SFB),
+	}
+	for sfb := range ltp.Ltp_long_used {
+		ltp.Ltp_long_used[sfb] = sfb != 5
+	}
+
+	spec := make([]float32, n)
+	s.Predict(info, ltp, spec, nil)
+
+	// Lagged by whole periods the estimate is the frame itself
+	frame := make([]float32, 2*n)
+	for i := range frame {
+		frame[i] = signal(2*n+i) * ltp_coef[3]
+	}
+	s.windows.window(ONLY_LONG_SEQUENCE, SINE_WINDOW, SINE_WINDOW, frame, frame)
+	want := directMDCT(frame)
+
+	for sfb := 0; sfb < int(MAX_LTP_LONG_SFB); sfb++ {
+		for k := int(info.swb_offset[sfb]); k < int(info.swb_offset[sfb+1]); k++ {
+			expected := want[k]
+			if sfb == 5 {
+				expected = 0
+			}
+			if math.Abs(float64(spec[k])-expected) > 0.5 {
+				t.Fatalf("line %d (%f) must be %f", k, spec[k], expected)
+			}
+		}
+	}
+
+	// An LD frame without ltp_lag_update predicts with the same lag
+	first := append([]float32(nil), spec...)
+	spec = make([]float32, n)
+	s.Predict(info, &ltp_data{Ltp_lag_prev: true, Ltp_coef: 3, Ltp_long_used: ltp.Ltp_long_used}, spec, nil)
+	for k := range spec {
+		if spec[k] != first[k] {
+			t.Fatalf("line %d (%f) with the previous lag must be %f", k, spec[k], first[k])
+		}
+	}
+
+	tns_called := false
+	s.Predict(info, ltp, spec, func(est []float32) { tns_called = len(est) == n })
+	if !tns_called {
+		t.Errorf("tns must be run over the estimate")
+	}
+
+	s.Update(info, make([]float32, n), make([]float32, n))
+	if s.history[0] != signal(n) || s.history[n] != 0 || s.history[2*n] != 0 {
+		t.Errorf("Update must shift in the new frame")
+	}
+}
+
+func TestIMDCT(t *testing.T) {
+	for _, size := range []int{32, 60} {
+		spec := make([]float32, size)
+		for k := range spec {
+			spec[k] = float32(math.Cos(float64(k) * 1.3))
+		}
+		out := make([]float32, 2*size)
+		imdct(spec, out)
+		want := directIMDCT(spec)
+		for i := range out {
+			if math.Abs(float64(out[i])-want[i]) > 1e-5 {
+				t.Fatalf("size %d: imdct[%d] (%f) must be %f", size, i, out[i], want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+// Parse modes of Options
+const (
+	// Rejects what the spec forbids where the syntax depends on it, and
+	// the reserved values of the ADTS layer, ics_reserved_bit and
+	// ms_mask_present
+	PARSE_DEFAULT = iota
+	// Also rejects every other reserved bit that is set, fill data other
+	// than the fill pattern and a program_config_element() of another
+	// sampling frequency than the header
+	PARSE_STRICT
+	// Accepts the reserved values the default mode rejects.  An error
+	// after the header ends the frame without an error returned, it is
+	// kept in the Err of the raw_data_block and of the element it
+	// happened in.
+	PARSE_LENIENT
+)
+
+// Options of ParseADTSWithOptions, the zero value parses like ParseADTS
+type Options struct {
+	// One of PARSE_DEFAULT, PARSE_STRICT or PARSE_LENIENT
+	Mode int
+
+	// Spectral data is read to find the next element but not kept, the
+	// Spectral_data of each individual_channel_stream is nil
+	SkipSpectralData bool
+
+	// SBR extension payloads are skipped, their Sbr_extension_data is nil
+	// but the Extension_type still tells that SBR is there
+	SkipSBR bool
+
+	// Limit on the syntactic elements of a raw_data_block(), 0 for none
+	MaxElements int
+
+	// Limit on the bytes of a fill_element(), data_stream_element(),
+	// data element and SBR extension, 0 for MaxBitsLeft bits
+	MaxExtensionBytes int
+}
+
+// Parses an ADTS frame with the given options
+func ParseADTSWithOptions(byteArray []byte, options Options) (*ADTS, error) {
+	adts := &ADTS{options: options}
+	err := adts.ParseInto(byteArray)
+	return adts, err
+}
+
+// A reserved value that is set, lenient mode accepts it
+func (adts *ADTS) reserved(set bool, format string, a ...interface{}) error {
+	if !set || adts.options.Mode == PARSE_LENIENT {
+		return nil
+	}
+	return parseErrorf(ErrReserved, format, a...)
+}
+
+// An error only strict mode rejects the frame for
+func (adts *ADTS) strict(set bool, kind error, format string, a ...interface{}) error {
+	if !set || adts.options.Mode != PARSE_STRICT {
+		return nil
+	}
+	return parseErrorf(kind, format, a...)
+}
+
+// The error of the frame after its header, lenient mode drops it and keeps
+// what was parsed
+func (adts *ADTS) lenient(err error) error {
+	if adts.options.Mode == PARSE_LENIENT {
+		return nil
+	}
+	return err
+}
+
+// An extension payload of cnt bytes, an error if it's over the limit
+func (adts *ADTS) extension_limit(cnt int) error {
+	limit := MaxBitsLeft / 8
+	if adts.options.MaxExtensionBytes > 0 {
+		limit = adts.options.MaxExtensionBytes
+	}
+	if cnt > limit {
+		return parseErrorf(ErrOutOfRange, "Error: extension of %d bytes exceeds the limit of %d", cnt, limit)
+	}
+	return nil
+}
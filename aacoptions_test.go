@@ -0,0 +1,220 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"errors"
+	"testing"
+)
+
+// fill_element() of an EXT_FILL_DATA payload
+func writeFillData(w *bitWriter, fill_nibble uint64, fill_byte uint64) {
+	w.write(ID_FIL, 3)
+	w.write(2, 4) // count
+	w.write(EXT_FILL_DATA, 4)
+	w.write(fill_nibble, 4)
+	w.write(fill_byte, 8)
+}
+
+func TestOptionsStrictFillData(t *testing.T) {
+	tests := []struct {
+		fill_nibble uint64
+		fill_byte   uint64
+		reserved    bool
+	}{
+		{0, 0xa5, false},
+		{1, 0xa5, true},
+		{0, 0x00, true},
+	}
+
+	for _, test := range tests {
+		buf := adtsFrame(1, 3, 1, func(w *bitWriter) {
+			writeFillData(w, test.fill_nibble, test.fill_byte)
+		})
+
+		if _, err := ParseADTS(buf); err != nil {
+			t.Errorf("fill_nibble %d, fill_byte %#x: err (%v) must be nil by default", test.fill_nibble, test.fill_byte, err)
+		}
+		_, err := ParseADTSWithOptions(buf, Options{Mode: PARSE_STRICT})
+		if test.reserved && !errors.Is(err, ErrReserved) {
+			t.Errorf("fill_nibble %d, fill_byte %#x: err (%v) must be ErrReserved in strict mode", test.fill_nibble, test.fill_byte, err)
+		} else if !test.reserved && err != nil {
+			t.Errorf("fill_nibble %d, fill_byte %#x: err (%v) must be nil in strict mode", test.fill_nibble, test.fill_byte, err)
+		}
+	}
+}
+
+func TestOptionsStrictPceSamplingFrequency(t *testing.T) {
+	for sfi := uint64(3); sfi <= 4; sfi++ {
+		buf := adtsFrame(1, 3, 0, func(w *bitWriter) {
+			w.write(ID_PCE, 3)
+			w.write(0, 4) // element_instance_tag
+			w.write(1, 2) // object_type
+			w.write(sfi, 4)
+			w.write(0, 21) // no elements of any kind
+			w.write(0, 3)  // no mixdowns
+			w.byteAlign()
+			w.write(0, 8) // comment_field_bytes
+		})
+
+		if _, err := ParseADTS(buf); err != nil {
+			t.Errorf("sfi %d: err (%v) must be nil by default", sfi, err)
+		}
+		_, err := ParseADTSWithOptions(buf, Options{Mode: PARSE_STRICT})
+		if sfi == 3 && err != nil {
+			t.Errorf("sfi %d: err (%v) must be nil in strict mode", sfi, err)
+		} else if sfi != 3 {
+			checkParseError(t, err, ErrOutOfRange, "Table 4.2 program_config_element", "raw_data_block[0]/PCE[0]/program_config_element")
+		}
+	}
+}
+
+func TestOptionsLenientReserved(t *testing.T) {
+	buf := adtsFrame(1, 3, 2, func(w *bitWriter) {
+		w.write(ID_CPE, 3)
+		w.write(0, 4) // element_instance_tag
+		w.write(0, 1) // common_window
+		writeLcIcs(w, 1)
+		writeLcIcs(w, 0)
+	})
+	buf[1] |= 0x6 // layer
+
+	if _, err := ParseADTS(buf); !errors.Is(err, ErrReserved) {
+		t.Errorf("err (%v) must be ErrReserved by default", err)
+	}
+	buf[1] &^= 0x6
+	if _, err := ParseADTS(buf); !errors.Is(err, ErrReserved) {
+		t.Errorf("err (%v) must be ErrReserved by default", err)
+	}
+	buf[1] |= 0x6
+
+	adts, err := ParseADTSWithOptions(buf, Options{Mode: PARSE_LENIENT})
+	if err != nil {
+		t.Fatalf("err (%v) must be nil in lenient mode", err)
+	}
+	if adts.Layer != 3 {
+		t.Errorf("Layer (%d) must be 3", adts.Layer)
+	}
+	if len(adts.Channel_pair_elements) != 1 || adts.Channel_pair_elements[0].Channel_stream2 == nil {
+		t.Errorf("the channel_pair_element must be parsed in lenient mode")
+	}
+}
+
+func TestOptionsLenientPartialFrame(t *testing.T) {
+	buf := adtsFrame(1, 3, 2, func(w *bitWriter) {
+		w.write(ID_CPE, 3)
+		w.write(0, 4) // element_instance_tag
+		w.write(0, 1) // common_window
+		writeLcIcs(w, 0)
+		writeLcIcs(w, 0)
+		w.write(ID_CPE, 3)
+		w.write(1, 4) // element_instance_tag
+		w.write(0, 1) // common_window
+		writeLcIcs(w, 0)
+		writeLcIcs(w, 0)
+	})
+	buf = buf[:(56+70+40)/8]
+
+	if _, err := ParseADTS(buf); !errors.Is(err, ErrTruncated) {
+		t.Errorf("err (%v) must be ErrTruncated by default", err)
+	}
+	adts, err := ParseADTSWithOptions(buf, Options{Mode: PARSE_LENIENT})
+	if err != nil {
+		t.Fatalf("err (%v) must be nil in lenient mode", err)
+	}
+	if adts.SamplingFrequency != 48000 {
+		t.Errorf("SamplingFrequency (%d) must be 48000", adts.SamplingFrequency)
+	}
+	elements := adts.Raw_data_blocks[0].Elements
+	if len(elements) != 2 || elements[0] != adts.Channel_pair_elements[0] {
+		t.Fatalf("Elements (%d) must be the parsed and the truncated CPE", len(elements))
+	}
+	if adts.Channel_pair_elements[0].Channel_stream2 == nil {
+		t.Errorf("the first channel_pair_element must be parsed")
+	}
+}
+
+func TestOptionsSkip(t *testing.T) {
+	buf := adtsFrame(1, 3, 1, func(w *bitWriter) {
+		w.write(ID_SCE, 3)
+		w.write(0, 4)   // element_instance_tag
+		w.write(100, 8) // global_gain
+		w.write(0, 1)   // ics_reserved_bit
+		w.write(ONLY_LONG_SEQUENCE, 2)
+		w.write(0, 1) // window_shape
+		w.write(1, 6) // max_sfb
+		w.write(0, 1) // predictor_data_present
+		w.write(1, 4) // sect_cb
+		w.write(1, 5) // sect_len
+		writeHcodSf(t, w, 0)
+		w.write(0, 3)                                    // pulse, tns and gain control
+		w.write(hcodCodeword(t, 1, []int16{0, 0, 0, 0})) // the 4 lines of band 0
+		w.write(ID_FIL, 3)
+		w.write(3, 4) // count
+		w.write(EXT_SBR_DATA, 4)
+		w.write(0xfffff, 20)
+	})
+
+	adts, err := ParseADTSWithOptions(buf, Options{SkipSpectralData: true, SkipSBR: true})
+	if err != nil {
+		t.Fatalf("err (%v) must be nil", err)
+	}
+	if adts.Single_channel_elements[0].Channel_stream.Spectral_data != nil {
+		t.Errorf("Spectral_data must be nil when skipped")
+	}
+	payload := adts.Fill_elements[0].Extension_payload
+	if payload.Extension_type != EXT_SBR_DATA || payload.Sbr_extension_data != nil {
+		t.Errorf("the SBR payload must be skipped")
+	}
+	if len(adts.Raw_data_blocks[0].Elements) != 3 {
+		t.Errorf("Elements (%d) must be the SCE, FIL and END", len(adts.Raw_data_blocks[0].Elements))
+	}
+
+	adts, err = ParseADTSWithOptions(buf, Options{SkipSBR: true})
+	if err != nil {
+		t.Fatalf("err (%v) must be nil", err)
+	}
+	if adts.Single_channel_elements[0].Channel_stream.Spectral_data == nil {
+		t.Errorf("Spectral_data must be parsed unless skipped")
+	}
+}
+
+func TestOptionsLimits(t *testing.T) {
+	buf := adtsFrame(1, 3, 1, func(w *bitWriter) {
+		writeFillData(w, 0, 0xa5)
+		writeFillData(w, 0, 0xa5)
+	})
+
+	tests := []struct {
+		options Options
+		err     bool
+	}{
+		{Options{}, false},
+		{Options{MaxElements: 2}, false},
+		{Options{MaxElements: 1}, true},
+		{Options{MaxExtensionBytes: 2}, false},
+		{Options{MaxExtensionBytes: 1}, true},
+	}
+	for _, test := range tests {
+		_, err := ParseADTSWithOptions(buf, test.options)
+		if test.err && !errors.Is(err, ErrOutOfRange) {
+			t.Errorf("%+v: err (%v) must be ErrOutOfRange", test.options, err)
+		} else if !test.err && err != nil {
+			t.Errorf("%+v: err (%v) must be nil", test.options, err)
+		}
+	}
+}
@@ -18,12 +18,21 @@ package gaad
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/Comcast/gaad/bitreader"
 )
 
 const MaxBitsLeft = 131072
 
+// The input of the parser: a bitreader.BitReader of data in memory, or a
+// bitreader.LimitedReader of a frame of a stream.  The parser reads it as a
+// bitreader.Reader and needs to know where the data ends.
+type frame_reader interface {
+	bitreader.Reader
+	BitsLeft() uint
+}
+
 type ADTS struct {
 	Bitrate              uint32
 	ChannelConfiguration uint8
@@ -34,12 +43,30 @@ type ADTS struct {
 	VbrMode              bool
 	Frame_length         uint16
 
-	reader              *bitreader.BitReader
+	reader              frame_reader
+	options             Options
+	mem                 frame_memory
 	aac_frame_length    uint16
 	sfi                 uint8
 	num_raw_data_blocks uint8
 	protection_absent   bool
 
+	// Error resilience tools signaled by the GASpecificConfig, never used
+	// in ADTS
+	aac_section_data_resilience_flag     bool
+	aac_scalefactor_data_resilience_flag bool
+	aac_spectral_data_resilience_flag    bool
+
+	// Low delay SBR of ER AAC ELD.  Its headers come from the
+	// ELDSpecificConfig, one per SCE and CPE, ld_sbr_element counts the
+	// SBR payloads of the current er_raw_data_block.
+	ld_sbr_header        []*sbr_header
+	ld_sbr_sampling_rate bool
+	ld_sbr_element       int
+
+	Error_check        *adts_error_check
+	Header_error_check *adts_header_error_check
+
 	Single_channel_elements   []*single_channel_element
 	Channel_pair_elements     []*channel_pair_element
 	Coupling_channel_elements []*coupling_channel_element
@@ -47,15 +74,69 @@ type ADTS struct {
 	Data_stream_elements      []*data_stream_element
 	Program_config_elements   []*program_config_element
 	Fill_elements             []*fill_element
+
+	// Every raw_data_block() in the frame with its elements kept in
+	// bitstream order.  The per-type slices above lose that order, which
+	// is needed to tell which fill element's SBR data belongs to which
+	// SCE/CPE and how the decoded channels map to speakers.
+	Raw_data_blocks []*raw_data_block
+}
+
+// Element is implemented by every syntactic element that can appear in a
+// raw_data_block().  ID returns the element's id_syn_ele (ID_SCE..ID_END).
+// Err returns the error the element was cut short by, its fields up to the
+// error are parsed and the rest are zero.
+type Element interface {
+	ID() uint8
+	Err() error
+	Incomplete() bool
+
+	fail(err error)
+}
+
+// Embedded in every Element to mark the one a raw_data_block() failed in
+type element_status struct {
+	err error
+}
+
+func (s *element_status) Err() error       { return s.err }
+func (s *element_status) Incomplete() bool { return s.err != nil }
+func (s *element_status) fail(err error)   { s.err = err }
+
+type raw_data_block struct {
+	// Offset in bytes from the start of the first raw_data_block(), as
+	// signaled by raw_data_block_position[].  Always 0 for the first block
+	// and for frames without CRC protection.
+	Position uint16
+
+	Error_check *adts_raw_data_block_error_check
+	Elements    []Element
+
+	// The error that ended the block before its ID_END, the elements
+	// before the failing one are complete
+	Err error
+}
+
+// Ends the block with err, marking the element it happened in
+func (block *raw_data_block) fail(element Element, err error) error {
+	block.Err = err
+	if element != nil {
+		element.fail(err)
+	}
+	return err
 }
 
 // Begin Main AAC Element Types
 type single_channel_element struct {
+	element_status
+
 	Element_instance_tag uint8
 	Channel_stream       *individual_channel_stream
 }
 
 type channel_pair_element struct {
+	element_status
+
 	Element_instance_tag uint8
 
 	Common_window bool
@@ -67,6 +148,8 @@ type channel_pair_element struct {
 }
 
 type coupling_channel_element struct {
+	element_status
+
 	Element_instance_tag uint8
 	Ind_sw_cce_flag      bool
 	Num_coupled_elements uint8
@@ -87,11 +170,15 @@ type coupling_channel_element struct {
 }
 
 type lfe_channel_element struct {
+	element_status
+
 	Element_instance_tag uint8
 	Channel_stream       *individual_channel_stream
 }
 
 type data_stream_element struct {
+	element_status
+
 	Element_instance_tag uint8
 	Data_byte_align_flag bool
 	Count                uint8
@@ -100,6 +187,8 @@ type data_stream_element struct {
 }
 
 type program_config_element struct {
+	element_status
+
 	Element_instance_tag     uint8
 	Object_type              uint8
 	Sampling_frequency_index uint8
@@ -141,12 +230,27 @@ type program_config_element struct {
 }
 
 type fill_element struct {
+	element_status
+
 	Count     uint16
 	Esc_count uint8
 
 	Extension_payload *extension_payload
 }
 
+type end_element struct {
+	element_status
+}
+
+func (e *single_channel_element) ID() uint8   { return ID_SCE }
+func (e *channel_pair_element) ID() uint8     { return ID_CPE }
+func (e *coupling_channel_element) ID() uint8 { return ID_CCE }
+func (e *lfe_channel_element) ID() uint8      { return ID_LFE }
+func (e *data_stream_element) ID() uint8      { return ID_DSE }
+func (e *program_config_element) ID() uint8   { return ID_PCE }
+func (e *fill_element) ID() uint8             { return ID_FIL }
+func (e *end_element) ID() uint8              { return ID_END }
+
 // End Main AAC element types
 // Begin AAC element sub components
 type adts_error_check struct {
@@ -254,14 +358,24 @@ type ics_info struct {
 	sfb_cb              [][]uint8
 	num_swb             uint8
 
-	Ltp_data_present bool
-	Ltp_data         *ltp_data
+	// ltp_data() of the channel, or of the first channel of a CPE with a
+	// common window.  The second channel's is sent after it and kept in
+	// Ltp_data2 rather than overwriting the first.
+	Ltp_data_present  bool
+	Ltp_data          *ltp_data
+	Ltp_data2_present bool
+	Ltp_data2         *ltp_data
 }
 
 type ltp_data struct {
-	Ltp_lag       uint
-	Ltp_coef      uint8
-	Ltp_long_used []bool
+	// ER AAC LD only sends ltp_lag when it changes.  Without an update
+	// Ltp_lag_prev is set and Ltp_lag is left at 0, the lag is the one of
+	// the channel's previous frame which its LTPState keeps.
+	Ltp_lag_update bool
+	Ltp_lag_prev   bool
+	Ltp_lag        uint
+	Ltp_coef       uint8
+	Ltp_long_used  []bool
 }
 
 type pulse_data struct {
@@ -271,6 +385,7 @@ type pulse_data struct {
 	Pulse_amp       []uint8
 }
 
+// The raw HCR coded bits, decoded into the channel's Spectral_data
 type reordered_spectral_data struct {
 	Data []uint8
 }
@@ -433,10 +548,14 @@ type sbr_grid struct {
 	Bs_num_rel_1  []uint8
 	Bs_pointer    []uint
 
+	Bs_transient_position []uint8 // LD_TRAN frames only
+
 	bs_num_env    []uint8
 	bs_num_noise  []uint8
 	bs_rel_bord_0 [][]uint8
 	bs_rel_bord_1 [][]uint8
+
+	num_time_slots uint8
 }
 
 type sbr_dtdf struct {
@@ -481,14 +600,22 @@ type scale_factor_data struct {
 	Dcpm_noise_nrg   [][]uint16
 	Dcpm_sf          [][]uint8
 
-	Sf_concealment      bool
-	Rev_global_gain     uint8
-	Len_of_rvlc_sf      uint16
-	Rvlc_cod_sf         uint8
-	Sf_escapes_present  bool
-	Len_of_rvlc_escapes uint8
-	rvlc_esc_sf         uint8
-	Dcpm_noise_last_pos uint16
+	// RVLC coded scale factors of the aacScalefactorDataResilienceFlag.
+	// Rvlc_cod_sf and Rvlc_esc_sf hold the raw codeword segments, which are
+	// decoded into the Dcpm arrays above as DPCM indexes like hcod_sf's.
+	Sf_concealment        bool
+	Rev_global_gain       uint8
+	Len_of_rvlc_sf        uint16
+	Rvlc_cod_sf           []byte
+	Sf_escapes_present    bool
+	Len_of_rvlc_escapes   uint8
+	Rvlc_esc_sf           []byte
+	Dcpm_noise_last_pos   uint16
+	Dcpm_is_last_position uint8
+
+	// Set when the segment had errors and the values between the forward
+	// and backward decoded bands were concealed
+	Rvlc_concealed bool
 }
 
 type section_data struct {
@@ -500,12 +627,10 @@ type section_data struct {
 	num_sec    []uint8
 }
 
+// The tuples of every section in bitstream order, signs and escapes
+// applied.  QuantizedSpectrum places them at their windows and bins.
 type spectral_data struct {
-	Hcod           [][]int8
-	Quad_sign_bits uint8
-	Pair_sign_bits uint8
-	Hcod_esc_y     uint32
-	Hcod_esc_z     uint32
+	Hcod [][]int16
 }
 
 type tns_data struct {
@@ -673,14 +798,14 @@ var ChannelConfiguration = [...]string{
 	" 4: 4 channels: front-center, front-left, front-right, back-center",
 	" 5: 5 channels: front-center, front-left, front-right, back-left, back-right",
 	" 6: 6 channels: front-center, front-left, front-right, back-left, back-right, LFE-channel",
-	" 7: 8 channels: front-center, front-left, front-right, side-left, side-right, back-left, back-right, LFE-channel",
+	" 7: 8 channels: front-center, front-left-center, front-right-center, front-left, front-right, back-left, back-right, LFE-channel",
 	" 8: Reserved",
 	" 9: Reserved",
 	"10: Reserved",
-	"11: Reserved",
-	"12: Reserved",
-	"13: Reserved",
-	"14: Reserved",
+	"11: 7 channels: front-center, front-left, front-right, side-left, side-right, back-center, LFE-channel",
+	"12: 8 channels: front-center, front-left, front-right, side-left, side-right, back-left, back-right, LFE-channel",
+	"13: 24 channels: 22.2",
+	"14: 8 channels: front-center, front-left, front-right, back-left, back-right, LFE-channel, top-front-left, top-front-right",
 	"15: Reserved",
 }
 
@@ -694,6 +819,20 @@ const (
 	VARVAR = 3
 )
 
+// The second bs_frame_class of low delay SBR, kept apart from the ones above
+// in Bs_frame_class
+const (
+	LD_TRAN = 4
+)
+
+// QMF time slots of an SBR frame, numTimeSlots, for 1024 and 960 sample
+// frames, each RATE QMF subsamples long
+const (
+	SBR_NUM_TIME_SLOTS     = 16
+	SBR_NUM_TIME_SLOTS_960 = 15
+	SBR_RATE               = 2
+)
+
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.121 – Values of the extension_type field
 ////////////////////////////////////////////////////////////////////////////////
@@ -753,6 +892,7 @@ const (
 	INTENSITY_HCB2 = 14
 	INTENSITY_HCB  = 15
 	ESC_FLAG       = 16
+	FIRST_VCB11    = 16 // error resilience virtual codebooks 16..31
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -770,48 +910,71 @@ var Aac_PRED_SFB_MAX = [...]uint8{
 // MAIN PARSE FUNCTION
 ////////////////////////////////////////////////////////////////////////////////
 func ParseADTS(byteArray []byte) (*ADTS, error) {
-	adts := &ADTS{}
-	adts.reader = bitreader.NewBitReader(byteArray)
-	err := adts.adts_frame()
-	return adts, err
+	return ParseADTSWithOptions(byteArray, Options{})
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 1.A.5 – Syntax of adts_frame()
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) adts_frame() error {
+	var err error
 
-	if adts.reader.HasByteLeft() {
-		err := adts.adts_fixed_header()
-		if err != nil {
+	// Frame Length is fixed at 1024 for and ADTS
+	adts.Frame_length = 1024
+
+	if err = adts.adts_fixed_header(); err != nil {
+		return err
+	}
+	if err = adts.adts_variable_header(); err != nil {
+		return err
+	}
+
+	if adts.num_raw_data_blocks == 0 {
+		if adts.Error_check, err = adts.adts_error_check(); err != nil {
 			return err
 		}
+		_, err = adts.raw_data_block()
+		return adts.lenient(err)
 	}
 
-	if adts.reader.HasByteLeft() {
-		adts.adts_variable_header()
+	if adts.Header_error_check, err = adts.adts_header_error_check(); err != nil {
+		return err
 	}
-	// Frame Length is fixed at 1024 for and ADTS
-	adts.Frame_length = 1024
-	if adts.num_raw_data_blocks == 0 {
-		adts.adts_error_check()
-		err := adts.raw_data_block()
+	for i := uint8(0); i <= adts.num_raw_data_blocks; i++ {
+		block, err := adts.raw_data_block()
+		if positions := adts.Header_error_check.Raw_data_block_position; positions != nil {
+			block.Position = positions[i]
+		}
 		if err != nil {
-			return err
+			return adts.lenient(err)
 		}
-	} else {
-		adts.adts_header_error_check()
-		for i := uint8(0); i <= adts.num_raw_data_blocks; i++ {
-			err := adts.raw_data_block()
-			if err != nil {
-				return err
-			}
-			adts.adts_raw_data_block_error_check()
+		if block.Error_check, err = adts.adts_raw_data_block_error_check(); err != nil {
+			return adts.lenient(err)
 		}
 	}
 	return nil
 }
 
+// Number of raw_data_blocks (access units) carried by the frame as signaled
+// in the header, regardless of how many were successfully parsed.
+func (adts *ADTS) NumRawDataBlocks() int {
+	return int(adts.num_raw_data_blocks) + 1
+}
+
+// Number of PCM samples per channel the frame decodes to.  Each
+// raw_data_block is one access unit of Frame_length samples.
+func (adts *ADTS) Samples() int {
+	return adts.NumRawDataBlocks() * int(adts.Frame_length)
+}
+
+// Playback duration of the frame
+func (adts *ADTS) Duration() time.Duration {
+	if adts.SamplingFrequency == 0 {
+		return 0
+	}
+	return time.Duration(adts.Samples()) * time.Second / time.Duration(adts.SamplingFrequency)
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // Table 1.A.6 – Syntax of adts_fixed_header()
 ////////////////////////////////////////////////////////////////////////////////
@@ -829,91 +992,100 @@ func (adts *ADTS) adts_fixed_header() error {
 		}
 	}
 
-	if adts.reader.HasBytesLeft(2) {
-		adts.MpegVersion, _ = adts.reader.ReadBit()    // mpeg version
-		adts.Layer, _ = adts.reader.ReadBitsAsUInt8(2) // layer; always 0
-		if adts.Layer != 0 {
-			return fmt.Errorf("ADTS Layer (%d) must be 0", adts.Layer)
-		}
-		adts.protection_absent, _ = adts.reader.ReadBitAsBool() // protection_absent
-		adts.Profile, _ = adts.reader.ReadBitsAsUInt8(2)        // profile object
-		adts.Profile += uint8(1)                                // profile object
-		adts.sfi, _ = adts.reader.ReadBitsAsUInt8(4)            // sampling_frequency_index
-		if adts.sfi > 12 {
-			return fmt.Errorf("Sampling Frequency Index (%d) out of acceptable range (0-12)", adts.sfi)
-		}
+	if sync_word_count < 3 {
+		return adts.check("adts_fixed_header", fmt.Errorf("Error: ADTS syncword not found"))
+	}
 
-		adts.SamplingFrequency = SamplingFrequency[adts.sfi]          // sampling frequency
-		adts.reader.SkipBits(1)                                       // private
-		adts.ChannelConfiguration, _ = adts.reader.ReadBitsAsUInt8(3) // channel_configuration
-		adts.reader.SkipBits(1)                                       // original
-		adts.reader.SkipBits(1)                                       // home
+	adts.MpegVersion, _ = adts.reader.ReadBit()    // mpeg version
+	adts.Layer, _ = adts.reader.ReadBitsAsUInt8(2) // layer; always 0
+	if err := adts.reserved(adts.Layer != 0, "Error: ADTS Layer (%d) must be 0", adts.Layer); err != nil {
+		return adts.check("adts_fixed_header", err)
+	}
+	adts.protection_absent, _ = adts.reader.ReadBitAsBool() // protection_absent
+	adts.Profile, _ = adts.reader.ReadBitsAsUInt8(2)        // profile object
+	adts.Profile += uint8(1)                                // profile object
+	adts.sfi, _ = adts.reader.ReadBitsAsUInt8(4)            // sampling_frequency_index
+	if adts.sfi > 12 {
+		return adts.check("adts_fixed_header", parseErrorf(ErrReserved, "Error: Sampling Frequency Index (%d) out of acceptable range (0-12)", adts.sfi))
 	}
 
-	return nil
+	adts.SamplingFrequency = SamplingFrequency[adts.sfi]          // sampling frequency
+	adts.reader.SkipBits(1)                                       // private
+	adts.ChannelConfiguration, _ = adts.reader.ReadBitsAsUInt8(3) // channel_configuration
+	adts.reader.SkipBits(1)                                       // original
+	adts.reader.SkipBits(1)                                       // home
+
+	return adts.check("adts_fixed_header", nil)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 1.A.7 – Syntax of adts_variable_header()
 ////////////////////////////////////////////////////////////////////////////////
-func (adts *ADTS) adts_variable_header() {
-	if adts.reader.HasBytesLeft(4) {
-		adts.reader.SkipBits(1)                                      // copyright_id
-		adts.reader.SkipBits(1)                                      // copyright_id_start
-		adts.aac_frame_length, _ = adts.reader.ReadBitsAsUInt16(13)  // aac_frame_length
-		adts_buffer_fullness, _ := adts.reader.ReadBitsAsUInt16(11)  // adts_buffer_fullness
-		adts.num_raw_data_blocks, _ = adts.reader.ReadBitsAsUInt8(2) // num_raw_data_blocks
-
-		if adts_buffer_fullness == 0x7ff {
-			adts.VbrMode = true
-		} else {
-			adts.VbrMode = false
-		}
+func (adts *ADTS) adts_variable_header() error {
+	adts.reader.SkipBits(1)                                      // copyright_id
+	adts.reader.SkipBits(1)                                      // copyright_id_start
+	adts.aac_frame_length, _ = adts.reader.ReadBitsAsUInt16(13)  // aac_frame_length
+	adts_buffer_fullness, _ := adts.reader.ReadBitsAsUInt16(11)  // adts_buffer_fullness
+	adts.num_raw_data_blocks, _ = adts.reader.ReadBitsAsUInt8(2) // num_raw_data_blocks
+	if err := adts.check("adts_variable_header", nil); err != nil {
+		return err
+	}
 
-		// ADTS is locked at 1024 samples
-		adts.Bitrate = adts.SamplingFrequency / 1024
-		adts.Bitrate *= uint32(adts.aac_frame_length) * 8
+	if adts_buffer_fullness == 0x7ff {
+		adts.VbrMode = true
+	} else {
+		adts.VbrMode = false
 	}
+
+	adts.Bitrate = adts.SamplingFrequency / uint32(adts.Frame_length)
+	adts.Bitrate *= uint32(adts.aac_frame_length) * 8
+	adts.Bitrate /= uint32(adts.num_raw_data_blocks) + 1
+	return nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 1.A.8 – Syntax of adts_error_check
 ////////////////////////////////////////////////////////////////////////////////
-func (adts *ADTS) adts_error_check() {
+func (adts *ADTS) adts_error_check() (*adts_error_check, error) {
+	data := adts.mem.adts_error_checks.new()
 	if !adts.protection_absent {
-		adts.reader.SkipBits(16) // crc_check;
+		data.Crc_check, _ = adts.reader.ReadBitsAsUInt16(16) // crc_check
 	}
+	return data, adts.check("adts_error_check", nil)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 1.A.9 – Syntax of adts_header_error_check
 ////////////////////////////////////////////////////////////////////////////////
-func (adts *ADTS) adts_header_error_check() {
-	data := &adts_header_error_check{}
+func (adts *ADTS) adts_header_error_check() (*adts_header_error_check, error) {
+	data := adts.mem.adts_header_error_checks.new()
 
 	if !adts.protection_absent {
-		data.Raw_data_block_position = make([]uint16, adts.num_raw_data_blocks+1)
+		data.Raw_data_block_position = adts.mem.uint16s.make(int(adts.num_raw_data_blocks) + 1)
 		for i := uint8(1); i <= adts.num_raw_data_blocks; i++ {
 			data.Raw_data_block_position[i], _ = adts.reader.ReadBitsAsUInt16(16) // raw_data_block_position
 		}
 		data.Crc_check, _ = adts.reader.ReadBitsAsUInt16(16) // crc_check
 	}
+	return data, adts.check("adts_header_error_check", nil)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 1.A.10 – Syntax of adts_raw_data_block_error_check()
 ////////////////////////////////////////////////////////////////////////////////
-func (adts *ADTS) adts_raw_data_block_error_check() {
+func (adts *ADTS) adts_raw_data_block_error_check() (*adts_raw_data_block_error_check, error) {
+	data := adts.mem.adts_raw_data_block_error_checks.new()
 	if !adts.protection_absent {
-		adts.reader.SkipBits(16) // crc_check
+		data.Crc_check, _ = adts.reader.ReadBitsAsUInt16(16) // crc_check
 	}
+	return data, adts.check("adts_raw_data_block_error_check", nil)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.2 – Syntax of program_config_element()
 ////////////////////////////////////////////////////////////////////////////////
-func (adts *ADTS) program_config_element() *program_config_element {
-	e := &program_config_element{}
+func (adts *ADTS) program_config_element() (*program_config_element, error) {
+	e := adts.mem.program_config_elements.new()
 	e.Element_instance_tag, _ = adts.reader.ReadBitsAsUInt8(4) // element_instance_tag
 
 	e.Object_type, _ = adts.reader.ReadBitsAsUInt8(2)                // object_type
@@ -924,6 +1096,10 @@ func (adts *ADTS) program_config_element() *program_config_element {
 	e.Num_lfe_channel_elements, _ = adts.reader.ReadBitsAsUInt8(2)   // num_lfe_channel_elements
 	e.Num_assoc_data_elements, _ = adts.reader.ReadBitsAsUInt8(3)    // num_assoc_data_elements;
 	e.Num_valid_cc_elements, _ = adts.reader.ReadBitsAsUInt8(4)      // num_valid_cc_elements
+	if err := adts.strict(e.Sampling_frequency_index != adts.sfi, ErrOutOfRange,
+		"Error: sampling_frequency_index (%d) differs from the header (%d)", e.Sampling_frequency_index, adts.sfi); err != nil {
+		return e, adts.check("program_config_element", err)
+	}
 
 	if e.Mono_mixdown_present, _ = adts.reader.ReadBitAsBool(); e.Mono_mixdown_present {
 		e.Mono_mixdown_element_num, _ = adts.reader.ReadBitsAsUInt8(4) // mono_mixdown_element_number
@@ -936,106 +1112,249 @@ func (adts *ADTS) program_config_element() *program_config_element {
 		e.Pseudo_surround_enable, _ = adts.reader.ReadBitAsBool() // pseudo_surround_enable
 	}
 
-	e.Front_element_is_cpe = make([]bool, e.Num_front_channel_elements)
-	e.Front_element_tag_select = make([]uint8, e.Num_front_channel_elements)
+	e.Front_element_is_cpe = adts.mem.bools.make(int(e.Num_front_channel_elements))
+	e.Front_element_tag_select = adts.mem.uint8s.make(int(e.Num_front_channel_elements))
 	for i := range e.Front_element_tag_select {
 		e.Front_element_is_cpe[i], _ = adts.reader.ReadBitAsBool()        // front_element_is_cpe[i]
 		e.Front_element_tag_select[i], _ = adts.reader.ReadBitsAsUInt8(4) // front_element_tag_select[i]
 	}
 
-	e.Side_element_is_cpe = make([]bool, e.Num_side_channel_elements)
-	e.Side_element_tag_select = make([]uint8, e.Num_side_channel_elements)
+	e.Side_element_is_cpe = adts.mem.bools.make(int(e.Num_side_channel_elements))
+	e.Side_element_tag_select = adts.mem.uint8s.make(int(e.Num_side_channel_elements))
 	for i := range e.Side_element_tag_select {
 		e.Side_element_is_cpe[i], _ = adts.reader.ReadBitAsBool()        // side_element_is_cpe[i]
 		e.Side_element_tag_select[i], _ = adts.reader.ReadBitsAsUInt8(4) // side_element_tag_select[i]
 	}
 
-	e.Back_element_is_cpe = make([]bool, e.Num_back_channel_elements)
-	e.Back_element_tag_select = make([]uint8, e.Num_back_channel_elements)
+	e.Back_element_is_cpe = adts.mem.bools.make(int(e.Num_back_channel_elements))
+	e.Back_element_tag_select = adts.mem.uint8s.make(int(e.Num_back_channel_elements))
 	for i := range e.Back_element_tag_select {
 		e.Back_element_is_cpe[i], _ = adts.reader.ReadBitAsBool()        // back_element_is_cpe[i]
 		e.Back_element_tag_select[i], _ = adts.reader.ReadBitsAsUInt8(4) // back_element_tag_select[i]
 	}
 
-	e.Lfe_element_tag_select = make([]uint8, e.Num_lfe_channel_elements)
+	e.Lfe_element_tag_select = adts.mem.uint8s.make(int(e.Num_lfe_channel_elements))
 	for i := range e.Lfe_element_tag_select {
 		e.Lfe_element_tag_select[i], _ = adts.reader.ReadBitsAsUInt8(4) // lfe_element_tag_select[i]
 	}
 
-	e.Assoc_data_element_tag_select = make([]uint8, e.Num_assoc_data_elements)
+	e.Assoc_data_element_tag_select = adts.mem.uint8s.make(int(e.Num_assoc_data_elements))
 	for i := range e.Assoc_data_element_tag_select {
 		e.Assoc_data_element_tag_select[i], _ = adts.reader.ReadBitsAsUInt8(4) // assoc_data_element_tag_select[i]
 	}
 
-	e.Cc_element_is_ind_sw = make([]bool, e.Num_valid_cc_elements)
-	e.Valid_cc_element_tag_select = make([]uint8, e.Num_valid_cc_elements)
+	e.Cc_element_is_ind_sw = adts.mem.bools.make(int(e.Num_valid_cc_elements))
+	e.Valid_cc_element_tag_select = adts.mem.uint8s.make(int(e.Num_valid_cc_elements))
 	for i := range e.Valid_cc_element_tag_select {
 		e.Cc_element_is_ind_sw[i], _ = adts.reader.ReadBitAsBool()           // cc_element_is_ind_sw[i]
 		e.Valid_cc_element_tag_select[i], _ = adts.reader.ReadBitsAsUInt8(4) // valid_cc_element_tag_select[i]
 	}
 
 	adts.reader.ByteAlign()
-	e.Comment_field_bytes, _ = adts.reader.ReadBitsAsUInt8(8)                                  // comment_field_bytes
-	e.Comment_field_data, _ = adts.reader.ReadBitsToByteArray(uint(e.Comment_field_bytes) * 8) // comment_field_data[i]
+	e.Comment_field_bytes, _ = adts.reader.ReadBitsAsUInt8(8)                               // comment_field_bytes
+	e.Comment_field_data, _ = adts.read_bits_to_byte_array(uint(e.Comment_field_bytes) * 8) // comment_field_data[i]
 
-	return e
+	return e, adts.check("program_config_element", nil)
 }
 
+// Names of the raw_data_blocks of a frame in errors, it has at most 4
+var raw_data_block_paths = [...]string{"raw_data_block[0]", "raw_data_block[1]", "raw_data_block[2]", "raw_data_block[3]"}
+
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.3 – Syntax of top level payload for audio object types AAC Main,
 //             SSR, LC, and LTP (raw_data_block())
 ////////////////////////////////////////////////////////////////////////////////
-func (adts *ADTS) raw_data_block() error {
+func (adts *ADTS) raw_data_block() (*raw_data_block, error) {
 	var err error
 	var id_syn_ele uint8 = 0
 	var id_syn_ele_Previous uint8
 
+	block := adts.mem.raw_data_blocks.new()
+	adts.Raw_data_blocks = adts.mem.raw_data_block_list.append(adts.Raw_data_blocks, block)
+	path := raw_data_block_paths[len(adts.Raw_data_blocks)-1]
+
+	// Elements of each type so far, to name them in errors
+	var count [8]int
 	for id_syn_ele != ID_END {
 		id_syn_ele_Previous = id_syn_ele
 		id_syn_ele, _ = adts.reader.ReadBits(3)
+		if err = adts.check(path, nil); err != nil {
+			return block, block.fail(nil, err)
+		}
+		if max := adts.options.MaxElements; max > 0 && id_syn_ele != ID_END && len(block.Elements) >= max {
+			err = adts.check(path, parseErrorf(ErrOutOfRange, "Error: raw_data_block has more than %d elements", max))
+			return block, block.fail(nil, err)
+		}
+
+		before := len(block.Elements)
 
 		switch id_syn_ele {
 		case ID_SCE:
 			var e *single_channel_element
 			e, err = adts.single_channel_element()
-			adts.Single_channel_elements = append(adts.Single_channel_elements, e)
+			adts.Single_channel_elements = adts.mem.single_channel_element_list.append(adts.Single_channel_elements, e)
+			block.Elements = adts.mem.elements.append(block.Elements, e)
 		case ID_CPE:
 			var e *channel_pair_element
 			e, err = adts.channel_pair_element()
-			adts.Channel_pair_elements = append(adts.Channel_pair_elements, e)
+			adts.Channel_pair_elements = adts.mem.channel_pair_element_list.append(adts.Channel_pair_elements, e)
+			block.Elements = adts.mem.elements.append(block.Elements, e)
 		case ID_CCE:
 			var e *coupling_channel_element
 			e, err = adts.coupling_channel_element()
-			adts.Coupling_channel_elements = append(adts.Coupling_channel_elements, e)
+			adts.Coupling_channel_elements = adts.mem.coupling_channel_element_list.append(adts.Coupling_channel_elements, e)
+			block.Elements = adts.mem.elements.append(block.Elements, e)
 		case ID_LFE:
 			var e *lfe_channel_element
 			e, err = adts.lfe_channel_element()
-			adts.Lfe_channel_elements = append(adts.Lfe_channel_elements, e)
+			adts.Lfe_channel_elements = adts.mem.lfe_channel_element_list.append(adts.Lfe_channel_elements, e)
+			block.Elements = adts.mem.elements.append(block.Elements, e)
 		case ID_DSE:
-			e := adts.data_stream_element()
-			adts.Data_stream_elements = append(adts.Data_stream_elements, e)
+			var e *data_stream_element
+			e, err = adts.data_stream_element()
+			adts.Data_stream_elements = adts.mem.data_stream_element_list.append(adts.Data_stream_elements, e)
+			block.Elements = adts.mem.elements.append(block.Elements, e)
 		case ID_PCE:
-			e := adts.program_config_element()
-			adts.Program_config_elements = append(adts.Program_config_elements, e)
+			var e *program_config_element
+			e, err = adts.program_config_element()
+			adts.Program_config_elements = adts.mem.program_config_element_list.append(adts.Program_config_elements, e)
+			block.Elements = adts.mem.elements.append(block.Elements, e)
 		case ID_FIL:
 			var e *fill_element
 			e, err = adts.fill_element(id_syn_ele_Previous)
-			adts.Fill_elements = append(adts.Fill_elements, e)
+			adts.Fill_elements = adts.mem.fill_element_list.append(adts.Fill_elements, e)
+			block.Elements = adts.mem.elements.append(block.Elements, e)
 		case ID_END:
+			block.Elements = adts.mem.elements.append(block.Elements, adts.mem.end_elements.new())
 		default:
-			err = fmt.Errorf("Error: Unsupported id_syn_ele: %d", id_syn_ele)
+			err = parseErrorf(ErrUnsupported, "Error: Unsupported id_syn_ele: %d", id_syn_ele)
+		}
+
+		var failed Element
+		if err != nil && len(block.Elements) > before {
+			failed = block.Elements[before]
+		}
+		if err == nil && id_syn_ele != ID_END && adts.reader.HasBitLeft() == false {
+			err = parseErrorf(ErrTruncated, "Error: Buffer empty parsing id_syn_ele %d", id_syn_ele)
 		}
+		if err != nil {
+			err = adts.check(path, within(path+"/"+elementPath(id_syn_ele, count[id_syn_ele]), err))
+			return block, block.fail(failed, err)
+		}
+		count[id_syn_ele]++
+	}
 
-		if id_syn_ele != ID_END && adts.reader.HasBitLeft() == false {
-			err = fmt.Errorf("Error: Buffer empty parsing id_syn_ele %d", id_syn_ele)
+	adts.reader.ByteAlign()
+	if err = adts.check(path, nil); err != nil {
+		return block, block.fail(nil, err)
+	}
+	return block, nil
+}
+
+// Elements of an er_raw_data_block() for each channelConfiguration, which
+// have no id_syn_ele
+var er_raw_data_block_elements = [][]uint8{
+	{},
+	{ID_SCE},
+	{ID_CPE},
+	{ID_SCE, ID_CPE},
+	{ID_SCE, ID_CPE, ID_SCE},
+	{ID_SCE, ID_CPE, ID_CPE},
+	{ID_SCE, ID_CPE, ID_CPE, ID_LFE},
+	{ID_SCE, ID_CPE, ID_CPE, ID_CPE, ID_LFE},
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Syntax of top level payload for the ER AAC object types (er_raw_data_block())
+////////////////////////////////////////////////////////////////////////////////
+func (adts *ADTS) er_raw_data_block() (*raw_data_block, error) {
+	var err error
+	block := adts.mem.raw_data_blocks.new()
+	adts.Raw_data_blocks = adts.mem.raw_data_block_list.append(adts.Raw_data_blocks, block)
+	// Parsed without ADTS headers, one block at a time
+	path := "er_raw_data_block[0]"
+
+	if adts.ChannelConfiguration == 0 || int(adts.ChannelConfiguration) >= len(er_raw_data_block_elements) {
+		err = adts.check(path, parseErrorf(ErrUnsupported, "Error: er_raw_data_block channel configuration (%d) is not supported", adts.ChannelConfiguration))
+		return block, block.fail(nil, err)
+	}
+
+	// SBR payloads follow in the order of the SCEs and CPEs
+	sbr_elements := make([]uint8, 0, 8)
+	var count [8]int
+	for _, id_syn_ele := range er_raw_data_block_elements[adts.ChannelConfiguration] {
+		switch id_syn_ele {
+		case ID_SCE:
+			var e *single_channel_element
+			e, err = adts.single_channel_element()
+			adts.Single_channel_elements = adts.mem.single_channel_element_list.append(adts.Single_channel_elements, e)
+			block.Elements = adts.mem.elements.append(block.Elements, e)
+		case ID_CPE:
+			var e *channel_pair_element
+			e, err = adts.channel_pair_element()
+			adts.Channel_pair_elements = adts.mem.channel_pair_element_list.append(adts.Channel_pair_elements, e)
+			block.Elements = adts.mem.elements.append(block.Elements, e)
+		case ID_LFE:
+			var e *lfe_channel_element
+			e, err = adts.lfe_channel_element()
+			adts.Lfe_channel_elements = adts.mem.lfe_channel_element_list.append(adts.Lfe_channel_elements, e)
+			block.Elements = adts.mem.elements.append(block.Elements, e)
 		}
 		if err != nil {
+			err = adts.check(path, within(path+"/"+elementPath(id_syn_ele, count[id_syn_ele]), err))
+			return block, block.fail(block.Elements[len(block.Elements)-1], err)
+		}
+		count[id_syn_ele]++
+		if id_syn_ele != ID_LFE {
+			sbr_elements = append(sbr_elements, id_syn_ele)
+		}
+	}
+
+	// The rest of the block is extension payloads, kept as fill elements
+	// so they're found the same way as in a raw_data_block
+	adts.ld_sbr_element = 0
+	for cnt := int(adts.reader.BitsLeft() / 8); cnt >= 1; {
+		id_syn_ele := uint8(ID_SCE)
+		if adts.ld_sbr_element < len(sbr_elements) {
+			id_syn_ele = sbr_elements[adts.ld_sbr_element]
+		}
+
+		e := adts.mem.fill_elements.new()
+		var sub int
+		err = adts.limit(uint(8*cnt), func() (err error) {
+			sub, e.Extension_payload, err = adts.extension_payload(cnt, id_syn_ele)
 			return err
+		})
+		e.Count = uint16(sub)
+		adts.Fill_elements = adts.mem.fill_element_list.append(adts.Fill_elements, e)
+		block.Elements = adts.mem.elements.append(block.Elements, e)
+		if err != nil {
+			err = adts.check(path, within(path+"/"+elementPath(ID_FIL, count[ID_FIL]), err))
+			return block, block.fail(e, err)
 		}
+		count[ID_FIL]++
+		if sub <= 0 {
+			break
+		}
+		cnt -= sub
 	}
 
+	block.Elements = adts.mem.elements.append(block.Elements, adts.mem.end_elements.new())
 	adts.reader.ByteAlign()
-	return nil
+	if err = adts.check(path, nil); err != nil {
+		return block, block.fail(nil, err)
+	}
+	return block, nil
+}
+
+// Object types using the error resilient bitstream syntax
+func (adts *ADTS) er_syntax() bool {
+	switch adts.Profile {
+	case AUDIO_OBJECT_TYPE_ER, AUDIO_OBJECT_TYPE_ER_AAC_LTP, AUDIO_OBJECT_TYPE_ER_AAC_SCALABLE,
+		AUDIO_OBJECT_TYPE_ER_AAC_LD, AUDIO_OBJECT_TYPE_ER_AAC_ELD:
+		return true
+	}
+	return false
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -1043,10 +1362,12 @@ func (adts *ADTS) raw_data_block() error {
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) single_channel_element() (*single_channel_element, error) {
 	var err error
-	e := &single_channel_element{}
-	e.Element_instance_tag, _ = adts.reader.ReadBitsAsUInt8(4)                // element_instance_tag
+	e := adts.mem.single_channel_elements.new()
+	if adts.Profile != AUDIO_OBJECT_TYPE_ER_AAC_ELD {
+		e.Element_instance_tag, _ = adts.reader.ReadBitsAsUInt8(4) // element_instance_tag
+	}
 	e.Channel_stream, err = adts.individual_channel_stream(false, false, nil) // individual_channel_stream(0,0)
-	return e, err
+	return e, adts.check("single_channel_element", within("ics[0]", err))
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -1054,24 +1375,26 @@ func (adts *ADTS) single_channel_element() (*single_channel_element, error) {
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) channel_pair_element() (*channel_pair_element, error) {
 	var err error
-	e := &channel_pair_element{}
-	e.Element_instance_tag, _ = adts.reader.ReadBitsAsUInt8(4) // element_instance_tag
+	e := adts.mem.channel_pair_elements.new()
+	if adts.Profile != AUDIO_OBJECT_TYPE_ER_AAC_ELD {
+		e.Element_instance_tag, _ = adts.reader.ReadBitsAsUInt8(4) // element_instance_tag
+	}
 
 	e.Common_window, _ = adts.reader.ReadBitAsBool() // common_window
 	if e.Common_window {
 		e.Ics_info, err = adts.ics_info(e.Common_window)
 		if err != nil {
-			return e, err
+			return e, adts.check("channel_pair_element", err)
 		}
 		ms_mask_present, _ := adts.reader.ReadBitsAsUInt8(2) // ms_mask_present
-		if ms_mask_present == 3 {
-			return e, fmt.Errorf("Error: ms_mask_present (%d) out of range", ms_mask_present)
+		if err = adts.reserved(ms_mask_present == 3, "Error: ms_mask_present (%d) out of range", ms_mask_present); err != nil {
+			return e, adts.check("channel_pair_element", err)
 		}
 
 		if ms_mask_present == 1 {
-			e.Ms_used = make([][]bool, e.Ics_info.num_window_groups)
+			e.Ms_used = adts.mem.bools_2d.make(int(e.Ics_info.num_window_groups))
 			for g, _ := range e.Ms_used {
-				e.Ms_used[g] = make([]bool, e.Ics_info.Max_sfb)
+				e.Ms_used[g] = adts.mem.bools.make(int(e.Ics_info.Max_sfb))
 				for sfb, _ := range e.Ms_used[g] {
 					e.Ms_used[g][sfb], _ = adts.reader.ReadBitAsBool() // ms_used[g][sfb]
 				}
@@ -1081,11 +1404,11 @@ func (adts *ADTS) channel_pair_element() (*channel_pair_element, error) {
 
 	e.Channel_stream1, err = adts.individual_channel_stream(e.Common_window, false, e.Ics_info)
 	if err != nil {
-		return e, err
+		return e, adts.check("channel_pair_element", within("ics[0]", err))
 	}
 
 	e.Channel_stream2, err = adts.individual_channel_stream(e.Common_window, false, e.Ics_info)
-	return e, err
+	return e, adts.check("channel_pair_element", within("ics[1]", err))
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -1093,36 +1416,50 @@ func (adts *ADTS) channel_pair_element() (*channel_pair_element, error) {
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) ics_info(common_window bool) (*ics_info, error) {
 	var err error
-	info := &ics_info{}
-	if ics_reserved_bit, _ := adts.reader.ReadBitsAsUInt8(1); ics_reserved_bit != 0 {
-		err = fmt.Errorf("Error: ics_reserved_bit must equal 0")
-		return nil, err
-	}
+	info := adts.mem.ics_infos.new()
+
+	// ER AAC ELD only has long windows of its own low delay shape and
+	// sends neither
+	if adts.Profile != AUDIO_OBJECT_TYPE_ER_AAC_ELD {
+		ics_reserved_bit, _ := adts.reader.ReadBitsAsUInt8(1)
+		if err = adts.reserved(ics_reserved_bit != 0, "Error: ics_reserved_bit must equal 0"); err != nil {
+			return nil, adts.check("ics_info", err)
+		}
 
-	info.Window_sequence, _ = adts.reader.ReadBits(2)     // window_sequence
-	info.Window_shape, _ = adts.reader.ReadBitsAsUInt8(1) // window_shape
+		info.Window_sequence, _ = adts.reader.ReadBits(2)     // window_sequence
+		info.Window_shape, _ = adts.reader.ReadBitsAsUInt8(1) // window_shape
+		if adts.Profile == AUDIO_OBJECT_TYPE_ER_AAC_LD && info.Window_sequence != ONLY_LONG_SEQUENCE {
+			return nil, adts.check("ics_info", fmt.Errorf("Error: window_sequence (%d) must be ONLY_LONG_SEQUENCE in ER AAC LD", info.Window_sequence))
+		}
+	}
 
 	if info.Window_sequence == EIGHT_SHORT_SEQUENCE {
 		info.Max_sfb, _ = adts.reader.ReadBits(4)
 		info.Scale_factor_grouping, _ = adts.reader.ReadBits(7) // scale_factor_grouping
 
-		window_grouping(info, adts.sfi, adts.Frame_length)
+		window_grouping(&adts.mem, info, adts.sfi, adts.Frame_length)
 		if info.Max_sfb > info.num_swb {
 			err = fmt.Errorf("Error: ics_info.Max_sfb (%d) must be less than ics_info.num_swb (%d)",
 				info.Max_sfb, info.num_swb)
-			return nil, err
+			return nil, adts.check("ics_info", err)
 		}
 	} else {
-		window_grouping(info, adts.sfi, adts.Frame_length)
+		window_grouping(&adts.mem, info, adts.sfi, adts.Frame_length)
+		if info.num_swb == 0 {
+			return nil, adts.check("ics_info", parseErrorf(ErrUnsupported, "Error: %d sample frames have no scalefactor bands at %d Hz",
+				adts.Frame_length, adts.SamplingFrequency))
+		}
 
 		info.Max_sfb, _ = adts.reader.ReadBits(6)
 		if info.Max_sfb > info.num_swb {
 			err = fmt.Errorf("Error: ics_info.Max_sfb (%d) must be less than ics_info.num_swb (%d)",
 				info.Max_sfb, info.num_swb)
-			return nil, err
+			return nil, adts.check("ics_info", err)
 		}
 
-		info.Predictor_data_present, _ = adts.reader.ReadBitAsBool()
+		if adts.Profile != AUDIO_OBJECT_TYPE_ER_AAC_ELD {
+			info.Predictor_data_present, _ = adts.reader.ReadBitAsBool()
+		}
 		if info.Predictor_data_present == true {
 			if adts.Profile == AUDIO_OBJECT_TYPE_AAC_MAIN {
 				info.Predictor_reset, _ = adts.reader.ReadBitAsBool()
@@ -1131,41 +1468,45 @@ func (adts *ADTS) ics_info(common_window bool) (*ics_info, error) {
 				}
 
 				PRED_SFB_MAX := minInt(int(info.Max_sfb), int(Aac_PRED_SFB_MAX[adts.sfi]))
-				info.Prediction_used = make([]bool, PRED_SFB_MAX)
+				info.Prediction_used = adts.mem.bools.make(PRED_SFB_MAX)
 				for sfb := range info.Prediction_used {
 					info.Prediction_used[sfb], _ = adts.reader.ReadBitAsBool()
 				}
 
 			} else {
 				if info.Ltp_data_present, _ = adts.reader.ReadBitAsBool(); info.Ltp_data_present {
-					info.Ltp_data, err = adts.ltp_data(info)
+					if info.Ltp_data, err = adts.ltp_data(info); err != nil {
+						return nil, adts.check("ics_info", err)
+					}
 				}
 				if common_window {
-					if info.Ltp_data_present, _ = adts.reader.ReadBitAsBool(); info.Ltp_data_present {
-						info.Ltp_data, err = adts.ltp_data(info)
+					if info.Ltp_data2_present, _ = adts.reader.ReadBitAsBool(); info.Ltp_data2_present {
+						if info.Ltp_data2, err = adts.ltp_data(info); err != nil {
+							return nil, adts.check("ics_info", err)
+						}
 					}
 				}
 			}
 		}
 	}
-	return info, err
+	return info, adts.check("ics_info", err)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.7 – Syntax of pulse_data()
 ////////////////////////////////////////////////////////////////////////////////
-func (adts *ADTS) pulse_data() *pulse_data {
-	data := &pulse_data{}
+func (adts *ADTS) pulse_data() (*pulse_data, error) {
+	data := adts.mem.pulse_datas.new()
 	data.Number_pulse, _ = adts.reader.ReadBitsAsUInt8(2)    // number_pulse
 	data.Pulse_start_sfb, _ = adts.reader.ReadBitsAsUInt8(6) // pulse_start_sfb
-	data.Pulse_amp = make([]uint8, data.Number_pulse)
-	data.Pulse_offset = make([]uint8, data.Number_pulse)
+	data.Pulse_amp = adts.mem.uint8s.make(int(data.Number_pulse + 1))
+	data.Pulse_offset = adts.mem.uint8s.make(int(data.Number_pulse + 1))
 	for i := range data.Pulse_amp {
 		data.Pulse_offset[i], _ = adts.reader.ReadBitsAsUInt8(5) // pulse_offset[i]
 		data.Pulse_amp[i], _ = adts.reader.ReadBitsAsUInt8(4)    // pulse_amp[i]
 	}
 
-	return data
+	return data, adts.check("pulse_data", nil)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -1173,23 +1514,24 @@ func (adts *ADTS) pulse_data() *pulse_data {
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) coupling_channel_element() (*coupling_channel_element, error) {
 	var err error
-	e := &coupling_channel_element{}
+	e := adts.mem.coupling_channel_elements.new()
 	e.Element_instance_tag, _ = adts.reader.ReadBitsAsUInt8(4) // element_instance_tag
 
 	e.Ind_sw_cce_flag, _ = adts.reader.ReadBitAsBool()         // ind_sw_cce_flag
 	e.Num_coupled_elements, _ = adts.reader.ReadBitsAsUInt8(3) // num_coupled_elements
 	num_gain_element_lists := 0
 
-	e.Cc_target_is_cpe = make([]bool, e.Num_coupled_elements)
-	e.Cc_target_tag_select = make([]uint8, e.Num_coupled_elements)
+	// num_coupled_elements is one less than the number of targets
+	e.Cc_target_is_cpe = adts.mem.bools.make(int(e.Num_coupled_elements + 1))
+	e.Cc_target_tag_select = adts.mem.uint8s.make(int(e.Num_coupled_elements + 1))
 	for c, _ := range e.Cc_target_is_cpe {
 		num_gain_element_lists++
 		e.Cc_target_is_cpe[c], _ = adts.reader.ReadBitAsBool()        // cc_target_is_cpe[c]
 		e.Cc_target_tag_select[c], _ = adts.reader.ReadBitsAsUInt8(4) // cc_target_tag_select[c]
 		if e.Cc_target_is_cpe[c] {
 			if e.Cc_l == nil && e.Cc_r == nil {
-				e.Cc_l = make([]bool, e.Num_coupled_elements)
-				e.Cc_r = make([]bool, e.Num_coupled_elements)
+				e.Cc_l = adts.mem.bools.make(int(e.Num_coupled_elements + 1))
+				e.Cc_r = adts.mem.bools.make(int(e.Num_coupled_elements + 1))
 			}
 			e.Cc_l[c], _ = adts.reader.ReadBitAsBool() // cc_l[c]
 			e.Cc_r[c], _ = adts.reader.ReadBitAsBool() // cc_r[c]
@@ -1205,12 +1547,12 @@ func (adts *ADTS) coupling_channel_element() (*coupling_channel_element, error)
 
 	e.Channel_stream, err = adts.individual_channel_stream(false, false, nil)
 	if err != nil {
-		return e, err
+		return e, adts.check("coupling_channel_element", within("ics[0]", err))
 	}
 
-	e.Common_gain_element_present = make([]bool, num_gain_element_lists)
-	e.Common_gain_element = make([]uint8, num_gain_element_lists)
-	e.DCPM_gain_element = make([][][]uint8, num_gain_element_lists)
+	e.Common_gain_element_present = adts.mem.bools.make(num_gain_element_lists)
+	e.Common_gain_element = adts.mem.uint8s.make(num_gain_element_lists)
+	e.DCPM_gain_element = adts.mem.uint8s_3d.make(num_gain_element_lists)
 	for c := 1; c < num_gain_element_lists; c++ {
 		cge := false
 		if e.Ind_sw_cce_flag {
@@ -1224,14 +1566,14 @@ func (adts *ADTS) coupling_channel_element() (*coupling_channel_element, error)
 			e.Common_gain_element[c], err = hcod_sf(adts.reader) // common_gain_element[c])
 		} else {
 			info := e.Channel_stream.Ics_info
-			e.DCPM_gain_element[c] = make([][]uint8, info.num_window_groups)
+			e.DCPM_gain_element[c] = adts.mem.uint8s_2d.make(int(info.num_window_groups))
 			for g := range e.DCPM_gain_element[c] {
-				e.DCPM_gain_element[c][g] = make([]uint8, info.Max_sfb)
+				e.DCPM_gain_element[c][g] = adts.mem.uint8s.make(int(info.Max_sfb))
 				for sfb := range e.DCPM_gain_element[c][g] {
 					if info.sfb_cb[g][sfb] != ZERO_HCB {
 						e.DCPM_gain_element[c][g][sfb], err = hcod_sf(adts.reader) //[dpcm_gain_element[c][g][sfb]]; 1..19
 						if err != nil {
-							return e, err
+							return e, adts.check("coupling_channel_element", err)
 						}
 					}
 				}
@@ -1239,7 +1581,7 @@ func (adts *ADTS) coupling_channel_element() (*coupling_channel_element, error)
 		}
 	}
 
-	return e, err
+	return e, adts.check("coupling_channel_element", err)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -1247,18 +1589,20 @@ func (adts *ADTS) coupling_channel_element() (*coupling_channel_element, error)
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) lfe_channel_element() (*lfe_channel_element, error) {
 	var err error
-	e := &lfe_channel_element{}
-	e.Element_instance_tag, _ = adts.reader.ReadBitsAsUInt8(4) // element_instance_tag
+	e := adts.mem.lfe_channel_elements.new()
+	if adts.Profile != AUDIO_OBJECT_TYPE_ER_AAC_ELD {
+		e.Element_instance_tag, _ = adts.reader.ReadBitsAsUInt8(4) // element_instance_tag
+	}
 
 	e.Channel_stream, err = adts.individual_channel_stream(false, false, nil)
-	return e, err
+	return e, adts.check("lfe_channel_element", within("ics[0]", err))
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.10 – Syntax of data_stream_element()
 ////////////////////////////////////////////////////////////////////////////////
-func (adts *ADTS) data_stream_element() *data_stream_element {
-	e := &data_stream_element{}
+func (adts *ADTS) data_stream_element() (*data_stream_element, error) {
+	e := adts.mem.data_stream_elements.new()
 	e.Element_instance_tag, _ = adts.reader.ReadBitsAsUInt8(4) // element_instance_tag
 
 	e.Data_byte_align_flag, _ = adts.reader.ReadBitAsBool() // data_byte_align_flag
@@ -1267,15 +1611,18 @@ func (adts *ADTS) data_stream_element() *data_stream_element {
 		e.Esc_count, _ = adts.reader.ReadBitsAsUInt8(8)
 		e.Count += e.Esc_count
 	}
+	if err := adts.extension_limit(int(e.Count)); err != nil {
+		return e, adts.check("data_stream_element", err)
+	}
 	if e.Data_byte_align_flag == true {
 		adts.reader.ByteAlign()
 	}
-	e.Data_stream_byte = make([][]uint8, e.Element_instance_tag+1)
-	e.Data_stream_byte[e.Element_instance_tag] = make([]uint8, e.Count)
+	e.Data_stream_byte = adts.mem.uint8s_2d.make(int(e.Element_instance_tag + 1))
+	e.Data_stream_byte[e.Element_instance_tag] = adts.mem.uint8s.make(int(e.Count))
 	for i := range e.Data_stream_byte[e.Element_instance_tag] {
 		e.Data_stream_byte[e.Element_instance_tag][i], _ = adts.reader.ReadBitsAsUInt8(8) // data_stream_byte[element_instance_tag][i]
 	}
-	return e
+	return e, adts.check("data_stream_element", nil)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -1283,45 +1630,52 @@ func (adts *ADTS) data_stream_element() *data_stream_element {
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) fill_element(id_syn_ele uint8) (*fill_element, error) {
 	var err error
-	e := &fill_element{}
+	e := adts.mem.fill_elements.new()
 
 	e.Count, _ = adts.reader.ReadBitsAsUInt16(4)
 	if e.Count == 15 {
 		e.Esc_count, _ = adts.reader.ReadBitsAsUInt8(8)
 		e.Count += uint16(e.Esc_count) - 1
 	}
+	if err = adts.extension_limit(int(e.Count)); err != nil {
+		return e, adts.check("fill_element", err)
+	}
 	for cnt := int(e.Count); cnt > 0; {
 		var sub int
-		sub, e.Extension_payload, err = adts.extension_payload(cnt, id_syn_ele)
+		err = adts.limit(uint(8*cnt), func() (err error) {
+			sub, e.Extension_payload, err = adts.extension_payload(cnt, id_syn_ele)
+			return err
+		})
 		cnt -= sub
 		if err != nil {
-			return e, err
+			return e, adts.check("fill_element", err)
 		}
 	}
 
-	return e, err
+	return e, adts.check("fill_element", err)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.12 – Syntax of gain_control_data()
 ////////////////////////////////////////////////////////////////////////////////
-func (adts *ADTS) gain_control_data(info *ics_info) *gain_control_data {
-	data := &gain_control_data{}
+func (adts *ADTS) gain_control_data(info *ics_info) (*gain_control_data, error) {
+	data := adts.mem.gain_control_datas.new()
 
+	// Bands are numbered 1..max_band, band 0 never has gain control
 	data.Max_band, _ = adts.reader.ReadBitsAsUInt8(2)
-	data.Adjust_num = make([][]uint8, data.Max_band)
-	data.Alevcode = make([][][]uint8, data.Max_band)
-	data.Aloccode = make([][][]uint8, data.Max_band)
+	data.Adjust_num = adts.mem.uint8s_2d.make(int(data.Max_band + 1))
+	data.Alevcode = adts.mem.uint8s_3d.make(int(data.Max_band + 1))
+	data.Aloccode = adts.mem.uint8s_3d.make(int(data.Max_band + 1))
 	switch {
 	case info.Window_sequence == ONLY_LONG_SEQUENCE:
-		for bd := uint8(1); bd < data.Max_band; bd++ {
-			data.Adjust_num[bd] = make([]uint8, 1)
-			data.Alevcode[bd] = make([][]uint8, 1)
-			data.Aloccode[bd] = make([][]uint8, 1)
+		for bd := uint8(1); bd <= data.Max_band; bd++ {
+			data.Adjust_num[bd] = adts.mem.uint8s.make(1)
+			data.Alevcode[bd] = adts.mem.uint8s_2d.make(1)
+			data.Aloccode[bd] = adts.mem.uint8s_2d.make(1)
 			for wd := range data.Adjust_num[bd] {
 				data.Adjust_num[bd][wd], _ = adts.reader.ReadBitsAsUInt8(3)
-				data.Alevcode[bd][wd] = make([]uint8, data.Adjust_num[bd][wd])
-				data.Aloccode[bd][wd] = make([]uint8, data.Adjust_num[bd][wd])
+				data.Alevcode[bd][wd] = adts.mem.uint8s.make(int(data.Adjust_num[bd][wd]))
+				data.Aloccode[bd][wd] = adts.mem.uint8s.make(int(data.Adjust_num[bd][wd]))
 				for ad := range data.Alevcode[bd][wd] {
 					data.Alevcode[bd][wd][ad], _ = adts.reader.ReadBitsAsUInt8(4)
 					data.Aloccode[bd][wd][ad], _ = adts.reader.ReadBitsAsUInt8(5)
@@ -1329,14 +1683,14 @@ func (adts *ADTS) gain_control_data(info *ics_info) *gain_control_data {
 			}
 		}
 	case info.Window_sequence == LONG_START_SEQUENCE:
-		for bd := uint8(1); bd < data.Max_band; bd++ {
-			data.Adjust_num[bd] = make([]uint8, 2)
-			data.Alevcode[bd] = make([][]uint8, 2)
-			data.Aloccode[bd] = make([][]uint8, 2)
+		for bd := uint8(1); bd <= data.Max_band; bd++ {
+			data.Adjust_num[bd] = adts.mem.uint8s.make(2)
+			data.Alevcode[bd] = adts.mem.uint8s_2d.make(2)
+			data.Aloccode[bd] = adts.mem.uint8s_2d.make(2)
 			for wd := range data.Adjust_num[bd] {
 				data.Adjust_num[bd][wd], _ = adts.reader.ReadBitsAsUInt8(3)
-				data.Alevcode[bd][wd] = make([]uint8, data.Adjust_num[bd][wd])
-				data.Aloccode[bd][wd] = make([]uint8, data.Adjust_num[bd][wd])
+				data.Alevcode[bd][wd] = adts.mem.uint8s.make(int(data.Adjust_num[bd][wd]))
+				data.Aloccode[bd][wd] = adts.mem.uint8s.make(int(data.Adjust_num[bd][wd]))
 				for ad := range data.Alevcode[bd][wd] {
 					data.Alevcode[bd][wd][ad], _ = adts.reader.ReadBitsAsUInt8(4)
 					if wd == 0 {
@@ -1348,14 +1702,14 @@ func (adts *ADTS) gain_control_data(info *ics_info) *gain_control_data {
 			}
 		}
 	case info.Window_sequence == EIGHT_SHORT_SEQUENCE:
-		for bd := uint8(1); bd < data.Max_band; bd++ {
-			data.Adjust_num[bd] = make([]uint8, 8)
-			data.Alevcode[bd] = make([][]uint8, 8)
-			data.Aloccode[bd] = make([][]uint8, 8)
+		for bd := uint8(1); bd <= data.Max_band; bd++ {
+			data.Adjust_num[bd] = adts.mem.uint8s.make(8)
+			data.Alevcode[bd] = adts.mem.uint8s_2d.make(8)
+			data.Aloccode[bd] = adts.mem.uint8s_2d.make(8)
 			for wd := range data.Adjust_num[bd] {
 				data.Adjust_num[bd][wd], _ = adts.reader.ReadBitsAsUInt8(3)
-				data.Alevcode[bd][wd] = make([]uint8, data.Adjust_num[bd][wd])
-				data.Aloccode[bd][wd] = make([]uint8, data.Adjust_num[bd][wd])
+				data.Alevcode[bd][wd] = adts.mem.uint8s.make(int(data.Adjust_num[bd][wd]))
+				data.Aloccode[bd][wd] = adts.mem.uint8s.make(int(data.Adjust_num[bd][wd]))
 				for ad := range data.Alevcode[bd][wd] {
 					data.Alevcode[bd][wd][ad], _ = adts.reader.ReadBitsAsUInt8(4)
 					data.Aloccode[bd][wd][ad], _ = adts.reader.ReadBitsAsUInt8(2)
@@ -1363,14 +1717,14 @@ func (adts *ADTS) gain_control_data(info *ics_info) *gain_control_data {
 			}
 		}
 	case info.Window_sequence == LONG_STOP_SEQUENCE:
-		for bd := uint8(1); bd < data.Max_band; bd++ {
-			data.Adjust_num[bd] = make([]uint8, 2)
-			data.Alevcode[bd] = make([][]uint8, 2)
-			data.Aloccode[bd] = make([][]uint8, 2)
+		for bd := uint8(1); bd <= data.Max_band; bd++ {
+			data.Adjust_num[bd] = adts.mem.uint8s.make(2)
+			data.Alevcode[bd] = adts.mem.uint8s_2d.make(2)
+			data.Aloccode[bd] = adts.mem.uint8s_2d.make(2)
 			for wd := range data.Adjust_num[bd] {
 				data.Adjust_num[bd][wd], _ = adts.reader.ReadBitsAsUInt8(3)
-				data.Alevcode[bd][wd] = make([]uint8, data.Adjust_num[bd][wd])
-				data.Aloccode[bd][wd] = make([]uint8, data.Adjust_num[bd][wd])
+				data.Alevcode[bd][wd] = adts.mem.uint8s.make(int(data.Adjust_num[bd][wd]))
+				data.Aloccode[bd][wd] = adts.mem.uint8s.make(int(data.Adjust_num[bd][wd]))
 				for ad := range data.Alevcode[bd][wd] {
 					data.Alevcode[bd][wd][ad], _ = adts.reader.ReadBitsAsUInt8(4)
 					if wd == 0 {
@@ -1382,10 +1736,10 @@ func (adts *ADTS) gain_control_data(info *ics_info) *gain_control_data {
 			}
 		}
 	default:
-		return nil
+		return nil, nil
 	}
 
-	return data
+	return data, adts.check("gain_control_data", nil)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -1393,13 +1747,13 @@ func (adts *ADTS) gain_control_data(info *ics_info) *gain_control_data {
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) individual_channel_stream(common_window bool, scale_flag bool, info *ics_info) (*individual_channel_stream, error) {
 	var err error
-	s := &individual_channel_stream{}
+	s := adts.mem.individual_channel_streams.new()
 	s.Global_gain, _ = adts.reader.ReadBitsAsUInt8(8) // global_gain
 
 	if !common_window && !scale_flag {
 		s.Ics_info, err = adts.ics_info(common_window)
 		if err != nil {
-			return s, err
+			return s, adts.check("individual_channel_stream", err)
 		}
 	} else {
 		s.Ics_info = info
@@ -1407,62 +1761,87 @@ func (adts *ADTS) individual_channel_stream(common_window bool, scale_flag bool,
 
 	s.Section_data, err = adts.section_data(s.Ics_info)
 	if err != nil {
-		return s, err
+		return s, adts.check("individual_channel_stream", err)
 	}
 
 	s.Scale_factor_data, err = adts.scale_factor_data(s.Ics_info)
 	if err != nil {
-		return s, err
+		return s, adts.check("individual_channel_stream", err)
 	}
 
+	// ER AAC ELD has neither pulse data nor gain control
+	eld := adts.Profile == AUDIO_OBJECT_TYPE_ER_AAC_ELD
 	if !scale_flag {
-		s.Pulse_data_present, _ = adts.reader.ReadBitAsBool() // pulse_data_present
-		if s.Pulse_data_present == true {
-			s.Pulse_data = adts.pulse_data()
+		if !eld {
+			s.Pulse_data_present, _ = adts.reader.ReadBitAsBool() // pulse_data_present
+			if s.Pulse_data_present == true {
+				if s.Pulse_data, err = adts.pulse_data(); err != nil {
+					return s, adts.check("individual_channel_stream", err)
+				}
+			}
 		}
 
 		s.Tns_data_present, _ = adts.reader.ReadBitAsBool() // tns_data_present
-		if s.Tns_data_present == true {
-			s.Tns_data = adts.tns_data(s.Ics_info)
+		if s.Tns_data_present == true && !adts.er_syntax() {
+			if s.Tns_data, err = adts.tns_data(s.Ics_info); err != nil {
+				return s, adts.check("individual_channel_stream", err)
+			}
 		}
 
-		s.Gain_control_data_present, _ = adts.reader.ReadBitAsBool() // gain_control_data_present
-		if s.Gain_control_data_present == true {
-			adts.gain_control_data(s.Ics_info)
+		if !eld {
+			s.Gain_control_data_present, _ = adts.reader.ReadBitAsBool() // gain_control_data_present
+			if s.Gain_control_data_present == true {
+				if s.Gain_control_data, err = adts.gain_control_data(s.Ics_info); err != nil {
+					return s, adts.check("individual_channel_stream", err)
+				}
+			}
 		}
 	}
 
-	// aacSpectralDataResilienceFlag is hard coded to false in other AAC decoders.
-	// We'll follow suit here.
-	//if !aacSpectralDataResilienceFlag {
-	s.Spectral_data, err = adts.spectral_data(s.Ics_info, s.Section_data)
-	/*} else {
-		adts.reader.SkipBits(14) // length_of_reordered_spectral_data
-		adts.reader.SkipBits(6)  // length_of_longest_codeword
-		adts.reordered_spectral_data()
-	}*/
-	return s, err
-}
+	if adts.aac_spectral_data_resilience_flag {
+		s.Length_of_reordered_spectral_data, _ = adts.reader.ReadBitsAsUInt16(14) // length_of_reordered_spectral_data
+		s.Length_of_longest_code_word, _ = adts.reader.ReadBitsAsUInt8(6)         // length_of_longest_codeword
+	}
 
-////////////////////////////////////////////////////////////////////////////////
-// Table 4.51 – Syntax of reordered_spectral_data ()
-////////////////////////////////////////////////////////////////////////////////
-// func (adts *ADTS) reordered_spectral_data() {
-// TODO
-/* complex reordering, see tool description of Huffman
-codeword reordering (subclause 4.6.16.3) */
-// }
+	// The RVLC segments follow the side information
+	if adts.aac_scalefactor_data_resilience_flag {
+		if err = adts.rvlc_segments(s.Ics_info, s.Global_gain, s.Scale_factor_data); err != nil {
+			return s, adts.check("individual_channel_stream", err)
+		}
+	}
+
+	// The error resilient syntax moves tns_data() behind the rest of the
+	// side information
+	if s.Tns_data_present && adts.er_syntax() {
+		if s.Tns_data, err = adts.tns_data(s.Ics_info); err != nil {
+			return s, adts.check("individual_channel_stream", err)
+		}
+	}
+
+	switch {
+	case !adts.aac_spectral_data_resilience_flag:
+		s.Spectral_data, err = adts.spectral_data(s.Ics_info, s.Section_data)
+	case adts.options.SkipSpectralData:
+		// Reordered spectral data has its length signaled
+		adts.reader.SkipBits(uint(s.Length_of_reordered_spectral_data))
+	default:
+		s.Reordered_spectral_data, s.Spectral_data, err = adts.reordered_spectral_data(s.Ics_info, s.Section_data,
+			s.Length_of_reordered_spectral_data, s.Length_of_longest_code_word)
+	}
+	if adts.options.SkipSpectralData {
+		s.Spectral_data = nil
+	}
+	return s, adts.check("individual_channel_stream", err)
+}
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.52 – Syntax of section_data()
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) section_data(info *ics_info) (*section_data, error) {
 	var err error
-	data := &section_data{}
+	data := adts.mem.section_datas.new()
 
-	// This param is hardcoded to false for all other AAC Decoders I've found.
-	// We'll just follow suit for now
-	aacSectionDataResilienceFlag := false
+	aacSectionDataResilienceFlag := adts.aac_section_data_resilience_flag
 
 	var bits uint
 	if info.Window_sequence == EIGHT_SHORT_SEQUENCE {
@@ -1472,21 +1851,21 @@ func (adts *ADTS) section_data(info *ics_info) (*section_data, error) {
 	}
 
 	sect_esc_val := uint8((1 << uint8(bits)) - 1)
-	data.Sect_cb = make([][]uint8, info.num_window_groups)
-	data.sect_start = make([][]uint8, info.num_window_groups)
-	data.sect_end = make([][]uint16, info.num_window_groups)
-	data.num_sec = make([]uint8, info.num_window_groups)
-	info.sfb_cb = make([][]uint8, info.num_window_groups)
+	data.Sect_cb = adts.mem.uint8s_2d.make(int(info.num_window_groups))
+	data.sect_start = adts.mem.uint8s_2d.make(int(info.num_window_groups))
+	data.sect_end = adts.mem.uint16s_2d.make(int(info.num_window_groups))
+	data.num_sec = adts.mem.uint8s.make(int(info.num_window_groups))
+	info.sfb_cb = adts.mem.uint8s_2d.make(int(info.num_window_groups))
 	for g := range data.Sect_cb {
 		i := uint8(0)
 		k := uint8(0)
 		for k < info.Max_sfb {
 			if aacSectionDataResilienceFlag {
 				val, _ := adts.reader.ReadBits(5) // sect_cb[g][i]
-				data.Sect_cb[g] = append(data.Sect_cb[g], val)
+				data.Sect_cb[g] = adts.mem.uint8s.append(data.Sect_cb[g], val)
 			} else {
 				val, _ := adts.reader.ReadBits(4) // sect_cb[g][i]
-				data.Sect_cb[g] = append(data.Sect_cb[g], val)
+				data.Sect_cb[g] = adts.mem.uint8s.append(data.Sect_cb[g], val)
 			}
 
 			sect_len := uint8(0)
@@ -1503,7 +1882,7 @@ func (adts *ADTS) section_data(info *ics_info) (*section_data, error) {
 					if err != nil {
 						// We need to check this err so we don't get stuck in
 						// an infinite loop
-						return data, err
+						return data, adts.check("section_data", err)
 					}
 				}
 			} else {
@@ -1511,11 +1890,11 @@ func (adts *ADTS) section_data(info *ics_info) (*section_data, error) {
 			}
 
 			sect_len += sect_len_incr
-			data.sect_start[g] = append(data.sect_start[g], uint8(k))
-			data.sect_end[g] = append(data.sect_end[g], uint16(k+sect_len))
+			data.sect_start[g] = adts.mem.uint8s.append(data.sect_start[g], uint8(k))
+			data.sect_end[g] = adts.mem.uint16s.append(data.sect_end[g], uint16(k+sect_len))
 
 			for j := uint8(0); j < sect_len; j++ {
-				info.sfb_cb[g] = append(info.sfb_cb[g], data.Sect_cb[g][i])
+				info.sfb_cb[g] = adts.mem.uint8s.append(info.sfb_cb[g], data.Sect_cb[g][i])
 			}
 
 			k += sect_len
@@ -1528,17 +1907,17 @@ func (adts *ADTS) section_data(info *ics_info) (*section_data, error) {
 			if k > upper_bound || i > upper_bound {
 				err = fmt.Errorf("Error: Section Codebook param out of bounds(%d). End (%d), Index (%d)",
 					upper_bound, k, i)
-				return data, err
+				return data, adts.check("section_data", err)
 			}
 
 		}
 		data.num_sec[g] = i
 		if k != info.Max_sfb {
 			err = fmt.Errorf("Error: Total length (%d) does not equal Max_sfb (%d)", k, info.Max_sfb)
-			return data, err
+			return data, adts.check("section_data", err)
 		}
 	}
-	return data, err
+	return data, adts.check("section_data", err)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -1546,19 +1925,19 @@ func (adts *ADTS) section_data(info *ics_info) (*section_data, error) {
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) scale_factor_data(info *ics_info) (*scale_factor_data, error) {
 	var err error
-	data := &scale_factor_data{}
-	// This param is hardcoded to false for all other AAC Decoders I've found.
-	// We'll just follow suit for now
-	//	if !aacSectionDataResilienceFlag {
+	data := adts.mem.scale_factor_datas.new()
+	if adts.aac_scalefactor_data_resilience_flag {
+		return adts.rvlc_scale_factor_data(info, data)
+	}
 	noise_pcm_flag := true
 
-	data.Dcpm_is_position = make([][]uint8, info.num_window_groups)
-	data.Dcpm_noise_nrg = make([][]uint16, info.num_window_groups)
-	data.Dcpm_sf = make([][]uint8, info.num_window_groups)
+	data.Dcpm_is_position = adts.mem.uint8s_2d.make(int(info.num_window_groups))
+	data.Dcpm_noise_nrg = adts.mem.uint16s_2d.make(int(info.num_window_groups))
+	data.Dcpm_sf = adts.mem.uint8s_2d.make(int(info.num_window_groups))
 	for g := uint8(0); g < info.num_window_groups; g++ {
-		data.Dcpm_is_position[g] = make([]uint8, info.Max_sfb)
-		data.Dcpm_noise_nrg[g] = make([]uint16, info.Max_sfb)
-		data.Dcpm_sf[g] = make([]uint8, info.Max_sfb)
+		data.Dcpm_is_position[g] = adts.mem.uint8s.make(int(info.Max_sfb))
+		data.Dcpm_noise_nrg[g] = adts.mem.uint16s.make(int(info.Max_sfb))
+		data.Dcpm_sf[g] = adts.mem.uint8s.make(int(info.Max_sfb))
 
 		for sfb := range data.Dcpm_sf[g] {
 			if info.sfb_cb[g][sfb] != ZERO_HCB {
@@ -1578,7 +1957,7 @@ func (adts *ADTS) scale_factor_data(info *ics_info) (*scale_factor_data, error)
 						data.Dcpm_sf[g][sfb], err = hcod_sf(adts.reader)
 					}
 					if err != nil {
-						return data, err
+						return data, adts.check("scale_factor_data", err)
 					}
 				}
 			} else {
@@ -1587,15 +1966,14 @@ func (adts *ADTS) scale_factor_data(info *ics_info) (*scale_factor_data, error)
 		}
 	}
 
-	// else {} from the spec is not implemented due to hard coded aacSectionDataResilienceFlag
-	return data, err
+	return data, adts.check("scale_factor_data", err)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.54 – Syntax of tns_data()
 ////////////////////////////////////////////////////////////////////////////////
-func (adts *ADTS) tns_data(info *ics_info) *tns_data {
-	data := &tns_data{}
+func (adts *ADTS) tns_data(info *ics_info) (*tns_data, error) {
+	data := adts.mem.tns_datas.new()
 
 	filt_bits := uint(2)
 	len_bits := uint(6)
@@ -1606,24 +1984,24 @@ func (adts *ADTS) tns_data(info *ics_info) *tns_data {
 		order_bits = 3
 	}
 
-	data.N_filt = make([]uint8, info.num_windows)
-	data.Len = make([][]uint8, info.num_windows)
-	data.Order = make([][]uint8, info.num_windows)
-	data.Direction = make([][]bool, info.num_windows)
-	data.Coef_res = make([]uint8, info.num_windows)
-	data.Coef_compress = make([][]uint8, info.num_windows)
-	data.Coef = make([][][]uint8, info.num_windows)
+	data.N_filt = adts.mem.uint8s.make(int(info.num_windows))
+	data.Len = adts.mem.uint8s_2d.make(int(info.num_windows))
+	data.Order = adts.mem.uint8s_2d.make(int(info.num_windows))
+	data.Direction = adts.mem.bools_2d.make(int(info.num_windows))
+	data.Coef_res = adts.mem.uint8s.make(int(info.num_windows))
+	data.Coef_compress = adts.mem.uint8s_2d.make(int(info.num_windows))
+	data.Coef = adts.mem.uint8s_3d.make(int(info.num_windows))
 	for w := range data.N_filt {
 		data.N_filt[w], _ = adts.reader.ReadBitsAsUInt8(filt_bits)
 		if data.N_filt[w] != 0 {
 			data.Coef_res[w], _ = adts.reader.ReadBitsAsUInt8(1)
 		}
 
-		data.Len[w] = make([]uint8, data.N_filt[w])
-		data.Order[w] = make([]uint8, data.N_filt[w])
-		data.Direction[w] = make([]bool, data.N_filt[w])
-		data.Coef_compress[w] = make([]uint8, data.N_filt[w])
-		data.Coef[w] = make([][]uint8, data.N_filt[w])
+		data.Len[w] = adts.mem.uint8s.make(int(data.N_filt[w]))
+		data.Order[w] = adts.mem.uint8s.make(int(data.N_filt[w]))
+		data.Direction[w] = adts.mem.bools.make(int(data.N_filt[w]))
+		data.Coef_compress[w] = adts.mem.uint8s.make(int(data.N_filt[w]))
+		data.Coef[w] = adts.mem.uint8s_2d.make(int(data.N_filt[w]))
 		for filt := range data.Len[w] {
 			data.Len[w][filt], _ = adts.reader.ReadBitsAsUInt8(len_bits)
 			data.Order[w][filt], _ = adts.reader.ReadBitsAsUInt8(order_bits)
@@ -1632,92 +2010,100 @@ func (adts *ADTS) tns_data(info *ics_info) *tns_data {
 				data.Coef_compress[w][filt], _ = adts.reader.ReadBitsAsUInt8(1)
 
 				coef_bits := data.Coef_res[w] + 3 - data.Coef_compress[w][filt]
-				data.Coef[w][filt] = make([]uint8, data.Order[w][filt])
+				data.Coef[w][filt] = adts.mem.uint8s.make(int(data.Order[w][filt]))
 				for i := range data.Coef[w][filt] {
 					data.Coef[w][filt][i], _ = adts.reader.ReadBitsAsUInt8(uint(coef_bits))
 				}
 			}
 		}
 	}
-	return data
+	return data, adts.check("tns_data", nil)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.55 – Syntax of ltp_data()
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) ltp_data(info *ics_info) (*ltp_data, error) {
-	data := &ltp_data{}
+	data := adts.mem.ltp_datas.new()
 	if adts.Profile == AUDIO_OBJECT_TYPE_ER_AAC_LD {
-		ltp_lag_update, _ := adts.reader.ReadBitAsBool()
-		if ltp_lag_update {
+		data.Ltp_lag_update, _ = adts.reader.ReadBitAsBool() // ltp_lag_update
+		if data.Ltp_lag_update {
 			data.Ltp_lag, _ = adts.reader.ReadBitsAsUInt(10)
 		} else {
-			data.Ltp_lag = info.Ltp_data.Ltp_lag
+			data.Ltp_lag_prev = true
 		}
 
 		if data.Ltp_lag > uint(adts.Frame_length<<1) {
-			return data, fmt.Errorf("Error: Ltp_lag (%d) out of range (%d)", data.Ltp_lag, adts.Frame_length<<1)
+			return data, adts.check("ltp_data", fmt.Errorf("Error: Ltp_lag (%d) out of range (%d)", data.Ltp_lag, adts.Frame_length<<1))
 		}
 
 		data.Ltp_coef, _ = adts.reader.ReadBitsAsUInt8(3)
-		data.Ltp_long_used = make([]bool, minInt(int(info.Max_sfb), int(MAX_LTP_LONG_SFB)))
+		data.Ltp_long_used = adts.mem.bools.make(minInt(int(info.Max_sfb), int(MAX_LTP_LONG_SFB)))
 		for sfb := range data.Ltp_long_used {
 			data.Ltp_long_used[sfb], _ = adts.reader.ReadBitAsBool()
 		}
 	} else {
 		data.Ltp_lag, _ = adts.reader.ReadBitsAsUInt(11)
 		if data.Ltp_lag > uint(adts.Frame_length<<1) {
-			return data, fmt.Errorf("Error: Ltp_lag (%d) out of range (%d)", data.Ltp_lag, adts.Frame_length<<1)
+			return data, adts.check("ltp_data", fmt.Errorf("Error: Ltp_lag (%d) out of range (%d)", data.Ltp_lag, adts.Frame_length<<1))
 		}
 
 		data.Ltp_coef, _ = adts.reader.ReadBitsAsUInt8(3)
 		if info.Window_sequence != EIGHT_SHORT_SEQUENCE {
-			data.Ltp_long_used = make([]bool, minInt(int(info.Max_sfb), int(MAX_LTP_LONG_SFB)))
+			data.Ltp_long_used = adts.mem.bools.make(minInt(int(info.Max_sfb), int(MAX_LTP_LONG_SFB)))
 			for sfb := range data.Ltp_long_used {
 				data.Ltp_long_used[sfb], _ = adts.reader.ReadBitAsBool()
 			}
 		}
 	}
 
-	return data, nil
+	return data, adts.check("ltp_data", nil)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.56 – Syntax of spectral_data()
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) spectral_data(info *ics_info, sec_data *section_data) (*spectral_data, error) {
-	data := &spectral_data{}
+	data := adts.mem.spectral_datas.new()
+
+	// Tuples that aren't kept are decoded into this one
+	var skipped [4]int16
 
 	for g := uint8(0); g < info.num_window_groups; g++ {
 		for i := uint8(0); i < sec_data.num_sec[g]; i++ {
-			switch sec_data.Sect_cb[g][i] {
+			sect_cb := sec_data.Sect_cb[g][i]
+			switch sect_cb {
 			case ZERO_HCB, NOISE_HCB, INTENSITY_HCB, INTENSITY_HCB2:
 			default:
 				inc := uint16(4)
-				if sec_data.Sect_cb[g][i] >= FIRST_PAIR_HCB {
+				if sect_cb >= FIRST_PAIR_HCB {
 					inc = 2
 				}
 
 				start := info.sect_sfb_offset[g][sec_data.sect_start[g][i]]
 				end := info.sect_sfb_offset[g][sec_data.sect_end[g][i]]
 				for k := start; k < end; k += inc {
-					if sec_data.Sect_cb[g][i] != 0 {
-						if adts.reader.HasBitLeft() == false {
-							return data, fmt.Errorf("Error: Spectral Data parsing ran out of bits")
+					if sect_cb != 0 {
+						var err error
+						if adts.options.SkipSpectralData {
+							err = hcod_values(adts.reader, sect_cb, skipped[:inc])
+						} else {
+							val := adts.mem.int16s.make(int(inc))
+							err = hcod_values(adts.reader, sect_cb, val)
+							if err == nil && adts.reader.Err() == nil {
+								data.Hcod = adts.mem.int16s_2d.append(data.Hcod, val)
+							}
 						}
-						val, err := hcod(adts.reader, sec_data.Sect_cb[g][i])
-						if err != nil {
-							return data, err
+						if err != nil || adts.reader.Err() != nil {
+							return data, adts.check("spectral_data", err)
 						}
-
-						data.Hcod = append(data.Hcod, val)
 					}
 				}
 			}
 		}
 	}
 
-	return data, nil
+	return data, adts.check("spectral_data", nil)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -1725,26 +2111,36 @@ func (adts *ADTS) spectral_data(info *ics_info, sec_data *section_data) (*spectr
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) extension_payload(cnt int, id_adts uint8) (int, *extension_payload, error) {
 	var err error
-	data := &extension_payload{}
+	data := adts.mem.extension_payloads.new()
 	data.Extension_type, _ = adts.reader.ReadBitsAsUInt8(4) // extension_type
 
 	switch data.Extension_type {
 	case EXT_DYNAMIC_RANGE:
-		cnt, data.Dynamic_range_info = adts.dynamic_range_info()
-		return cnt, data, err
+		cnt, data.Dynamic_range_info, err = adts.dynamic_range_info()
+		return cnt, data, adts.check("extension_payload", err)
 	case EXT_SAC_DATA:
-		cnt, data.Sac_extension_data = adts.sac_extension_data(cnt)
-		return cnt, data, err
-	case EXT_SBR_DATA:
-		cnt, data.Sbr_extension_data, err = adts.sbr_extension_data(cnt, id_adts, false)
-		return cnt, data, err
-	case EXT_SBR_DATA_CRC:
-		cnt, data.Sbr_extension_data, err = adts.sbr_extension_data(cnt, id_adts, true)
-		return cnt, data, err
+		cnt, data.Sac_extension_data, err = adts.sac_extension_data(cnt)
+		return cnt, data, adts.check("extension_payload", err)
+	case EXT_SBR_DATA, EXT_SBR_DATA_CRC:
+		if adts.options.SkipSBR {
+			adts.reader.SkipBits(uint(8*(cnt-1) + 4))
+			return cnt, data, adts.check("extension_payload", nil)
+		}
+		crc_flag := data.Extension_type == EXT_SBR_DATA_CRC
+		cnt, data.Sbr_extension_data, err = adts.sbr_extension_data(cnt, id_adts, crc_flag)
+		return cnt, data, adts.check("extension_payload", err)
 	case EXT_FILL_DATA:
 		data.Fill_nibble, _ = adts.reader.ReadBitsAsUInt8(4) // fill_nibble - must be ‘0000’
 		if int(adts.reader.BitsLeft()) > cnt {
-			data.Fill_byte, _ = adts.reader.ReadBitsToByteArray(uint(8 * (cnt - 1)))
+			data.Fill_byte, _ = adts.read_bits_to_byte_array(uint(8 * (cnt - 1)))
+		}
+		if err = adts.strict(data.Fill_nibble != 0, ErrReserved, "Error: fill_nibble (%d) must be 0", data.Fill_nibble); err != nil {
+			return cnt, data, adts.check("extension_payload", err)
+		}
+		for _, fill_byte := range data.Fill_byte {
+			if err = adts.strict(fill_byte != 0xa5, ErrReserved, "Error: fill_byte (%#x) must be 0xa5", fill_byte); err != nil {
+				return cnt, data, adts.check("extension_payload", err)
+			}
 		}
 	case EXT_DATA_ELEMENT:
 		data.Data_element_version, _ = adts.reader.ReadBitsAsUInt8(4) // data_element_version
@@ -1758,7 +2154,10 @@ func (adts *ADTS) extension_payload(cnt int, id_adts uint8) (int, *extension_pay
 					break
 				}
 			}
-			data.Data_element_byte, _ = adts.reader.ReadBytes(dataElementLength)
+			if err = adts.extension_limit(int(dataElementLength)); err != nil {
+				return cnt, data, adts.check("extension_payload", err)
+			}
+			data.Data_element_byte, _ = adts.read_bits_to_byte_array(8 * dataElementLength)
 		}
 	case EXT_FILL:
 		fallthrough
@@ -1766,14 +2165,14 @@ func (adts *ADTS) extension_payload(cnt int, id_adts uint8) (int, *extension_pay
 		adts.reader.SkipBits(uint(8*(cnt-1) + 4))
 	}
 
-	return cnt, data, err
+	return cnt, data, adts.check("extension_payload", err)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.58 – Syntax of dynamic_range_info()
 ////////////////////////////////////////////////////////////////////////////////
-func (adts *ADTS) dynamic_range_info() (int, *dynamic_range_info) {
-	info := &dynamic_range_info{}
+func (adts *ADTS) dynamic_range_info() (int, *dynamic_range_info, error) {
+	info := adts.mem.dynamic_range_infos.new()
 
 	n := 1
 	drc_num_bands := uint8(1)
@@ -1781,6 +2180,10 @@ func (adts *ADTS) dynamic_range_info() (int, *dynamic_range_info) {
 	if info.Pce_tag_present {
 		info.Pce_instance_tag, _ = adts.reader.ReadBitsAsUInt8(4)     // pce_instance_tag
 		info.Drc_tag_reserve_bits, _ = adts.reader.ReadBitsAsUInt8(4) // drc_tag_reserved_bits
+		n++
+		if err := adts.strict(info.Drc_tag_reserve_bits != 0, ErrReserved, "Error: drc_tag_reserved_bits must equal 0"); err != nil {
+			return 0, info, adts.check("dynamic_range_info", err)
+		}
 	}
 
 	info.Excluded_chns_present, _ = adts.reader.ReadBitAsBool() // excluded_chns_present
@@ -1797,54 +2200,61 @@ func (adts *ADTS) dynamic_range_info() (int, *dynamic_range_info) {
 
 		n++
 		drc_num_bands += info.Drc_band_incr
-		info.Drc_band_top, _ = adts.reader.ReadBytes(uint(drc_num_bands))
+		info.Drc_band_top = adts.mem.uint8s.make(int(drc_num_bands))
+		for i := range info.Drc_band_top {
+			info.Drc_band_top[i], _ = adts.reader.ReadBitsAsUInt8(8) // drc_band_top[i]
+		}
+		n += int(drc_num_bands)
 	}
 
 	info.Prog_ref_level_present, _ = adts.reader.ReadBitAsBool() // prog_ref_level_present
 	if info.Prog_ref_level_present {
 		info.Prog_ref_level, _ = adts.reader.ReadBitsAsUInt8(7)      // prog_ref_level
 		info.Prog_ref_level_reserved_bits, _ = adts.reader.ReadBit() // prog_ref_level_reserved_bitsBits(1)
+		if err := adts.strict(info.Prog_ref_level_reserved_bits != 0, ErrReserved, "Error: prog_ref_level_reserved_bits must equal 0"); err != nil {
+			return 0, info, adts.check("dynamic_range_info", err)
+		}
 		n++
 	}
 
-	info.Dyn_range_sign = make([]uint8, drc_num_bands)
-	info.Dyn_range_cnt = make([]uint8, drc_num_bands)
+	info.Dyn_range_sign = adts.mem.uint8s.make(int(drc_num_bands))
+	info.Dyn_range_cnt = adts.mem.uint8s.make(int(drc_num_bands))
 	for i := range info.Dyn_range_sign {
 		info.Dyn_range_sign[i], _ = adts.reader.ReadBitsAsUInt8(1) // dyn_rng_sgn[i]
 		info.Dyn_range_cnt[i], _ = adts.reader.ReadBitsAsUInt8(7)  // dyn_rng_ctl[i]
 		n++
 	}
 
-	return n, info
+	return n, info, adts.check("dynamic_range_info", nil)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.59 – Syntax of excluded_channels()
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) excluded_channels() (int, *excluded_channels) {
-	data := &excluded_channels{}
+	data := adts.mem.excluded_channels.new()
 	n := 0
 	num_excl_chan := 7
-	data.Exclude_mask = make([]bool, 7)
+	data.Exclude_mask = adts.mem.bools.make(7)
 	for i := range data.Exclude_mask {
 		data.Exclude_mask[i], _ = adts.reader.ReadBitAsBool()
 	}
 
 	n++
 
-	data.Additional_excluded_chns = make([]bool, 0)
+	data.Additional_excluded_chns = adts.mem.bools.make(0)
 	additional_excluded_chn, _ := adts.reader.ReadBitAsBool()
-	data.Additional_excluded_chns = append(data.Additional_excluded_chns, additional_excluded_chn)
+	data.Additional_excluded_chns = adts.mem.bools.append(data.Additional_excluded_chns, additional_excluded_chn)
 	for data.Additional_excluded_chns[n-1] {
 		for i := num_excl_chan; i < num_excl_chan+7; i++ {
 			mask, _ := adts.reader.ReadBitAsBool()
-			data.Exclude_mask = append(data.Exclude_mask, mask)
+			data.Exclude_mask = adts.mem.bools.append(data.Exclude_mask, mask)
 		}
 		n++
 		num_excl_chan += 7
 
 		additional_excluded_chn, _ := adts.reader.ReadBitAsBool()
-		data.Additional_excluded_chns = append(data.Additional_excluded_chns, additional_excluded_chn)
+		data.Additional_excluded_chns = adts.mem.bools.append(data.Additional_excluded_chns, additional_excluded_chn)
 	}
 
 	return n, data
@@ -1864,14 +2274,14 @@ func (adts *ADTS) excluded_channels() (int, *excluded_channels) {
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.61 – Syntax of sac_extension_data()
 ////////////////////////////////////////////////////////////////////////////////
-func (adts *ADTS) sac_extension_data(cnt int) (int, *sac_extension_data) {
-	data := &sac_extension_data{}
+func (adts *ADTS) sac_extension_data(cnt int) (int, *sac_extension_data, error) {
+	data := adts.mem.sac_extension_datas.new()
 
-	data.AncType, _ = adts.reader.ReadBitsAsUInt8(2)                  // ancType
-	data.AncStart, _ = adts.reader.ReadBitAsBool()                    // ancStart
-	data.AncStop, _ = adts.reader.ReadBitAsBool()                     // ancStop
-	data.AncDataSegmentByte, _ = adts.reader.ReadBytes(uint(cnt - 1)) // ancDataSegmentByte[i]
-	return cnt, data
+	data.AncType, _ = adts.reader.ReadBitsAsUInt8(2)                           // ancType
+	data.AncStart, _ = adts.reader.ReadBitAsBool()                             // ancStart
+	data.AncStop, _ = adts.reader.ReadBitAsBool()                              // ancStop
+	data.AncDataSegmentByte, _ = adts.read_bits_to_byte_array(8 * uint(cnt-1)) // ancDataSegmentByte[i]
+	return cnt, data, adts.check("sac_extension_data", nil)
 }
 
 //////////////////////////////////////////////////////////////////////////////////
@@ -1879,7 +2289,7 @@ func (adts *ADTS) sac_extension_data(cnt int) (int, *sac_extension_data) {
 //////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) sbr_extension_data(cnt int, id_aac uint8, crc_flag bool) (int, *sbr_extension_data, error) {
 	var err error
-	data := &sbr_extension_data{}
+	data := adts.mem.sbr_extension_datas.new()
 	num_sbr_bits := uint(0)
 
 	if crc_flag {
@@ -1892,24 +2302,38 @@ func (adts *ADTS) sbr_extension_data(cnt int, id_aac uint8, crc_flag bool) (int,
 	num_sbr_bits++
 	var data_bits uint
 	if data.Bs_header_flag, _ = adts.reader.ReadBitAsBool(); data.Bs_header_flag {
-		data_bits, data.Sbr_header = adts.sbr_header()
+		if data_bits, data.Sbr_header, err = adts.sbr_header(); err != nil {
+			return 0, data, adts.check("sbr_extension_data", err)
+		}
 		num_sbr_bits += data_bits
 	}
 
+	// ER AAC ELD sends the headers in its config
+	if adts.ld_sbr_element < len(adts.ld_sbr_header) {
+		if data.Sbr_header == nil {
+			data.Sbr_header = adts.ld_sbr_header[adts.ld_sbr_element]
+		}
+		adts.ld_sbr_element++
+	}
+
 	if data.Sbr_header != nil {
 		// Sampling freq for the sbr is twice the stream sample rate (4.6.18.2.5).  This means the sbr
-		// sampling freq index moves down by 3, except for 0, 1, 2 and 12
+		// sampling freq index moves down by 3, except for 0, 1, 2 and 12.  Single rate low delay
+		// SBR runs at the stream sample rate.
 		sfi := int(adts.sfi) - 3
+		if adts.Profile == AUDIO_OBJECT_TYPE_ER_AAC_ELD && !adts.ld_sbr_sampling_rate {
+			sfi = int(adts.sfi)
+		}
 		if sfi < 0 {
 			sfi = 0
 		} else if sfi > 8 {
 			sfi = 8
 		}
 
-		err = derive_sbr_tables(data, uint8(sfi), data.Sbr_header.Bs_start_freq, data.Sbr_header.Bs_stop_freq,
+		err = derive_sbr_tables(&adts.mem, data, uint8(sfi), data.Sbr_header.Bs_start_freq, data.Sbr_header.Bs_stop_freq,
 			data.Sbr_header.Bs_freq_scale, data.Sbr_header.Bs_alter_scale, data.Sbr_header.Bs_xover_band)
 		if err != nil {
-			return 0, data, err
+			return 0, data, adts.check("sbr_extension_data", err)
 		}
 
 		data_bits, data.Sbr_data, err = adts.sbr_data(data, id_aac, data.Sbr_header.Bs_amp_res)
@@ -1919,19 +2343,19 @@ func (adts *ADTS) sbr_extension_data(cnt int, id_aac uint8, crc_flag bool) (int,
 	num_align_bits := (8*uint(cnt) - 4 - num_sbr_bits)
 
 	if 8*uint(cnt) < (4 + num_sbr_bits) {
-		return 0, data, fmt.Errorf("sbr extension payload malformed")
+		return 0, data, adts.check("sbr_extension_data", fmt.Errorf("sbr extension payload malformed"))
 	}
 
-	data.Bs_fill_bits, _ = adts.reader.ReadBitsToByteArray(num_align_bits)
+	data.Bs_fill_bits, _ = adts.read_bits_to_byte_array(num_align_bits)
 
-	return int(num_sbr_bits+num_align_bits+4) / 8, data, err
+	return int(num_sbr_bits+num_align_bits+4) / 8, data, adts.check("sbr_extension_data", err)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.63 – Syntax of sbr_header()
 ////////////////////////////////////////////////////////////////////////////////
-func (adts *ADTS) sbr_header() (uint, *sbr_header) {
-	data := &sbr_header{}
+func (adts *ADTS) sbr_header() (uint, *sbr_header, error) {
+	data := adts.mem.sbr_headers.new()
 	start_bits := adts.reader.BitsLeft()
 
 	data.Bs_amp_res, _ = adts.reader.ReadBitAsBool()
@@ -1939,6 +2363,9 @@ func (adts *ADTS) sbr_header() (uint, *sbr_header) {
 	data.Bs_stop_freq, _ = adts.reader.ReadBitsAsUInt8(4)
 	data.Bs_xover_band, _ = adts.reader.ReadBitsAsUInt8(3)
 	data.Bs_reserved, _ = adts.reader.ReadBitsAsUInt8(2)
+	if err := adts.strict(data.Bs_reserved != 0, ErrReserved, "Error: bs_reserved must equal 0"); err != nil {
+		return 0, data, adts.check("sbr_header", err)
+	}
 
 	data.Bs_header_extra_1, _ = adts.reader.ReadBitAsBool()
 	data.Bs_header_extra_2, _ = adts.reader.ReadBitAsBool()
@@ -1966,7 +2393,7 @@ func (adts *ADTS) sbr_header() (uint, *sbr_header) {
 		data.Bs_smoothing_mode = 1
 	}
 
-	return (start_bits - adts.reader.BitsLeft()), data
+	return (start_bits - adts.reader.BitsLeft()), data, adts.check("sbr_header", nil)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -1974,7 +2401,7 @@ func (adts *ADTS) sbr_header() (uint, *sbr_header) {
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) sbr_data(ext_data *sbr_extension_data, id_aac uint8, bs_amp_res bool) (uint, *sbr_data, error) {
 	var err error
-	data := &sbr_data{}
+	data := adts.mem.sbr_datas.new()
 	data_bits := adts.reader.BitsLeft()
 	// FFMPEG and FAAD2 both blindly assume sbr_layer = SBR_NOT_SCALABLE, I guess we will too
 	// switch sbr_layer
@@ -1985,33 +2412,29 @@ func (adts *ADTS) sbr_data(ext_data *sbr_extension_data, id_aac uint8, bs_amp_re
 	case ID_CPE:
 		data.Sbr_channel_pair_element, err = adts.sbr_channel_pair_element(ext_data, bs_amp_res)
 	}
-	return (data_bits - adts.reader.BitsLeft()), data, err
+	return (data_bits - adts.reader.BitsLeft()), data, adts.check("sbr_data", err)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.65 – Syntax of sbr_single_channel_element()
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) sbr_single_channel_element(ext_data *sbr_extension_data, bs_amp_res bool) (*sbr_single_channel_element, error) {
-	e := &sbr_single_channel_element{}
+	e := adts.mem.sbr_single_channel_elements.new()
 
 	if e.Bs_data_extra, _ = adts.reader.ReadBitAsBool(); e.Bs_data_extra { // bs_data_extra
 		e.Bs_reserved, _ = adts.reader.ReadBitsAsUInt8(4) // bs_reserved
+		if err := adts.strict(e.Bs_reserved != 0, ErrReserved, "Error: bs_reserved must equal 0"); err != nil {
+			return e, adts.check("sbr_single_channel_element", err)
+		}
 	}
 
-	e.Sbr_grid = &sbr_grid{
-		Bs_var_bord_0: make([]uint8, 2),
-		Bs_var_bord_1: make([]uint8, 2),
-		Bs_num_rel_0:  make([]uint8, 2),
-		Bs_num_rel_1:  make([]uint8, 2),
-		bs_rel_bord_0: make([][]uint8, 2),
-		bs_rel_bord_1: make([][]uint8, 2),
-	}
-	e.Sbr_dtdf = &sbr_dtdf{}
-	e.Sbr_invf = &sbr_invf{}
-	e.Sbr_envelope = &sbr_envelope{}
-	e.Sbr_noise = &sbr_noise{}
+	e.Sbr_grid = adts.new_sbr_grid()
+	e.Sbr_dtdf = adts.mem.sbr_dtdfs.new()
+	e.Sbr_invf = adts.mem.sbr_invfs.new()
+	e.Sbr_envelope = adts.mem.sbr_envelopes.new()
+	e.Sbr_noise = adts.mem.sbr_noises.new()
 	if err := adts.sbr_grid(0, e.Sbr_grid, ext_data.Sbr_header); err != nil {
-		return e, err
+		return e, adts.check("sbr_single_channel_element", err)
 	}
 
 	adts.sbr_dtdf(0, e.Sbr_dtdf, e.Sbr_grid)
@@ -2019,7 +2442,7 @@ func (adts *ADTS) sbr_single_channel_element(ext_data *sbr_extension_data, bs_am
 	adts.sbr_envelope(0, false, bs_amp_res, e.Sbr_envelope, ext_data, e.Sbr_grid, e.Sbr_dtdf)
 	adts.sbr_noise(0, false, e.Sbr_noise, ext_data, e.Sbr_grid, e.Sbr_dtdf)
 	if e.Bs_add_harmonic_flag, _ = adts.reader.ReadBitAsBool(); e.Bs_add_harmonic_flag { // bs_add_harmonic_flag
-		e.Sbr_sinusoidal_coding = &sbr_sinusoidal_coding{}
+		e.Sbr_sinusoidal_coding = adts.mem.sbr_sinusoidal_codings.new()
 		adts.sbr_sinusoidal_coding(0, e.Sbr_sinusoidal_coding, ext_data)
 	}
 
@@ -2032,67 +2455,65 @@ func (adts *ADTS) sbr_single_channel_element(ext_data *sbr_extension_data, bs_am
 		}
 
 		num_bits_left := cnt * 8
-		if num_bits_left > MaxBitsLeft {
-			return e, fmt.Errorf("Too many bits left, check bitstream continuity")
+		if err := adts.extension_limit(int(cnt)); err != nil {
+			return e, adts.check("sbr_single_channel_element", err)
 		}
 
-		e.Bs_extension_id = make([]uint8, 0)
-		e.Sbr_extension = make([]*sbr_extension, 0)
+		e.Bs_extension_id = adts.mem.uint8s.make(0)
+		e.Sbr_extension = adts.mem.sbr_extension_list.make(0)
 		for i := 0; num_bits_left > 7; i++ {
 			ext_id, _ := adts.reader.ReadBitsAsUInt8(2)
-			e.Bs_extension_id = append(e.Bs_extension_id, ext_id)
-			if e.Bs_extension_id[i] == EXTENSION_ID_PS {
-				num_bits_left -= 2
+			e.Bs_extension_id = adts.mem.uint8s.append(e.Bs_extension_id, ext_id)
+			num_bits_left -= 2
+			if e.Bs_extension_id[i] != EXTENSION_ID_PS {
+				// Unknown extensions are left to the fill bits below
+				break
+			}
 
-				bits_read, ext, err := adts.sbr_extension(e.Bs_extension_id[i], num_bits_left)
-				if err != nil {
-					return e, err
-				}
-				e.Sbr_extension = append(e.Sbr_extension, ext)
+			bits_read, ext, err := adts.sbr_extension(e.Bs_extension_id[i], num_bits_left)
+			if err != nil {
+				return e, adts.check("sbr_single_channel_element", err)
+			}
+			e.Sbr_extension = adts.mem.sbr_extension_list.append(e.Sbr_extension, ext)
 
-				num_bits_left -= bits_read
-				if num_bits_left < 0 {
-					return e, fmt.Errorf("Error: SBR parsing overran available bits")
-				}
+			if bits_read > num_bits_left {
+				return e, adts.check("sbr_single_channel_element", fmt.Errorf("Error: SBR parsing overran available bits"))
 			}
+			num_bits_left -= bits_read
 		}
 
-		e.Bs_fill_bits, _ = adts.reader.ReadBitsToByteArray(num_bits_left)
+		e.Bs_fill_bits, _ = adts.read_bits_to_byte_array(num_bits_left)
 	}
 
-	return e, nil
+	return e, adts.check("sbr_single_channel_element", nil)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.66 – Syntax of sbr_channel_pair_element()
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) sbr_channel_pair_element(ext_data *sbr_extension_data, bs_amp_res bool) (*sbr_channel_pair_element, error) {
-	e := &sbr_channel_pair_element{}
+	e := adts.mem.sbr_channel_pair_elements.new()
 
 	if e.Bs_data_extra, _ = adts.reader.ReadBitAsBool(); e.Bs_data_extra { // bs_data_extra
 		e.Bs_reserved_0, _ = adts.reader.ReadBitsAsUInt8(4) // bs_reserved
 		e.Bs_reserved_1, _ = adts.reader.ReadBitsAsUInt8(4) // bs_reserved
+		if err := adts.strict(e.Bs_reserved_0 != 0 || e.Bs_reserved_1 != 0, ErrReserved, "Error: bs_reserved must equal 0"); err != nil {
+			return e, adts.check("sbr_channel_pair_element", err)
+		}
 	}
 
-	e.Sbr_grid = &sbr_grid{
-		Bs_var_bord_0: make([]uint8, 2),
-		Bs_var_bord_1: make([]uint8, 2),
-		Bs_num_rel_0:  make([]uint8, 2),
-		Bs_num_rel_1:  make([]uint8, 2),
-		bs_rel_bord_0: make([][]uint8, 2),
-		bs_rel_bord_1: make([][]uint8, 2),
-	}
-	e.Sbr_dtdf = &sbr_dtdf{}
-	e.Sbr_invf = &sbr_invf{}
-	e.Sbr_envelope = &sbr_envelope{}
-	e.Sbr_noise = &sbr_noise{}
+	e.Sbr_grid = adts.new_sbr_grid()
+	e.Sbr_dtdf = adts.mem.sbr_dtdfs.new()
+	e.Sbr_invf = adts.mem.sbr_invfs.new()
+	e.Sbr_envelope = adts.mem.sbr_envelopes.new()
+	e.Sbr_noise = adts.mem.sbr_noises.new()
 	if e.Bs_coupling, _ = adts.reader.ReadBitAsBool(); e.Bs_coupling { // bs_coupling
 		if err := adts.sbr_grid(0, e.Sbr_grid, ext_data.Sbr_header); err != nil {
-			return e, err
+			return e, adts.check("sbr_channel_pair_element", err)
 		}
 		// The spec isn't clear about this, but because this is a coupled channel we
 		// need to copy the grid and inverting filter from ch0 to ch1
-		grid_copy(e.Sbr_grid)
+		grid_copy(&adts.mem, e.Sbr_grid)
 
 		adts.sbr_dtdf(0, e.Sbr_dtdf, e.Sbr_grid)
 		adts.sbr_dtdf(1, e.Sbr_dtdf, e.Sbr_grid)
@@ -2105,10 +2526,10 @@ func (adts *ADTS) sbr_channel_pair_element(ext_data *sbr_extension_data, bs_amp_
 
 	} else {
 		if err := adts.sbr_grid(0, e.Sbr_grid, ext_data.Sbr_header); err != nil {
-			return e, err
+			return e, adts.check("sbr_channel_pair_element", err)
 		}
 		if err := adts.sbr_grid(1, e.Sbr_grid, ext_data.Sbr_header); err != nil {
-			return e, err
+			return e, adts.check("sbr_channel_pair_element", err)
 		}
 		adts.sbr_dtdf(0, e.Sbr_dtdf, e.Sbr_grid)
 		adts.sbr_dtdf(1, e.Sbr_dtdf, e.Sbr_grid)
@@ -2122,17 +2543,17 @@ func (adts *ADTS) sbr_channel_pair_element(ext_data *sbr_extension_data, bs_amp_
 	}
 
 	flag, _ := adts.reader.ReadBitAsBool()
-	e.Bs_add_harmonic_flag = append(e.Bs_add_harmonic_flag, flag)
-	e.Sbr_sinusoidal_coding = &sbr_sinusoidal_coding{}
+	e.Bs_add_harmonic_flag = adts.mem.bools.append(e.Bs_add_harmonic_flag, flag)
+	e.Sbr_sinusoidal_coding = adts.mem.sbr_sinusoidal_codings.new()
 	if e.Bs_add_harmonic_flag[0] { // bs_add_harmonic_flag
 		adts.sbr_sinusoidal_coding(0, e.Sbr_sinusoidal_coding, ext_data)
 	} else {
 		// We need to fill in the 0 channel in the struct to it indexes correctly
-		e.Sbr_sinusoidal_coding.Bs_add_harmonic = make([][]bool, 1)
+		e.Sbr_sinusoidal_coding.Bs_add_harmonic = adts.mem.bools_2d.make(1)
 	}
 
 	flag, _ = adts.reader.ReadBitAsBool()
-	e.Bs_add_harmonic_flag = append(e.Bs_add_harmonic_flag, flag)
+	e.Bs_add_harmonic_flag = adts.mem.bools.append(e.Bs_add_harmonic_flag, flag)
 	if e.Bs_add_harmonic_flag[1] { // bs_add_harmonic_flag
 		adts.sbr_sinusoidal_coding(1, e.Sbr_sinusoidal_coding, ext_data)
 	}
@@ -2148,43 +2569,39 @@ func (adts *ADTS) sbr_channel_pair_element(ext_data *sbr_extension_data, bs_amp_
 		// TODO: could we be a bit more graceful about this block?
 		num_bits_left := cnt * 8
 
-		if num_bits_left > MaxBitsLeft {
-			return e, fmt.Errorf("Too many bits left, check bitstream continuity")
+		if err := adts.extension_limit(int(cnt)); err != nil {
+			return e, adts.check("sbr_channel_pair_element", err)
 		}
 
 		// Extentions are currently unsupported
 		adts.reader.SkipBits(num_bits_left)
 	}
-	return e, nil
+	return e, adts.check("sbr_channel_pair_element", nil)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.67 – Syntax of sbr_channel_pair_base_element()
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) sbr_channel_pair_base_element(bs_amp_res bool, ext_data *sbr_extension_data) (*sbr_channel_pair_base_element, error) {
-	e := &sbr_channel_pair_base_element{}
+	e := adts.mem.sbr_channel_pair_base_elements.new()
 
 	if e.Bs_data_extra, _ = adts.reader.ReadBitAsBool(); e.Bs_data_extra { // bs_data_extra
 		e.Bs_reserved_0, _ = adts.reader.ReadBitsAsUInt8(4) // bs_reserved
 		e.Bs_reserved_1, _ = adts.reader.ReadBitsAsUInt8(4) // bs_reserved
+		if err := adts.strict(e.Bs_reserved_0 != 0 || e.Bs_reserved_1 != 0, ErrReserved, "Error: bs_reserved must equal 0"); err != nil {
+			return e, adts.check("sbr_channel_pair_base_element", err)
+		}
 	}
 
 	e.Bs_coupling, _ = adts.reader.ReadBitAsBool()
 
-	e.Sbr_grid = &sbr_grid{
-		Bs_var_bord_0: make([]uint8, 2),
-		Bs_var_bord_1: make([]uint8, 2),
-		Bs_num_rel_0:  make([]uint8, 2),
-		Bs_num_rel_1:  make([]uint8, 2),
-		bs_rel_bord_0: make([][]uint8, 2),
-		bs_rel_bord_1: make([][]uint8, 2),
-	}
-	e.Sbr_dtdf = &sbr_dtdf{}
-	e.Sbr_invf = &sbr_invf{}
-	e.Sbr_envelope = &sbr_envelope{}
-	e.Sbr_noise = &sbr_noise{}
+	e.Sbr_grid = adts.new_sbr_grid()
+	e.Sbr_dtdf = adts.mem.sbr_dtdfs.new()
+	e.Sbr_invf = adts.mem.sbr_invfs.new()
+	e.Sbr_envelope = adts.mem.sbr_envelopes.new()
+	e.Sbr_noise = adts.mem.sbr_noises.new()
 	if err := adts.sbr_grid(0, e.Sbr_grid, ext_data.Sbr_header); err != nil {
-		return e, err
+		return e, adts.check("sbr_channel_pair_base_element", err)
 	}
 
 	adts.sbr_dtdf(0, e.Sbr_dtdf, e.Sbr_grid)
@@ -2193,7 +2610,7 @@ func (adts *ADTS) sbr_channel_pair_base_element(bs_amp_res bool, ext_data *sbr_e
 	adts.sbr_noise(0, true, e.Sbr_noise, ext_data, e.Sbr_grid, e.Sbr_dtdf)
 
 	if e.Bs_add_harmonic_flag, _ = adts.reader.ReadBitAsBool(); e.Bs_add_harmonic_flag { // bs_add_harmonic_flag
-		e.Sbr_sinusoidal_coding = &sbr_sinusoidal_coding{}
+		e.Sbr_sinusoidal_coding = adts.mem.sbr_sinusoidal_codings.new()
 		adts.sbr_sinusoidal_coding(0, e.Sbr_sinusoidal_coding, ext_data)
 	}
 
@@ -2207,35 +2624,37 @@ func (adts *ADTS) sbr_channel_pair_base_element(bs_amp_res bool, ext_data *sbr_e
 
 		// TODO: could we be a bit more graceful about this block?
 		num_bits_left := cnt * 8
-		if num_bits_left > MaxBitsLeft {
-			return e, fmt.Errorf("Too many bits left, check bitstream continuity")
+		if err := adts.extension_limit(int(cnt)); err != nil {
+			return e, adts.check("sbr_channel_pair_base_element", err)
 		}
 
-		e.Bs_extension_id = make([]uint8, 0)
-		e.Sbr_extension = make([]*sbr_extension, 0)
+		e.Bs_extension_id = adts.mem.uint8s.make(0)
+		e.Sbr_extension = adts.mem.sbr_extension_list.make(0)
 		for i := 0; num_bits_left > 7; i++ {
 			ext_id, _ := adts.reader.ReadBitsAsUInt8(2)
-			e.Bs_extension_id = append(e.Bs_extension_id, ext_id)
-			if e.Bs_extension_id[i] == EXTENSION_ID_PS {
-				num_bits_left -= 2
+			e.Bs_extension_id = adts.mem.uint8s.append(e.Bs_extension_id, ext_id)
+			num_bits_left -= 2
+			if e.Bs_extension_id[i] != EXTENSION_ID_PS {
+				// Unknown extensions are left to the fill bits below
+				break
+			}
 
-				bits_read, ext, err := adts.sbr_extension(e.Bs_extension_id[i], num_bits_left)
-				if err != nil {
-					return e, err
-				}
-				e.Sbr_extension = append(e.Sbr_extension, ext)
+			bits_read, ext, err := adts.sbr_extension(e.Bs_extension_id[i], num_bits_left)
+			if err != nil {
+				return e, adts.check("sbr_channel_pair_base_element", err)
+			}
+			e.Sbr_extension = adts.mem.sbr_extension_list.append(e.Sbr_extension, ext)
 
-				num_bits_left -= bits_read
-				if num_bits_left < 0 {
-					return e, fmt.Errorf("Error: SBR parsing overran available bits")
-				}
+			if bits_read > num_bits_left {
+				return e, adts.check("sbr_channel_pair_base_element", fmt.Errorf("Error: SBR parsing overran available bits"))
 			}
+			num_bits_left -= bits_read
 		}
 
-		e.Bs_fill_bits, _ = adts.reader.ReadBitsToByteArray(num_bits_left)
+		e.Bs_fill_bits, _ = adts.read_bits_to_byte_array(num_bits_left)
 	}
 
-	return e, nil
+	return e, adts.check("sbr_channel_pair_base_element", nil)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -2262,42 +2681,52 @@ func (adts *ADTS) sbr_channel_pair_enhance_element(bs_amp_res bool) *sbr_channel
 // Table 4.69 – Syntax of sbr_grid()
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) sbr_grid(ch uint, data *sbr_grid, header *sbr_header) error {
-	data.Bs_frame_class[ch], _ = adts.reader.ReadBitsAsUInt8(2)
+	data.num_time_slots = adts.sbr_num_time_slots()
+	if adts.Profile == AUDIO_OBJECT_TYPE_ER_AAC_ELD {
+		// Low delay SBR only has FIXFIX and the LD_TRAN class
+		if ld_tran, _ := adts.reader.ReadBitAsBool(); ld_tran {
+			data.Bs_frame_class[ch] = LD_TRAN
+		} else {
+			data.Bs_frame_class[ch] = FIXFIX
+		}
+	} else {
+		data.Bs_frame_class[ch], _ = adts.reader.ReadBitsAsUInt8(2)
+	}
 	switch data.Bs_frame_class[ch] {
 	case FIXFIX:
 		data.Tmp, _ = adts.reader.ReadBitsAsUInt8(2)
-		data.bs_num_env = append(data.bs_num_env, 1<<data.Tmp)
+		data.bs_num_env = adts.mem.uint8s.append(data.bs_num_env, 1<<data.Tmp)
 
 		if data.bs_num_env[ch] == 1 {
 			header.Bs_amp_res = false
 		}
 
-		data.Bs_freq_res = append(data.Bs_freq_res, make([]uint8, data.bs_num_env[ch]))
+		data.Bs_freq_res = adts.mem.uint8s_2d.append(data.Bs_freq_res, adts.mem.uint8s.make(int(data.bs_num_env[ch])))
 		data.Bs_freq_res[ch][0], _ = adts.reader.ReadBitsAsUInt8(1)
 		for env := uint8(1); env < data.bs_num_env[ch]; env++ {
 			data.Bs_freq_res[ch][env] = data.Bs_freq_res[ch][0]
 		}
 
 		// Initialize this to 0 for this channel to keep the chan index correct
-		data.Bs_pointer = append(data.Bs_pointer, 0)
+		data.Bs_pointer = adts.mem.uints.append(data.Bs_pointer, 0)
 	case FIXVAR:
 		tmp, _ := adts.reader.ReadBitsAsUInt8(2)
 		data.Bs_var_bord_1[ch] = tmp
 		tmp, _ = adts.reader.ReadBitsAsUInt8(2)
 		data.Bs_num_rel_1[ch] = tmp
-		data.bs_num_env = append(data.bs_num_env, data.Bs_num_rel_1[ch]+1)
+		data.bs_num_env = adts.mem.uint8s.append(data.bs_num_env, data.Bs_num_rel_1[ch]+1)
 
-		data.bs_rel_bord_1[ch] = make([]uint8, data.bs_num_env[ch]-1)
+		data.bs_rel_bord_1[ch] = adts.mem.uint8s.make(int(data.bs_num_env[ch] - 1))
 		for rel := range data.bs_rel_bord_1[ch] {
 			data.Tmp, _ = adts.reader.ReadBitsAsUInt8(2)
-			data.bs_rel_bord_1[ch][rel] = 2*data.Tmp + 1
+			data.bs_rel_bord_1[ch][rel] = 2*data.Tmp + 2
 		}
 
 		ptr_bits := ceil_log2(data.bs_num_env[ch] + 1)
 		ptr, _ := adts.reader.ReadBitsAsUInt(uint(ptr_bits))
-		data.Bs_pointer = append(data.Bs_pointer, ptr)
+		data.Bs_pointer = adts.mem.uints.append(data.Bs_pointer, ptr)
 
-		data.Bs_freq_res = append(data.Bs_freq_res, make([]uint8, data.bs_num_env[ch]))
+		data.Bs_freq_res = adts.mem.uint8s_2d.append(data.Bs_freq_res, adts.mem.uint8s.make(int(data.bs_num_env[ch])))
 		for env := range data.Bs_freq_res[ch] {
 			data.Bs_freq_res[ch][data.bs_num_env[ch]-1-uint8(env)], _ = adts.reader.ReadBitsAsUInt8(1)
 		}
@@ -2306,9 +2735,9 @@ func (adts *ADTS) sbr_grid(ch uint, data *sbr_grid, header *sbr_header) error {
 		data.Bs_var_bord_0[ch] = tmp
 		tmp, _ = adts.reader.ReadBitsAsUInt8(2)
 		data.Bs_num_rel_0[ch] = tmp
-		data.bs_num_env = append(data.bs_num_env, data.Bs_num_rel_0[ch]+1)
+		data.bs_num_env = adts.mem.uint8s.append(data.bs_num_env, data.Bs_num_rel_0[ch]+1)
 
-		data.bs_rel_bord_0[ch] = make([]uint8, data.bs_num_env[ch]-1)
+		data.bs_rel_bord_0[ch] = adts.mem.uint8s.make(int(data.bs_num_env[ch] - 1))
 		for rel := range data.bs_rel_bord_0[ch] {
 			data.Tmp, _ = adts.reader.ReadBitsAsUInt8(2)
 			data.bs_rel_bord_0[ch][rel] = 2*data.Tmp + 2
@@ -2316,9 +2745,9 @@ func (adts *ADTS) sbr_grid(ch uint, data *sbr_grid, header *sbr_header) error {
 
 		ptr_bits := ceil_log2(data.bs_num_env[ch] + 1)
 		ptr, _ := adts.reader.ReadBitsAsUInt(uint(ptr_bits))
-		data.Bs_pointer = append(data.Bs_pointer, ptr)
+		data.Bs_pointer = adts.mem.uints.append(data.Bs_pointer, ptr)
 
-		data.Bs_freq_res = append(data.Bs_freq_res, make([]uint8, data.bs_num_env[ch]))
+		data.Bs_freq_res = adts.mem.uint8s_2d.append(data.Bs_freq_res, adts.mem.uint8s.make(int(data.bs_num_env[ch])))
 		for env := range data.Bs_freq_res[ch] {
 			data.Bs_freq_res[ch][env], _ = adts.reader.ReadBitsAsUInt8(1)
 		}
@@ -2332,7 +2761,7 @@ func (adts *ADTS) sbr_grid(ch uint, data *sbr_grid, header *sbr_header) error {
 		tmp, _ = adts.reader.ReadBitsAsUInt8(2)
 		data.Bs_num_rel_1[ch] = tmp
 
-		data.bs_num_env = append(
+		data.bs_num_env = adts.mem.uint8s.append(
 			data.bs_num_env,
 			uint8(minInt(5, int(data.Bs_num_rel_0[ch]+data.Bs_num_rel_1[ch]+1))),
 		)
@@ -2340,13 +2769,13 @@ func (adts *ADTS) sbr_grid(ch uint, data *sbr_grid, header *sbr_header) error {
 		// This is how the spec spells this section out.  We could make Bs_num_rel into
 		// a 2d array and use one loop, but for the sake of keeping variable names consistent
 		// with the spec we'll follow their two loop method
-		data.bs_rel_bord_0[ch] = make([]uint8, data.Bs_num_rel_0[ch])
+		data.bs_rel_bord_0[ch] = adts.mem.uint8s.make(int(data.Bs_num_rel_0[ch]))
 		for rel := range data.bs_rel_bord_0[ch] {
 			data.Tmp, _ = adts.reader.ReadBitsAsUInt8(2)
 			data.bs_rel_bord_0[ch][rel] = data.Tmp*2 + 2
 		}
 
-		data.bs_rel_bord_1[ch] = make([]uint8, data.Bs_num_rel_1[ch])
+		data.bs_rel_bord_1[ch] = adts.mem.uint8s.make(int(data.Bs_num_rel_1[ch]))
 		for rel := range data.bs_rel_bord_1[ch] {
 			data.Tmp, _ = adts.reader.ReadBitsAsUInt8(2)
 			data.bs_rel_bord_1[ch][rel] = data.Tmp*2 + 2
@@ -2354,34 +2783,123 @@ func (adts *ADTS) sbr_grid(ch uint, data *sbr_grid, header *sbr_header) error {
 
 		ptr_bits := ceil_log2(data.bs_num_env[ch] + 1)
 		ptr, _ := adts.reader.ReadBitsAsUInt(uint(ptr_bits))
-		data.Bs_pointer = append(data.Bs_pointer, ptr)
+		data.Bs_pointer = adts.mem.uints.append(data.Bs_pointer, ptr)
 
-		data.Bs_freq_res = append(data.Bs_freq_res, make([]uint8, data.bs_num_env[ch]))
+		data.Bs_freq_res = adts.mem.uint8s_2d.append(data.Bs_freq_res, adts.mem.uint8s.make(int(data.bs_num_env[ch])))
+		for env := range data.Bs_freq_res[ch] {
+			data.Bs_freq_res[ch][env], _ = adts.reader.ReadBitsAsUInt8(1)
+		}
+	case LD_TRAN:
+		data.Bs_transient_position[ch], _ = adts.reader.ReadBitsAsUInt8(4)
+		if data.Bs_transient_position[ch] >= data.num_time_slots {
+			return adts.check("sbr_grid", parseErrorf(ErrOutOfRange, "Error: bs_transient_position[%d] (%d) is out of range for %d time slots",
+				ch, data.Bs_transient_position[ch], data.num_time_slots))
+		}
+		data.bs_num_env = adts.mem.uint8s.append(data.bs_num_env, data.ld_envelope(int(ch))[0])
+
+		data.Bs_pointer = adts.mem.uints.append(data.Bs_pointer, 0)
+
+		data.Bs_freq_res = adts.mem.uint8s_2d.append(data.Bs_freq_res, adts.mem.uint8s.make(int(data.bs_num_env[ch])))
 		for env := range data.Bs_freq_res[ch] {
 			data.Bs_freq_res[ch][env], _ = adts.reader.ReadBitsAsUInt8(1)
 		}
 	}
 
 	if data.bs_num_env[ch] > 1 {
-		data.bs_num_noise = append(data.bs_num_noise, 2)
+		data.bs_num_noise = adts.mem.uint8s.append(data.bs_num_noise, 2)
 	} else if data.bs_num_env[ch] == 1 {
-		data.bs_num_noise = append(data.bs_num_noise, 1)
+		data.bs_num_noise = adts.mem.uint8s.append(data.bs_num_noise, 1)
 	} else {
-		return fmt.Errorf("Error: bs_num_env[%d] (%d) is out of range", ch, data.bs_num_env[ch])
+		return adts.check("sbr_grid", fmt.Errorf("Error: bs_num_env[%d] (%d) is out of range", ch, data.bs_num_env[ch]))
 	}
-	return nil
+	return adts.check("sbr_grid", nil)
+}
+
+// numTimeSlots of the SBR frame, one less for the 960 and 480 sample frames
+// of DAB+ and DRM.  Low delay SBR has a time slot per 32 samples of its
+// 512 or 480 sample frames at dual and single rate alike, single rate
+// only halves the QMF bands.
+func (adts *ADTS) sbr_num_time_slots() uint8 {
+	if adts.Profile == AUDIO_OBJECT_TYPE_ER_AAC_ELD {
+		return uint8(adts.Frame_length / 32)
+	}
+	if adts.Frame_length == 960 || adts.Frame_length == 480 {
+		return SBR_NUM_TIME_SLOTS_960
+	}
+	return SBR_NUM_TIME_SLOTS
+}
+
+// Envelope time borders t_E of a channel in time slots, bs_num_env+1 of
+// them from the leading to the trailing border of the frame.
+func (data *sbr_grid) EnvelopeBorders(ch int) []uint8 {
+	if ch >= len(data.bs_num_env) {
+		return nil
+	}
+	num_time_slots := data.num_time_slots
+	if num_time_slots == 0 {
+		num_time_slots = SBR_NUM_TIME_SLOTS
+	}
+	num_env := int(data.bs_num_env[ch])
+
+	var abs_bord_lead, abs_bord_trail uint8 = 0, num_time_slots
+	var rel_bord_lead, rel_bord_trail []uint8
+	switch data.Bs_frame_class[ch] {
+	case FIXFIX:
+		// Evenly spaced, rounded for 15 time slots
+		rel_bord_lead = make([]uint8, num_env-1)
+		for l := range rel_bord_lead {
+			rel_bord_lead[l] = uint8((int(num_time_slots) + num_env/2) / num_env)
+		}
+	case FIXVAR:
+		abs_bord_trail += data.Bs_var_bord_1[ch]
+		rel_bord_trail = data.bs_rel_bord_1[ch]
+	case VARFIX:
+		abs_bord_lead = data.Bs_var_bord_0[ch]
+		rel_bord_lead = data.bs_rel_bord_0[ch]
+	case VARVAR:
+		abs_bord_lead = data.Bs_var_bord_0[ch]
+		abs_bord_trail += data.Bs_var_bord_1[ch]
+		rel_bord_lead = data.bs_rel_bord_0[ch]
+		rel_bord_trail = data.bs_rel_bord_1[ch]
+	case LD_TRAN:
+		env := data.ld_envelope(ch)
+		borders := make([]uint8, num_env+1)
+		copy(borders[1:num_env], env[1:])
+		borders[num_env] = num_time_slots
+		return borders
+	}
+
+	borders := make([]uint8, num_env+1)
+	borders[0] = abs_bord_lead
+	borders[num_env] = abs_bord_trail
+	for l := 1; l <= len(rel_bord_lead) && l < num_env; l++ {
+		borders[l] = borders[l-1] + rel_bord_lead[l-1]
+	}
+	for i := 0; i < len(rel_bord_trail) && num_env-1-i > len(rel_bord_lead); i++ {
+		borders[num_env-1-i] = borders[num_env-i] - rel_bord_trail[i]
+	}
+	return borders
+}
+
+// The row of the LD envelope table for the bs_transient_position of an
+// LD_TRAN channel
+func (data *sbr_grid) ld_envelope(ch int) [3]uint8 {
+	if data.num_time_slots == SBR_NUM_TIME_SLOTS_960 {
+		return ldEnvelopeTable15[data.Bs_transient_position[ch]]
+	}
+	return ldEnvelopeTable16[data.Bs_transient_position[ch]]
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Table 4.70 – Syntax of sbr_dtdf()
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) sbr_dtdf(ch uint8, data *sbr_dtdf, grid *sbr_grid) {
-	data.Bs_df_env = append(data.Bs_df_env, make([]bool, grid.bs_num_env[ch]))
+	data.Bs_df_env = adts.mem.bools_2d.append(data.Bs_df_env, adts.mem.bools.make(int(grid.bs_num_env[ch])))
 	for env := range data.Bs_df_env[ch] {
 		data.Bs_df_env[ch][env], _ = adts.reader.ReadBitAsBool() // bs_df_env[ch][env]
 	}
 
-	data.Bs_df_noise = append(data.Bs_df_noise, make([]bool, grid.bs_num_noise[ch]))
+	data.Bs_df_noise = adts.mem.bools_2d.append(data.Bs_df_noise, adts.mem.bools.make(int(grid.bs_num_noise[ch])))
 	for noise := range data.Bs_df_noise[ch] {
 		data.Bs_df_noise[ch][noise], _ = adts.reader.ReadBitAsBool() // bs_df_noise[ch][noise]
 	}
@@ -2391,7 +2909,7 @@ func (adts *ADTS) sbr_dtdf(ch uint8, data *sbr_dtdf, grid *sbr_grid) {
 // Table 4.71 – Syntax of sbr_invf()
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) sbr_invf(ch uint, data *sbr_invf, ext_data *sbr_extension_data) {
-	data.Bs_invf_mode = append(data.Bs_invf_mode, make([]uint8, ext_data.N_Q))
+	data.Bs_invf_mode = adts.mem.uint8s_2d.append(data.Bs_invf_mode, adts.mem.uint8s.make(int(ext_data.N_Q)))
 	for n := range data.Bs_invf_mode[ch] {
 		data.Bs_invf_mode[ch][n], _ = adts.reader.ReadBitsAsUInt8(2) // bs_invf_mode[ch][n]
 	}
@@ -2430,11 +2948,11 @@ func (adts *ADTS) sbr_envelope(
 		}
 	}
 
-	e.Bs_data_env = append(e.Bs_data_env, make([][]int, grid.bs_num_env[ch]))
+	e.Bs_data_env = adts.mem.ints_3d.append(e.Bs_data_env, adts.mem.ints_2d.make(int(grid.bs_num_env[ch])))
 	for env := range e.Bs_data_env[ch] {
 		if dtdf.Bs_df_env[ch][env] == false {
 			num_bands := ext_data.n[grid.Bs_freq_res[ch][env]]
-			e.Bs_data_env[ch][env] = make([]int, num_bands)
+			e.Bs_data_env[ch][env] = adts.mem.ints.make(int(num_bands))
 			if bs_coupling && ch == 1 {
 				if amp_res {
 					e.Bs_env_start_value_balance, _ = adts.reader.ReadBitsAsUInt8(5) // bs_env_start_value_balance
@@ -2458,7 +2976,7 @@ func (adts *ADTS) sbr_envelope(
 			}
 		} else {
 			num_bands := ext_data.n[grid.Bs_freq_res[ch][env]]
-			e.Bs_data_env[ch][env] = make([]int, num_bands)
+			e.Bs_data_env[ch][env] = adts.mem.ints.make(int(num_bands))
 			for band := range e.Bs_data_env[ch][env] {
 				e.Bs_data_env[ch][env][band] = sbr_huff_dec(adts.reader, t_huff)
 			}
@@ -2484,9 +3002,9 @@ func (adts *ADTS) sbr_noise(
 		f_huff = f_huffman_env_3_0dB
 	}
 
-	data.Bs_data_noise = append(data.Bs_data_noise, make([][]int, grid.bs_num_noise[ch]))
+	data.Bs_data_noise = adts.mem.ints_3d.append(data.Bs_data_noise, adts.mem.ints_2d.make(int(grid.bs_num_noise[ch])))
 	for noise := range data.Bs_data_noise[ch] {
-		data.Bs_data_noise[ch][noise] = make([]int, ext_data.N_Q)
+		data.Bs_data_noise[ch][noise] = adts.mem.ints.make(int(ext_data.N_Q))
 		if dtdf.Bs_df_noise[ch][noise] == false {
 			if bs_coupling && ch == 1 {
 				data.Bs_noise_start_value_balance, _ = adts.reader.ReadBitsAsUInt8(5)
@@ -2510,7 +3028,7 @@ func (adts *ADTS) sbr_noise(
 // Table 4.74 – Syntax of sbr_sinusoidal_coding()
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) sbr_sinusoidal_coding(ch uint8, data *sbr_sinusoidal_coding, ext_data *sbr_extension_data) {
-	data.Bs_add_harmonic = append(data.Bs_add_harmonic, make([]bool, ext_data.N_high))
+	data.Bs_add_harmonic = adts.mem.bools_2d.append(data.Bs_add_harmonic, adts.mem.bools.make(int(ext_data.N_high)))
 	for n := range data.Bs_add_harmonic[ch] {
 		data.Bs_add_harmonic[ch][n], _ = adts.reader.ReadBitAsBool()
 	}
@@ -2520,19 +3038,19 @@ func (adts *ADTS) sbr_sinusoidal_coding(ch uint8, data *sbr_sinusoidal_coding, e
 // Table 8.A.1 – Syntax of sbr_extension()
 ////////////////////////////////////////////////////////////////////////////////
 func (adts *ADTS) sbr_extension(bs_extension_id uint8, num_bits_left uint) (uint, *sbr_extension, error) {
-	data := &sbr_extension{}
+	data := adts.mem.sbr_extensions.new()
 	switch bs_extension_id {
 	case EXTENSION_ID_PS:
 		// he-AAC v2 - currently unsupported
 		// TODO
 		// num_bits_left -= ps_data()
 		// returning num_bits_left tells the caller that all bits have been read
-		return num_bits_left, nil, fmt.Errorf("bs_extension_id of 2 (EXTENSION_ID_PS) unsupported")
+		return num_bits_left, nil, adts.check("sbr_extension", parseErrorf(ErrUnsupported, "Error: bs_extension_id of 2 (EXTENSION_ID_PS) unsupported"))
 	default:
 		//data.Bs_fill_bits, _ = adts.reader.ReadBitsToByteArray(num_bits_left)
 	}
 	// returning num_bits_left tells the caller that all bits have been read
-	return num_bits_left, data, nil
+	return num_bits_left, data, adts.check("sbr_extension", nil)
 }
 
 func is_intensity(info *ics_info, group, sfb uint8) int {
@@ -2561,12 +3079,25 @@ func ceil_log2(val uint8) uint8 {
 	return 0
 }
 
+// A grid with the borders of both channels
+func (adts *ADTS) new_sbr_grid() *sbr_grid {
+	grid := adts.mem.sbr_grids.new()
+	grid.Bs_var_bord_0 = adts.mem.uint8s.make(2)
+	grid.Bs_var_bord_1 = adts.mem.uint8s.make(2)
+	grid.Bs_num_rel_0 = adts.mem.uint8s.make(2)
+	grid.Bs_num_rel_1 = adts.mem.uint8s.make(2)
+	grid.Bs_transient_position = adts.mem.uint8s.make(2)
+	grid.bs_rel_bord_0 = adts.mem.uint8s_2d.make(2)
+	grid.bs_rel_bord_1 = adts.mem.uint8s_2d.make(2)
+	return grid
+}
+
 // Copy function for coupled channels
-func grid_copy(grid *sbr_grid) {
-	grid.Bs_freq_res = append(grid.Bs_freq_res, grid.Bs_freq_res[0])
-	grid.Bs_pointer = append(grid.Bs_pointer, grid.Bs_pointer[0])
-	grid.bs_num_env = append(grid.bs_num_env, grid.bs_num_env[0])
-	grid.bs_num_noise = append(grid.bs_num_noise, grid.bs_num_noise[0])
+func grid_copy(mem *frame_memory, grid *sbr_grid) {
+	grid.Bs_freq_res = mem.uint8s_2d.append(grid.Bs_freq_res, grid.Bs_freq_res[0])
+	grid.Bs_pointer = mem.uints.append(grid.Bs_pointer, grid.Bs_pointer[0])
+	grid.bs_num_env = mem.uint8s.append(grid.bs_num_env, grid.bs_num_env[0])
+	grid.bs_num_noise = mem.uint8s.append(grid.bs_num_noise, grid.bs_num_noise[0])
 
 	// We initialize these in each function before we populate the initial grid, so they're
 	// treated differently here as well
@@ -2574,6 +3105,7 @@ func grid_copy(grid *sbr_grid) {
 	grid.Bs_num_rel_1[1] = grid.Bs_num_rel_1[0]
 	grid.Bs_var_bord_0[1] = grid.Bs_var_bord_0[0]
 	grid.Bs_var_bord_1[1] = grid.Bs_var_bord_1[0]
+	grid.Bs_transient_position[1] = grid.Bs_transient_position[0]
 	grid.bs_rel_bord_0[1] = grid.bs_rel_bord_0[0]
 	grid.bs_rel_bord_1[1] = grid.bs_rel_bord_1[0]
 }
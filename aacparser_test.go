@@ -19,7 +19,12 @@ package gaad
 import (
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/Comcast/gaad/bitreader"
 )
 
 func TestAacLcADTS(t *testing.T) {
@@ -246,6 +251,40 @@ func TestSbrChannelPairElementParse(t *testing.T) {
 	}
 }
 
+// Elements must be reported in bitstream order so the SBR fill element can be
+// associated with the channel pair element that precedes it
+func TestRawDataBlockElementOrder(t *testing.T) {
+	buf, err := base64.StdEncoding.DecodeString(
+		"//FYgD+BnCEbQ9uJooYaHEyKrWaAZUmYoqZdSkJUqh/ZoD0ZT3iVbMTgNe8FuI6HSt1vZoAcY13PUkjIqz2PXN82feqhznnAoQwAnAYzxDC3VbYX7x1oyZMJJDh0nozSuFKVaJUjeJVUi01puKLL3LdgXpQyDr0hZa9PAvAtQvxBLVNTXGa6FRWh2VU1r+fA3WhRZP1ChIYijIlCvUJgErbYRTWCgmIghE9cuudbSXxrNF5VNQ3LbkkuRBA6hl73rHJa8C2vUAhslyiC8nspS800OvMc3YzrTHnNlrcYMCXC/Bwha6I5KDVXi3O5UQaDWyinMlymVxOeNUp3FbdbWC4+KfCKGNPbI0aNSYxoo1b0xpUqoWRTZ1p8UU5gqnHFRzrmnThZGNyD8MZa7Y/NYOdthRkyZyD+S1nc0HDm2WoPc7O23AWWNkcLa/kTFekvbxFTk6DcJjxM3IQeqK2gKo5NynkRqIKyFspol79egkg3O8DIf68aWV/C4qjg0r5ODFsMHZQfTQI7ukK2Fh33vrHrN2OlhayZAQJp0gE2DCaInTHGgCpmmag8WmNqWBDZmRmZgmxZobHrmRPKCRtiEkAX8pJblEvdB61vNxNE3WJlvefJV7Y+FLJAXUA3gG8N37ATwNsYoCIAAAAAE/fwe/879/u5fmSNCBAA8w==")
+	if err != nil {
+		t.Errorf("DecodeString: %s", err)
+	}
+	adts, err := ParseADTS(buf)
+	if err != nil {
+		t.Errorf("err (%s) must be nil", err.Error())
+	}
+	if len(adts.Raw_data_blocks) != 1 {
+		t.Fatalf("Raw_data_blocks length (%d) must be 1", len(adts.Raw_data_blocks))
+	}
+
+	want := []uint8{ID_CPE, ID_FIL, ID_END}
+	elements := adts.Raw_data_blocks[0].Elements
+	if len(elements) != len(want) {
+		t.Fatalf("Elements length (%d) must be %d", len(elements), len(want))
+	}
+	for i := range want {
+		if elements[i].ID() != want[i] {
+			t.Errorf("Elements[%d] id (%d) must be %d", i, elements[i].ID(), want[i])
+		}
+	}
+	if elements[0] != adts.Channel_pair_elements[0] {
+		t.Errorf("Elements[0] must be the same element as Channel_pair_elements[0]")
+	}
+	if elements[1] != adts.Fill_elements[0] {
+		t.Errorf("Elements[1] must be the same element as Fill_elements[0]")
+	}
+}
+
 // AAC Audio frame with a full, parsable SBR
 func TestSbrParse(t *testing.T) {
 	buf, err := base64.StdEncoding.DecodeString(
@@ -311,3 +350,365 @@ func TestADTSHeaderFuzz(t *testing.T) {
 		ParseADTS([]byte(f))
 	}
 }
+
+// Every prefix of a frame must fail with the syntax element the data ran out
+// in rather than parse garbage or panic
+func TestTruncatedFrame(t *testing.T) {
+	buf, _ := hex.DecodeString("fff94c8021600c21098bfffff7fff21646d20c0a44441020211465018f4dfe6fbb9d46e1bd57a7287afdc88a54ab41c9657781d576e1a4969091168afbb4b99bd76bd5cebeeedeeb35e3364f21dab6a4ade022f200cf5e1988838045824d12d1244fcf9effc766dd7cecd36e01ea20ad7b0368552f274bdf84480892931ac5195b02f25116b63359639b42b4f7571433320b57a13cec7831ddfb559135c9652ae1a396b5be6ebb631162")
+
+	for n := 2; n <= len(buf); n++ {
+		_, err := ParseADTS(buf[:n])
+		if !errors.Is(err, bitreader.ErrReaderOutOfBounds) {
+			t.Fatalf("%d bytes: err (%v) must wrap ErrReaderOutOfBounds", n, err)
+		}
+	}
+
+	_, err := ParseADTS(buf[:15])
+	var perr *ParseError
+	if !errors.As(err, &perr) || !errors.Is(err, ErrTruncated) {
+		t.Fatalf("err (%v) must be a truncated ParseError", err)
+	}
+	if perr.Path != "raw_data_block[0]/CPE[0]/ics[0]/section_data" || perr.Offset != 115 {
+		t.Errorf("err (%v) must be at section_data of the first channel, bit 115", err)
+	}
+}
+
+// A frame cut inside its fill element keeps the CPE before it and marks
+// the fill element as the one that failed
+func TestIncompleteElement(t *testing.T) {
+	buf := adtsFrame(1, 3, 2, func(w *bitWriter) {
+		w.write(ID_CPE, 3)
+		w.write(0, 4) // element_instance_tag
+		w.write(0, 1) // common_window
+		writeLcIcs(w, 0)
+		writeLcIcs(w, 0)
+		w.write(ID_FIL, 3)
+		w.write(8, 4) // count
+		w.write(EXT_SBR_DATA, 4)
+		w.write(0, 60)
+	})
+	buf = buf[:(56+70+20)/8]
+
+	for _, options := range []Options{{}, {Mode: PARSE_LENIENT}} {
+		adts, err := ParseADTSWithOptions(buf, options)
+		if options.Mode == PARSE_LENIENT && err != nil {
+			t.Errorf("err (%v) must be nil in lenient mode", err)
+		} else if options.Mode != PARSE_LENIENT && !errors.Is(err, ErrTruncated) {
+			t.Errorf("err (%v) must be ErrTruncated", err)
+		}
+		if adts.SamplingFrequency != 48000 {
+			t.Errorf("SamplingFrequency (%d) must be 48000", adts.SamplingFrequency)
+		}
+
+		block := adts.Raw_data_blocks[0]
+		if !errors.Is(block.Err, ErrTruncated) {
+			t.Errorf("block Err (%v) must be ErrTruncated", block.Err)
+		}
+		if options.Mode != PARSE_LENIENT && block.Err != err {
+			t.Errorf("block Err (%v) must be the frame's error (%v)", block.Err, err)
+		}
+		if len(block.Elements) != 2 {
+			t.Fatalf("Elements length (%d) must be 2", len(block.Elements))
+		}
+		if cpe := block.Elements[0]; cpe.Incomplete() || cpe.Err() != nil {
+			t.Errorf("the CPE must be complete, Err (%v)", cpe.Err())
+		}
+		if fil := block.Elements[1]; !fil.Incomplete() || fil.Err() != block.Err {
+			t.Errorf("the fill element must be incomplete with the block's error, Err (%v)", fil.Err())
+		}
+	}
+}
+
+// bitWriter builds synthetic bitstreams for syntax that is hard to capture
+// from real encoders
+type bitWriter struct {
+	buf  []byte
+	bits uint
+}
+
+func (w *bitWriter) write(val uint64, n uint) {
+	for i := n; i > 0; i-- {
+		if w.bits%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if (val>>(i-1))&1 == 1 {
+			w.buf[len(w.buf)-1] |= 0x80 >> (w.bits % 8)
+		}
+		w.bits++
+	}
+}
+
+func (w *bitWriter) byteAlign() {
+	w.bits = uint(len(w.buf)) * 8
+}
+
+// Frames with more than one raw_data_block must report each block with its
+// position and CRC, and count every block as an access unit
+func TestMultipleRawDataBlocks(t *testing.T) {
+	w := &bitWriter{}
+	w.write(0xfff, 12)  // syncword
+	w.write(1, 1)       // ID
+	w.write(0, 2)       // layer
+	w.write(0, 1)       // protection_absent
+	w.write(1, 2)       // profile_ObjectType (AAC LC)
+	w.write(3, 4)       // sampling_frequency_index (48000)
+	w.write(0, 1)       // private_bit
+	w.write(2, 3)       // channel_configuration
+	w.write(0, 2)       // original_copy, home
+	w.write(0, 2)       // copyright_identification_bit, copyright_identification_start
+	w.write(15, 13)     // aac_frame_length
+	w.write(0x7ff, 11)  // adts_buffer_fullness
+	w.write(1, 2)       // number_of_raw_data_blocks_in_frame
+	w.write(3, 16)      // raw_data_block_position[1]
+	w.write(0xbeef, 16) // crc_check
+	w.write(ID_END, 3)  // raw_data_block 0
+	w.byteAlign()
+	w.write(0x1234, 16) // crc_check
+	w.write(ID_END, 3)  // raw_data_block 1
+	w.byteAlign()
+	w.write(0x5678, 16) // crc_check
+
+	adts, err := ParseADTS(w.buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err.Error())
+	}
+	if adts.Header_error_check == nil || adts.Header_error_check.Crc_check != 0xbeef {
+		t.Errorf("Header_error_check.Crc_check must be 0xbeef")
+	}
+	if len(adts.Raw_data_blocks) != 2 {
+		t.Fatalf("Raw_data_blocks length (%d) must be 2", len(adts.Raw_data_blocks))
+	}
+	if adts.Raw_data_blocks[0].Position != 0 || adts.Raw_data_blocks[1].Position != 3 {
+		t.Errorf("Positions (%d, %d) must be (0, 3)",
+			adts.Raw_data_blocks[0].Position, adts.Raw_data_blocks[1].Position)
+	}
+	if adts.Raw_data_blocks[0].Error_check.Crc_check != 0x1234 ||
+		adts.Raw_data_blocks[1].Error_check.Crc_check != 0x5678 {
+		t.Errorf("raw_data_block CRCs (%#x, %#x) must be (0x1234, 0x5678)",
+			adts.Raw_data_blocks[0].Error_check.Crc_check, adts.Raw_data_blocks[1].Error_check.Crc_check)
+	}
+	if adts.NumRawDataBlocks() != 2 {
+		t.Errorf("NumRawDataBlocks (%d) must be 2", adts.NumRawDataBlocks())
+	}
+	if adts.Samples() != 2048 {
+		t.Errorf("Samples (%d) must be 2048", adts.Samples())
+	}
+	if want := 2048 * time.Second / 48000; adts.Duration() != want {
+		t.Errorf("Duration (%s) must be %s", adts.Duration(), want)
+	}
+}
+
+// Overwrites n bits at bit offset pos
+func (w *bitWriter) patch(pos uint, val uint64, n uint) {
+	for i := uint(0); i < n; i++ {
+		bit := pos + i
+		mask := byte(0x80 >> (bit % 8))
+		if (val>>(n-1-i))&1 == 1 {
+			w.buf[bit/8] |= mask
+		} else {
+			w.buf[bit/8] &^= mask
+		}
+	}
+}
+
+// Wraps a single raw_data_block written by payload in an unprotected ADTS
+// header.  profile is the 2 bit ADTS profile, the object type minus one.
+func adtsFrame(profile uint64, sfi uint64, channel_configuration uint64, payload func(w *bitWriter)) []byte {
+	w := &bitWriter{}
+	w.write(0xfff, 12) // syncword
+	w.write(1, 1)      // ID
+	w.write(0, 2)      // layer
+	w.write(1, 1)      // protection_absent
+	w.write(profile, 2)
+	w.write(sfi, 4)
+	w.write(0, 1) // private_bit
+	w.write(channel_configuration, 3)
+	w.write(0, 4)      // original_copy, home, copyright bits
+	w.write(0, 13)     // aac_frame_length, patched below
+	w.write(0x7ff, 11) // adts_buffer_fullness
+	w.write(0, 2)      // number_of_raw_data_blocks_in_frame
+	payload(w)
+	w.write(ID_END, 3)
+	w.byteAlign()
+	w.patch(30, uint64(len(w.buf)), 13)
+	return w.buf
+}
+
+// ics_info() of a long window with 2 bands and LTP.  lag < 0 leaves
+// ltp_data out.
+func writeLtpIcsInfo(w *bitWriter, common_window bool, lags [2]int, coefs [2]uint64, used [2][2]uint64) {
+	w.write(0, 1) // ics_reserved_bit
+	w.write(ONLY_LONG_SEQUENCE, 2)
+	w.write(0, 1) // window_shape
+	w.write(2, 6) // max_sfb
+	w.write(1, 1) // predictor_data_present
+	channels := 1
+	if common_window {
+		channels = 2
+	}
+	for ch := 0; ch < channels; ch++ {
+		if lags[ch] < 0 {
+			w.write(0, 1) // ltp_data_present
+			continue
+		}
+		w.write(1, 1) // ltp_data_present
+		w.write(uint64(lags[ch]), 11)
+		w.write(coefs[ch], 3)
+		w.write(used[ch][0], 1)
+		w.write(used[ch][1], 1)
+	}
+}
+
+// individual_channel_stream() of 2 ZERO_HCB bands and no tools
+func writeSilentIcs(w *bitWriter) {
+	w.write(100, 8) // global_gain
+	w.write(ZERO_HCB, 4)
+	w.write(2, 5) // sect_len
+	w.write(0, 1) // pulse_data_present
+	w.write(0, 1) // tns_data_present
+	w.write(0, 1) // gain_control_data_present
+}
+
+func checkLtpData(t *testing.T, name string, data *ltp_data, lag uint, coef uint8, used []bool) {
+	if data == nil {
+		t.Errorf("%s must not be nil", name)
+		return
+	}
+	if data.Ltp_lag != lag || data.Ltp_coef != coef {
+		t.Errorf("%s lag/coef (%d, %d) must be (%d, %d)", name, data.Ltp_lag, data.Ltp_coef, lag, coef)
+	}
+	if !reflect.DeepEqual(data.Ltp_long_used, used) {
+		t.Errorf("%s Ltp_long_used %v must be %v", name, data.Ltp_long_used, used)
+	}
+}
+
+// A common window CPE carries one ltp_data() per channel, the second must
+// not overwrite the first
+func TestLtpCommonWindowChannelPair(t *testing.T) {
+	buf := adtsFrame(3, 4, 2, func(w *bitWriter) {
+		w.write(ID_CPE, 3)
+		w.write(0, 4) // element_instance_tag
+		w.write(1, 1) // common_window
+		writeLtpIcsInfo(w, true, [2]int{100, 700}, [2]uint64{5, 2}, [2][2]uint64{{1, 0}, {0, 1}})
+		w.write(0, 2) // ms_mask_present
+		writeSilentIcs(w)
+		writeSilentIcs(w)
+	})
+
+	adts, err := ParseADTS(buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	if adts.Profile != AUDIO_OBJECT_TYPE_LTP {
+		t.Fatalf("Profile (%d) must be AAC LTP", adts.Profile)
+	}
+	if len(adts.Channel_pair_elements) != 1 {
+		t.Fatalf("Channel_pair_elements length (%d) must be 1", len(adts.Channel_pair_elements))
+	}
+	info := adts.Channel_pair_elements[0].Ics_info
+	if !info.Ltp_data_present || !info.Ltp_data2_present {
+		t.Fatalf("Ltp_data_present (%t) and Ltp_data2_present (%t) must be true",
+			info.Ltp_data_present, info.Ltp_data2_present)
+	}
+	checkLtpData(t, "Ltp_data", info.Ltp_data, 100, 5, []bool{true, false})
+	checkLtpData(t, "Ltp_data2", info.Ltp_data2, 700, 2, []bool{false, true})
+}
+
+func TestLtpCommonWindowSecondChannelOnly(t *testing.T) {
+	buf := adtsFrame(3, 4, 2, func(w *bitWriter) {
+		w.write(ID_CPE, 3)
+		w.write(0, 4) // element_instance_tag
+		w.write(1, 1) // common_window
+		writeLtpIcsInfo(w, true, [2]int{-1, 2047}, [2]uint64{0, 7}, [2][2]uint64{{0, 0}, {1, 1}})
+		w.write(0, 2) // ms_mask_present
+		writeSilentIcs(w)
+		writeSilentIcs(w)
+	})
+
+	adts, err := ParseADTS(buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	info := adts.Channel_pair_elements[0].Ics_info
+	if info.Ltp_data_present || info.Ltp_data != nil {
+		t.Errorf("first channel must not have LTP data")
+	}
+	checkLtpData(t, "Ltp_data2", info.Ltp_data2, 2047, 7, []bool{true, true})
+}
+
+func TestLtpSeparateWindowChannelPair(t *testing.T) {
+	buf := adtsFrame(3, 4, 2, func(w *bitWriter) {
+		w.write(ID_CPE, 3)
+		w.write(0, 4) // element_instance_tag
+		w.write(0, 1) // common_window
+		w.write(100, 8)
+		writeLtpIcsInfo(w, false, [2]int{300, -1}, [2]uint64{1, 0}, [2][2]uint64{{1, 1}, {0, 0}})
+		w.write(ZERO_HCB, 4)
+		w.write(2, 5)
+		w.write(0, 3) // pulse, tns, gain control
+		w.write(100, 8)
+		writeLtpIcsInfo(w, false, [2]int{-1, -1}, [2]uint64{}, [2][2]uint64{})
+		w.write(ZERO_HCB, 4)
+		w.write(2, 5)
+		w.write(0, 3) // pulse, tns, gain control
+	})
+
+	adts, err := ParseADTS(buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	cpe := adts.Channel_pair_elements[0]
+	checkLtpData(t, "Channel_stream1 Ltp_data", cpe.Channel_stream1.Ics_info.Ltp_data, 300, 1, []bool{true, true})
+	if cpe.Channel_stream2.Ics_info.Ltp_data != nil || cpe.Channel_stream1.Ics_info.Ltp_data2 != nil {
+		t.Errorf("only the first channel may have LTP data")
+	}
+}
+
+func TestLtpSingleChannel(t *testing.T) {
+	buf := adtsFrame(3, 4, 1, func(w *bitWriter) {
+		w.write(ID_SCE, 3)
+		w.write(0, 4) // element_instance_tag
+		w.write(100, 8)
+		writeLtpIcsInfo(w, false, [2]int{1024, -1}, [2]uint64{3, 0}, [2][2]uint64{{0, 1}, {0, 0}})
+		w.write(ZERO_HCB, 4)
+		w.write(2, 5)
+		w.write(0, 3) // pulse, tns, gain control
+	})
+
+	adts, err := ParseADTS(buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	checkLtpData(t, "Ltp_data", adts.Single_channel_elements[0].Channel_stream.Ics_info.Ltp_data,
+		1024, 3, []bool{false, true})
+}
+
+// An LD ltp_data() without ltp_lag_update leaves the lag to the channel's
+// previous frame instead of making one up
+func TestLtpLDLagUpdate(t *testing.T) {
+	w := &bitWriter{}
+	w.write(0, 1) // ltp_lag_update
+	w.write(4, 3) // ltp_coef
+	w.write(1, 2) // ltp_long_used
+	w.write(1, 1) // ltp_lag_update
+	w.write(321, 10)
+	w.write(4, 3) // ltp_coef
+	w.write(1, 2) // ltp_long_used
+
+	adts := &ADTS{Profile: AUDIO_OBJECT_TYPE_ER_AAC_LD, Frame_length: 512}
+	adts.reader = bitreader.NewBitReader(w.buf)
+	info := &ics_info{Max_sfb: 2}
+
+	data, err := adts.ltp_data(info)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	if data.Ltp_lag_update || !data.Ltp_lag_prev || data.Ltp_lag != 0 {
+		t.Errorf("lag (%d) without an update must be left to the previous frame", data.Ltp_lag)
+	}
+
+	data, _ = adts.ltp_data(info)
+	if !data.Ltp_lag_update || data.Ltp_lag_prev || data.Ltp_lag != 321 {
+		t.Errorf("updated lag (%d) must be 321", data.Ltp_lag)
+	}
+}
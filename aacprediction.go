@@ -0,0 +1,154 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import "math"
+
+// One predictor per spectral line up to the highest PRED_SFB_MAX band
+const MAX_PREDICTORS = 672
+
+// Predictor reset groups interleave every 30 lines
+const PREDICTOR_RESET_GROUPS = 30
+
+// Second order backward-adaptive lattice LMS predictor of one line
+type predictor_state struct {
+	r0, r1     float32
+	cor0, cor1 float32
+	var0, var1 float32
+}
+
+// AAC Main prediction state of a channel.  It is kept across frames, so
+// each decoded channel needs its own MainPredictor.
+type MainPredictor struct {
+	state       [MAX_PREDICTORS]predictor_state
+	initialized bool
+}
+
+func NewMainPredictor() *MainPredictor {
+	p := &MainPredictor{}
+	p.Reset()
+	return p
+}
+
+// Resets every predictor of the channel
+func (p *MainPredictor) Reset() {
+	for i := range p.state {
+		p.state[i].reset()
+	}
+	p.initialized = true
+}
+
+// Resets the predictors of reset group 1..30
+func (p *MainPredictor) ResetGroup(group_num uint8) {
+	if group_num == 0 || group_num > PREDICTOR_RESET_GROUPS {
+		return
+	}
+	for i := int(group_num) - 1; i < MAX_PREDICTORS; i += PREDICTOR_RESET_GROUPS {
+		p.state[i].reset()
+	}
+}
+
+func (ps *predictor_state) reset() {
+	ps.r0, ps.r1 = 0, 0
+	ps.cor0, ps.cor1 = 0, 0
+	ps.var0, ps.var1 = 1, 1
+}
+
+// Runs the predictors of a channel over its dequantized spectrum.  The
+// prediction is added to the lines of bands flagged in prediction_used[]
+// while every predictor up to PRED_SFB_MAX is updated either way.  Short
+// windows reset all predictors.
+func (adts *ADTS) ApplyPrediction(p *MainPredictor, info *ics_info, spec []float32) {
+	if !p.initialized {
+		p.Reset()
+	}
+
+	if info.Window_sequence == EIGHT_SHORT_SEQUENCE {
+		p.Reset()
+		return
+	}
+
+	pred_sfb_max := int(Aac_PRED_SFB_MAX[adts.sfi])
+	if pred_sfb_max > len(info.swb_offset)-1 {
+		pred_sfb_max = len(info.swb_offset) - 1
+	}
+	for sfb := 0; sfb < pred_sfb_max; sfb++ {
+		output_enable := info.Predictor_data_present && sfb < len(info.Prediction_used) &&
+			info.Prediction_used[sfb]
+		for k := int(info.swb_offset[sfb]); k < int(info.swb_offset[sfb+1]) && k < MAX_PREDICTORS && k < len(spec); k++ {
+			p.state[k].predict(&spec[k], output_enable)
+		}
+	}
+
+	if info.Predictor_data_present && info.Predictor_reset {
+		p.ResetGroup(info.Predictor_reset_group_num)
+	}
+}
+
+// The predictor's state and intermediate results are kept at 16 bit
+// mantissa precision so every decoder predicts bit exactly.  Products are
+// converted explicitly so they are rounded to float32 and never fused into
+// a multiply-add, which would change the result.
+func (ps *predictor_state) predict(coef *float32, output_enable bool) {
+	const a = float32(0.953125)    // 61/64
+	const alpha = float32(0.90625) // 29/32
+
+	r0, r1 := ps.r0, ps.r1
+	cor0, cor1 := ps.cor0, ps.cor1
+	var0, var1 := ps.var0, ps.var1
+
+	var k1, k2 float32
+	if var0 > 1 {
+		k1 = cor0 * flt16_even(a/var0)
+	}
+	if var1 > 1 {
+		k2 = cor1 * flt16_even(a/var1)
+	}
+
+	pv := flt16_round(float32(k1*r0) + float32(k2*r1))
+	if output_enable {
+		*coef += pv
+	}
+
+	e0 := *coef
+	e1 := e0 - float32(k1*r0)
+
+	ps.cor1 = flt16_trunc(float32(alpha*cor1) + float32(r1*e1))
+	ps.var1 = flt16_trunc(float32(alpha*var1) + float32(0.5*(float32(r1*r1)+float32(e1*e1))))
+	ps.cor0 = flt16_trunc(float32(alpha*cor0) + float32(r0*e0))
+	ps.var0 = flt16_trunc(float32(alpha*var0) + float32(0.5*(float32(r0*r0)+float32(e0*e0))))
+
+	ps.r1 = flt16_trunc(a * (r0 - float32(k1*e0)))
+	ps.r0 = flt16_trunc(a * e0)
+}
+
+// Round to nearest on a 16 bit mantissa
+func flt16_round(f float32) float32 {
+	i := math.Float32bits(f)
+	return math.Float32frombits((i + 0x00008000) & 0xffff0000)
+}
+
+// Round to nearest, ties to even, on a 16 bit mantissa
+func flt16_even(f float32) float32 {
+	i := math.Float32bits(f)
+	return math.Float32frombits((i + 0x00007fff + ((i >> 16) & 1)) & 0xffff0000)
+}
+
+// Truncate to a 16 bit mantissa
+func flt16_trunc(f float32) float32 {
+	return math.Float32frombits(math.Float32bits(f) & 0xffff0000)
+}
@@ -0,0 +1,113 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFlt16Rounding(t *testing.T) {
+	f := math.Float32frombits(0x3f808000) // exactly half way
+	if got := math.Float32bits(flt16_round(f)); got != 0x3f810000 {
+		t.Errorf("flt16_round (%#x) must be 0x3f810000", got)
+	}
+	if got := math.Float32bits(flt16_even(f)); got != 0x3f800000 {
+		t.Errorf("flt16_even (%#x) must round the tie to 0x3f800000", got)
+	}
+	f = math.Float32frombits(0x3f818000)
+	if got := math.Float32bits(flt16_even(f)); got != 0x3f820000 {
+		t.Errorf("flt16_even (%#x) must round the tie to 0x3f820000", got)
+	}
+	f = math.Float32frombits(0x3f80ffff)
+	if got := math.Float32bits(flt16_trunc(f)); got != 0x3f800000 {
+		t.Errorf("flt16_trunc (%#x) must be 0x3f800000", got)
+	}
+}
+
+func predictionTestFrame() (*ADTS, *ics_info) {
+	adts := &ADTS{sfi: 4} // 44100
+	info := &ics_info{Window_sequence: ONLY_LONG_SEQUENCE}
+	window_grouping(&adts.mem, info, adts.sfi, 1024)
+	return adts, info
+}
+
+func TestMainPrediction(t *testing.T) {
+	adts, info := predictionTestFrame()
+	p := NewMainPredictor()
+
+	// A sinusoid on line 0 the predictor learns with its output disabled
+	signal := func(n int) float32 { return float32(1000 * math.Cos(0.3*float64(n))) }
+	spec := make([]float32, 1024)
+	for n := 0; n < 200; n++ {
+		spec[0] = signal(n)
+		adts.ApplyPrediction(p, info, spec)
+		if spec[0] != signal(n) {
+			t.Fatalf("frame %d: disabled prediction must not change the spectrum", n)
+		}
+	}
+
+	// With prediction used only the residual is transmitted
+	info.Predictor_data_present = true
+	info.Prediction_used = make([]bool, Aac_PRED_SFB_MAX[adts.sfi])
+	info.Prediction_used[0] = true
+	spec[0] = 0
+	adts.ApplyPrediction(p, info, spec)
+	if want := signal(200); math.Abs(float64(spec[0]-want)) > 100 {
+		t.Errorf("prediction (%f) must be close to %f", spec[0], want)
+	}
+	if spec[4] != 0 {
+		t.Errorf("line 4 (%f) must be 0, its band doesn't use prediction", spec[4])
+	}
+}
+
+func TestMainPredictionReset(t *testing.T) {
+	adts, info := predictionTestFrame()
+	p := NewMainPredictor()
+
+	spec := make([]float32, 1024)
+	for n := 0; n < 10; n++ {
+		for k := range spec {
+			spec[k] = 100
+		}
+		adts.ApplyPrediction(p, info, spec)
+	}
+	if p.state[0].var0 == 1 || p.state[30].var0 == 1 || p.state[1].var0 == 1 {
+		t.Fatalf("predictors must have adapted")
+	}
+
+	// Reset group 1 resets lines 0, 30, 60...
+	info.Predictor_data_present = true
+	info.Predictor_reset = true
+	info.Predictor_reset_group_num = 1
+	adts.ApplyPrediction(p, info, spec)
+	for _, k := range []int{0, 30, 60, 630} {
+		if p.state[k] != (predictor_state{var0: 1, var1: 1}) {
+			t.Errorf("predictor %d must be reset", k)
+		}
+	}
+	if p.state[1].var0 == 1 {
+		t.Errorf("predictor 1 must not be reset")
+	}
+
+	short := &ics_info{Window_sequence: EIGHT_SHORT_SEQUENCE}
+	window_grouping(&adts.mem, short, adts.sfi, 1024)
+	adts.ApplyPrediction(p, short, spec)
+	if p.state[1] != (predictor_state{var0: 1, var1: 1}) {
+		t.Errorf("short windows must reset every predictor")
+	}
+}
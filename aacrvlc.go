@@ -0,0 +1,422 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import "fmt"
+
+// rvlc_cod_sf values of +-RVLC_ESC_VAL are extended by an rvlc_esc_sf
+const RVLC_ESC_VAL = 7
+
+// Offset of the DPCM values kept in scale_factor_data, the same index
+// hcod_sf returns
+const RVLC_DPCM_OFFSET = 60
+
+// Offset of the 9 bit noise energies
+const NOISE_OFFSET = 256
+
+type rvlc_codeword struct {
+	value  int8
+	length uint8
+	code   uint32
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// RVLC codebook of scale factor differences.  Every codeword is a palindrome
+// so the segment decodes the same read backwards, the unused codes are
+// invalid and flag bitstream errors.
+////////////////////////////////////////////////////////////////////////////////
+var rvlc_sf_codebook = []rvlc_codeword{
+	{0, 1, 0x000},
+	{-1, 3, 0x005}, {1, 3, 0x007},
+	{-2, 4, 0x009},
+	{-3, 5, 0x011}, {2, 5, 0x01b},
+	{-4, 6, 0x021}, {3, 6, 0x033},
+	{-7, 7, 0x041}, {7, 7, 0x063}, {4, 7, 0x06b},
+	{-5, 8, 0x081}, {5, 8, 0x0c3},
+	{-6, 9, 0x101}, {6, 9, 0x183},
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// RVLC escape codebook, 0..53 added to an escaped +-7
+////////////////////////////////////////////////////////////////////////////////
+var rvlc_esc_codebook = []rvlc_codeword{
+	{1, 2, 0x00000}, {0, 2, 0x00002},
+	{3, 3, 0x00002}, {2, 3, 0x00006},
+	{4, 4, 0x0000e},
+	{7, 5, 0x0000d}, {6, 5, 0x0000f}, {5, 5, 0x0001f},
+	{11, 6, 0x00018}, {10, 6, 0x00019}, {9, 6, 0x0001d}, {8, 6, 0x0003d},
+	{13, 7, 0x00038}, {12, 7, 0x00078},
+	{15, 8, 0x00072}, {14, 8, 0x000f2},
+	{17, 9, 0x000e6}, {16, 9, 0x001e6},
+	{19, 10, 0x001cf}, {18, 10, 0x003ce},
+	{22, 11, 0x0039d}, {20, 11, 0x0079e}, {21, 11, 0x0079f},
+	{23, 12, 0x00738},
+	{25, 13, 0x00e72},
+	{24, 14, 0x01ce7},
+	{26, 15, 0x039cd},
+	{49, 19, 0x39cc0}, {50, 19, 0x39cc1}, {51, 19, 0x39cc2}, {52, 19, 0x39cc3}, {53, 19, 0x39cc4},
+	{27, 20, 0x7398a}, {28, 20, 0x7398b}, {29, 20, 0x7398c}, {30, 20, 0x7398d},
+	{31, 20, 0x7398e}, {32, 20, 0x7398f}, {33, 20, 0x73990}, {34, 20, 0x73991},
+	{35, 20, 0x73992}, {36, 20, 0x73993}, {37, 20, 0x73994}, {38, 20, 0x73995},
+	{39, 20, 0x73996}, {40, 20, 0x73997}, {41, 20, 0x73998}, {42, 20, 0x73999},
+	{43, 20, 0x7399a}, {44, 20, 0x7399b}, {45, 20, 0x7399c}, {46, 20, 0x7399d},
+	{47, 20, 0x7399e}, {48, 20, 0x7399f},
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Table 4.53 – Syntax of scale_factor_data(), aacScalefactorDataResilienceFlag
+////////////////////////////////////////////////////////////////////////////////
+// Reads the side information of RVLC coded scale factors the way decoders
+// lay it out: the first noise energy and the last noise position are sent
+// here, the codeword segments follow the rest of the side information and
+// are read by rvlc_segments.
+func (adts *ADTS) rvlc_scale_factor_data(info *ics_info, data *scale_factor_data) (*scale_factor_data, error) {
+	data.Dcpm_is_position = adts.mem.uint8s_2d.make(int(info.num_window_groups))
+	data.Dcpm_noise_nrg = adts.mem.uint16s_2d.make(int(info.num_window_groups))
+	data.Dcpm_sf = adts.mem.uint8s_2d.make(int(info.num_window_groups))
+	for g := range data.Dcpm_sf {
+		data.Dcpm_is_position[g] = adts.mem.uint8s.make(int(info.Max_sfb))
+		data.Dcpm_noise_nrg[g] = adts.mem.uint16s.make(int(info.Max_sfb))
+		data.Dcpm_sf[g] = adts.mem.uint8s.make(int(info.Max_sfb))
+	}
+
+	data.Sf_concealment, _ = adts.reader.ReadBitAsBool()
+	data.Rev_global_gain, _ = adts.reader.ReadBitsAsUInt8(8)
+	if info.Window_sequence == EIGHT_SHORT_SEQUENCE {
+		data.Len_of_rvlc_sf, _ = adts.reader.ReadBitsAsUInt16(11)
+	} else {
+		data.Len_of_rvlc_sf, _ = adts.reader.ReadBitsAsUInt16(9)
+	}
+
+	if g, sfb, ok := first_noise_band(info); ok {
+		// Counted in length_of_rvlc_sf
+		if data.Len_of_rvlc_sf < 9 {
+			return data, adts.check("scale_factor_data", fmt.Errorf("Error: length_of_rvlc_sf (%d) too short for the noise energy", data.Len_of_rvlc_sf))
+		}
+		data.Dcpm_noise_nrg[g][sfb], _ = adts.reader.ReadBitsAsUInt16(9)
+	}
+
+	var err error
+	data.Sf_escapes_present, err = adts.reader.ReadBitAsBool()
+	if data.Sf_escapes_present {
+		data.Len_of_rvlc_escapes, err = adts.reader.ReadBitsAsUInt8(8)
+	}
+	if _, _, ok := first_noise_band(info); ok {
+		data.Dcpm_noise_last_pos, err = adts.reader.ReadBitsAsUInt16(9)
+	}
+	return data, adts.check("scale_factor_data", err)
+}
+
+// Reads the RVLC codeword segments and decodes them into the DPCM values of
+// data.  Decoding runs forward from global_gain and, if that fails, backward
+// from the last values, which is what the reversible codes are for.
+func (adts *ADTS) rvlc_segments(info *ics_info, global_gain uint8, data *scale_factor_data) error {
+	length := uint(data.Len_of_rvlc_sf)
+	if _, _, ok := first_noise_band(info); ok {
+		length -= 9
+	}
+
+	var err error
+	if data.Rvlc_cod_sf, err = adts.read_bits_to_byte_array(length); err != nil {
+		return adts.check("scale_factor_data", err)
+	}
+	if data.Sf_escapes_present {
+		if data.Rvlc_esc_sf, err = adts.read_bits_to_byte_array(uint(data.Len_of_rvlc_escapes)); err != nil {
+			return adts.check("scale_factor_data", err)
+		}
+	}
+
+	return adts.check("scale_factor_data", rvlc_decode(&adts.mem, info, global_gain, data, length))
+}
+
+// First band coded with NOISE_HCB, whose energy is sent as 9 bit PCM
+func first_noise_band(info *ics_info) (uint8, uint8, bool) {
+	for g := uint8(0); g < info.num_window_groups; g++ {
+		for sfb := uint8(0); sfb < info.Max_sfb; sfb++ {
+			if is_noise(info, g, sfb) {
+				return g, sfb, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// Reads a segment of n bits, as returned by ReadBitsToByteArray, from
+// either end
+type rvlc_segment struct {
+	buf      []byte
+	n        uint
+	pos      uint
+	backward bool
+}
+
+func (s *rvlc_segment) bit() (uint32, error) {
+	if s.pos >= s.n {
+		return 0, fmt.Errorf("Error: RVLC segment overrun")
+	}
+	i := s.pos
+	if s.backward {
+		i = s.n - 1 - s.pos
+	}
+	s.pos++
+
+	// The last bit of the segment is the LSB of the last byte
+	from_end := s.n - 1 - i
+	return uint32(s.buf[len(s.buf)-1-int(from_end/8)]>>(from_end%8)) & 1, nil
+}
+
+func (s *rvlc_segment) decode(book []rvlc_codeword) (int8, error) {
+	code := uint32(0)
+	length := uint8(0)
+	for _, cw := range book {
+		for length < cw.length {
+			b, err := s.bit()
+			if err != nil {
+				return 0, err
+			}
+			code = code<<1 | b
+			length++
+		}
+		if code == cw.code {
+			return cw.value, nil
+		}
+	}
+	return 0, fmt.Errorf("Error: invalid RVLC codeword (%#x)", code)
+}
+
+// The three DPCM chains of the scale factor segment
+const (
+	rvlc_sf = iota
+	rvlc_is
+	rvlc_noise
+)
+
+type rvlc_band struct {
+	g, sfb uint8
+	chain  int
+}
+
+// Bands with a codeword in forward order, the last intensity position
+// excluded
+func rvlc_bands(mem *frame_memory, info *ics_info) []rvlc_band {
+	var bands []rvlc_band
+	noise_g, noise_sfb, noise_used := first_noise_band(info)
+	for g := uint8(0); g < info.num_window_groups; g++ {
+		for sfb := uint8(0); sfb < info.Max_sfb; sfb++ {
+			switch {
+			case info.sfb_cb[g][sfb] == ZERO_HCB:
+			case is_intensity(info, g, sfb) != 0:
+				bands = mem.rvlc_bands.append(bands, rvlc_band{g, sfb, rvlc_is})
+			case is_noise(info, g, sfb):
+				if !noise_used || g != noise_g || sfb != noise_sfb {
+					bands = mem.rvlc_bands.append(bands, rvlc_band{g, sfb, rvlc_noise})
+				}
+			default:
+				bands = mem.rvlc_bands.append(bands, rvlc_band{g, sfb, rvlc_sf})
+			}
+		}
+	}
+	return bands
+}
+
+// One direction's decode: the differences of the first count bands it
+// reached, in forward band order, and each chain's value where it stopped
+type rvlc_pass struct {
+	diffs    []int
+	count    int
+	values   [3]int
+	is_last  int
+	complete bool
+}
+
+// Decodes the scale factor segment into the DPCM values of data.  Forward
+// decoding starts at global_gain, an intensity position of 0 and the PCM
+// coded first noise energy and has to end at rev_global_gain, the last
+// intensity position and dpcm_noise_last_position, where backward decoding
+// starts.  If the forward pass hits an error the bands before it are taken
+// from the forward pass and the bands after it from the backward pass.  The
+// chains are bridged at the damaged bands, which recovers a single lost band
+// of a chain exactly and holds the value over longer gaps.  The buffers of
+// the decode are taken from mem.
+func rvlc_decode(mem *frame_memory, info *ics_info, global_gain uint8, data *scale_factor_data, length uint) error {
+	var escapes []int8
+	if data.Sf_escapes_present {
+		esc := &rvlc_segment{buf: data.Rvlc_esc_sf, n: uint(data.Len_of_rvlc_escapes)}
+		for esc.pos < esc.n {
+			v, err := esc.decode(rvlc_esc_codebook)
+			if err != nil {
+				break
+			}
+			escapes = mem.int8s.append(escapes, v)
+		}
+	}
+
+	bands := rvlc_bands(mem, info)
+	_, _, intensity_used := rvlc_first_intensity(info)
+
+	var start, end [3]int
+	start[rvlc_sf] = int(global_gain)
+	end[rvlc_sf] = int(data.Rev_global_gain)
+	if g, sfb, ok := first_noise_band(info); ok {
+		start[rvlc_noise] = int(global_gain) - 90 - NOISE_OFFSET + int(data.Dcpm_noise_nrg[g][sfb])
+		end[rvlc_noise] = int(data.Rev_global_gain) - 90 - NOISE_OFFSET + int(data.Dcpm_noise_last_pos)
+	}
+
+	// Backward decoding reads the last intensity position first and keeps
+	// the forward value when it can't
+	forward := rvlc_run(mem, data, length, escapes, bands, intensity_used, start, false)
+	end[rvlc_is] = forward.values[rvlc_is]
+	if forward.complete && forward.values == end && forward.is_last == forward.values[rvlc_is] {
+		rvlc_store(data, bands, forward.diffs, forward.is_last)
+		return nil
+	}
+
+	backward := rvlc_run(mem, data, length, escapes, bands, intensity_used, end, true)
+	if backward.complete && backward.values == start {
+		rvlc_store(data, bands, backward.diffs, backward.is_last)
+		return nil
+	}
+	data.Rvlc_concealed = true
+
+	// Forward values up to the error, backward values after it
+	diffs := mem.ints.make(len(bands))
+	from := len(bands) - backward.count
+	if from < forward.count {
+		from = forward.count
+	}
+	copy(diffs, forward.diffs[:forward.count])
+	copy(diffs[from:], backward.diffs[from:])
+
+	// The first damaged band of each chain bridges the forward value to
+	// the backward one
+	bridged := [3]bool{}
+	for i := forward.count; i < from; i++ {
+		c := bands[i].chain
+		if !bridged[c] {
+			bridged[c] = true
+			diffs[i] = backward.values[c] - forward.values[c]
+			if diffs[i] > RVLC_DPCM_OFFSET {
+				diffs[i] = RVLC_DPCM_OFFSET
+			} else if diffs[i] < -RVLC_DPCM_OFFSET {
+				diffs[i] = -RVLC_DPCM_OFFSET
+			}
+		}
+	}
+
+	rvlc_store(data, bands, diffs, backward.is_last)
+	return nil
+}
+
+// Decodes codewords in one direction until the segment ends or an error.
+// start is each chain's value before the first band decoded.
+func rvlc_run(mem *frame_memory, data *scale_factor_data, length uint, escapes []int8, bands []rvlc_band, intensity_used bool, start [3]int, backward bool) rvlc_pass {
+	pass := rvlc_pass{diffs: mem.ints.make(len(bands)), values: start, is_last: start[rvlc_is]}
+	seg := &rvlc_segment{buf: data.Rvlc_cod_sf, n: length, backward: backward}
+
+	next_escape := 0
+	if backward {
+		next_escape = len(escapes) - 1
+	}
+	read := func() (int, error) {
+		v, err := seg.decode(rvlc_sf_codebook)
+		if err != nil {
+			return 0, err
+		}
+		if v == RVLC_ESC_VAL || v == -RVLC_ESC_VAL {
+			if next_escape < 0 || next_escape >= len(escapes) {
+				return 0, fmt.Errorf("Error: RVLC escape missing")
+			}
+			if v > 0 {
+				v += escapes[next_escape]
+			} else {
+				v -= escapes[next_escape]
+			}
+			if backward {
+				next_escape--
+			} else {
+				next_escape++
+			}
+		}
+		return int(v), nil
+	}
+
+	if backward && intensity_used {
+		d, err := read()
+		if err != nil {
+			return pass
+		}
+		pass.is_last = d
+		pass.values[rvlc_is] = d
+	}
+
+	for i := range bands {
+		b := i
+		if backward {
+			b = len(bands) - 1 - i
+		}
+		d, err := read()
+		if err != nil {
+			return pass
+		}
+		pass.diffs[b] = d
+		pass.count++
+
+		if backward {
+			pass.values[bands[b].chain] -= d
+		} else {
+			pass.values[bands[b].chain] += d
+		}
+		if bands[b].chain == rvlc_sf && (pass.values[rvlc_sf] < 0 || pass.values[rvlc_sf] > 255) {
+			return pass
+		}
+	}
+
+	if !backward && intensity_used {
+		d, err := read()
+		if err != nil {
+			return pass
+		}
+		pass.is_last = d
+	}
+	pass.complete = seg.pos == seg.n
+	return pass
+}
+
+func rvlc_store(data *scale_factor_data, bands []rvlc_band, diffs []int, is_last int) {
+	for i, b := range bands {
+		switch b.chain {
+		case rvlc_is:
+			data.Dcpm_is_position[b.g][b.sfb] = uint8(diffs[i] + RVLC_DPCM_OFFSET)
+		case rvlc_noise:
+			data.Dcpm_noise_nrg[b.g][b.sfb] = uint16(diffs[i] + RVLC_DPCM_OFFSET)
+		default:
+			data.Dcpm_sf[b.g][b.sfb] = uint8(diffs[i] + RVLC_DPCM_OFFSET)
+		}
+	}
+	data.Dcpm_is_last_position = uint8(is_last + RVLC_DPCM_OFFSET)
+}
+
+func rvlc_first_intensity(info *ics_info) (uint8, uint8, bool) {
+	for g := uint8(0); g < info.num_window_groups; g++ {
+		for sfb := uint8(0); sfb < info.Max_sfb; sfb++ {
+			if is_intensity(info, g, sfb) != 0 {
+				return g, sfb, true
+			}
+		}
+	}
+	return 0, 0, false
+}
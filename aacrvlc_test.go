@@ -0,0 +1,207 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"testing"
+)
+
+// Writes the RVLC codeword of each difference, moving the part beyond
+// +-RVLC_ESC_VAL into the escape segment
+func writeRvlc(w *bitWriter, esc *bitWriter, diffs []int) {
+	for _, d := range diffs {
+		v := d
+		if d >= RVLC_ESC_VAL || d <= -RVLC_ESC_VAL {
+			e := d - RVLC_ESC_VAL
+			v = RVLC_ESC_VAL
+			if d < 0 {
+				e = -d - RVLC_ESC_VAL
+				v = -RVLC_ESC_VAL
+			}
+			for _, cw := range rvlc_esc_codebook {
+				if int(cw.value) == e {
+					esc.write(uint64(cw.code), uint(cw.length))
+				}
+			}
+		}
+		for _, cw := range rvlc_sf_codebook {
+			if int(cw.value) == v {
+				w.write(uint64(cw.code), uint(cw.length))
+			}
+		}
+	}
+}
+
+// Bits of a bitWriter the way ReadBitsToByteArray returns them
+func rvlcBytes(w *bitWriter) []byte {
+	out := &bitWriter{}
+	if pad := (8 - w.bits%8) % 8; pad != 0 {
+		out.write(0, pad)
+	}
+	for i := uint(0); i < w.bits; i++ {
+		out.write(uint64(w.buf[i/8]>>(7-i%8))&1, 1)
+	}
+	return out.buf
+}
+
+func TestRvlcDecode(t *testing.T) {
+	sf := uint8(1)
+	is := uint8(INTENSITY_HCB)
+	info := &ics_info{
+		num_window_groups: 1,
+		Max_sfb:           7,
+		sfb_cb:            [][]uint8{{sf, sf, is, sf, ZERO_HCB, is, sf}},
+	}
+	diffs := []int{3, -15, 2, 6, -4, 25}
+	is_last := 2 - 4
+
+	for _, test := range []struct {
+		name      string
+		corrupt   bool
+		concealed bool
+	}{
+		{"clean", false, false},
+		{"corrupted", true, true},
+	} {
+		seg := &bitWriter{}
+		esc := &bitWriter{}
+		writeRvlc(seg, esc, diffs[:3])
+		at := seg.bits
+		writeRvlc(seg, esc, diffs[3:])
+		writeRvlc(seg, esc, []int{is_last})
+		if test.corrupt {
+			// An invalid palindrome in place of the 9 bit codeword of 6
+			seg.patch(at, 0x1ab, 9)
+		}
+
+		data := &scale_factor_data{
+			Dcpm_is_position:    [][]uint8{make([]uint8, 7)},
+			Dcpm_noise_nrg:      [][]uint16{make([]uint16, 7)},
+			Dcpm_sf:             [][]uint8{make([]uint8, 7)},
+			Rev_global_gain:     uint8(100 + 3 - 15 + 6 + 25),
+			Rvlc_cod_sf:         rvlcBytes(seg),
+			Sf_escapes_present:  true,
+			Len_of_rvlc_escapes: uint8(esc.bits),
+			Rvlc_esc_sf:         rvlcBytes(esc),
+		}
+		if err := rvlc_decode(new(frame_memory), info, 100, data, seg.bits); err != nil {
+			t.Fatalf("%s: err (%s) must be nil", test.name, err)
+		}
+
+		want := []int{3, -15, 2, 6, 0, -4, 25}
+		for sfb, d := range want {
+			var got int
+			switch info.sfb_cb[0][sfb] {
+			case ZERO_HCB:
+				continue
+			case INTENSITY_HCB:
+				got = int(data.Dcpm_is_position[0][sfb]) - RVLC_DPCM_OFFSET
+			default:
+				got = int(data.Dcpm_sf[0][sfb]) - RVLC_DPCM_OFFSET
+			}
+			if got != d {
+				t.Errorf("%s: band %d difference (%d) must be %d", test.name, sfb, got, d)
+			}
+		}
+		if int(data.Dcpm_is_last_position)-RVLC_DPCM_OFFSET != is_last {
+			t.Errorf("%s: last intensity position (%d) must be %d", test.name,
+				int(data.Dcpm_is_last_position)-RVLC_DPCM_OFFSET, is_last)
+		}
+		if data.Rvlc_concealed != test.concealed {
+			t.Errorf("%s: Rvlc_concealed (%t) must be %t", test.name, data.Rvlc_concealed, test.concealed)
+		}
+	}
+}
+
+func TestErSectionAndRvlcParse(t *testing.T) {
+	config := &AudioSpecificConfig{
+		Audio_object_type:        AUDIO_OBJECT_TYPE_ER,
+		Sampling_frequency_index: 3,
+		Sampling_frequency:       48000,
+		Channel_configuration:    1,
+		Ga_specific_config: &ga_specific_config{
+			Extension_flag:                       true,
+			Aac_section_data_resilience_flag:     true,
+			Aac_scalefactor_data_resilience_flag: true,
+		},
+	}
+
+	seg := &bitWriter{}
+	esc := &bitWriter{}
+	writeRvlc(seg, esc, []int{3, 17, -2})
+
+	w := &bitWriter{}
+	w.write(0, 4)   // element_instance_tag
+	w.write(100, 8) // global_gain
+	w.write(0, 1)   // ics_reserved_bit
+	w.write(ONLY_LONG_SEQUENCE, 2)
+	w.write(0, 1) // window_shape
+	w.write(4, 6) // max_sfb
+	w.write(0, 1) // predictor_data_present
+
+	// section_data, codebooks 11 and 16 have no length
+	w.write(ESC_HCB, 5)
+	w.write(FIRST_VCB11, 5)
+	w.write(1, 5)
+	w.write(1, 5)
+	w.write(NOISE_HCB, 5)
+	w.write(1, 5)
+
+	// scale_factor_data
+	w.write(0, 1)                  // sf_concealment
+	w.write(118, 8)                // rev_global_gain
+	w.write(uint64(seg.bits+9), 9) // length_of_rvlc_sf
+	w.write(300, 9)                // first noise energy
+	w.write(1, 1)                  // sf_escapes_present
+	w.write(uint64(esc.bits), 8)   // length_of_rvlc_escapes
+	w.write(282, 9)                // dpcm_noise_last_position
+
+	w.write(0, 1) // pulse_data_present
+	w.write(0, 1) // tns_data_present
+	w.write(0, 1) // gain_control_data_present
+	writeRvlc(w, &bitWriter{}, []int{3, 17, -2})
+	for i := uint(0); i < esc.bits; i++ {
+		w.write(uint64(esc.buf[i/8]>>(7-i%8))&1, 1)
+	}
+
+	// spectral_data, all zero
+	w.write(0, 4)
+	w.write(0, 4)
+	w.write(0, 4)
+	w.write(0, 4)
+	w.write(0, 1)
+	w.byteAlign()
+
+	adts, err := ParseRawDataBlock(config, w.buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	s := adts.Single_channel_elements[0].Channel_stream
+	cbs := s.Ics_info.sfb_cb[0]
+	if len(cbs) != 4 || cbs[0] != ESC_HCB || cbs[1] != FIRST_VCB11 || cbs[2] != 1 || cbs[3] != NOISE_HCB {
+		t.Errorf("sfb_cb (%v) must be [11 16 1 13]", cbs)
+	}
+	data := s.Scale_factor_data
+	for sfb, d := range []int{3, 17, -2} {
+		if got := int(data.Dcpm_sf[0][sfb]) - RVLC_DPCM_OFFSET; got != d {
+			t.Errorf("band %d difference (%d) must be %d", sfb, got, d)
+		}
+	}
+	if data.Dcpm_noise_nrg[0][3] != 300 || data.Rvlc_concealed {
+		t.Errorf("noise energy (%d) must be 300 without concealment", data.Dcpm_noise_nrg[0][3])
+	}
+}
@@ -59,8 +59,50 @@ var stopMin = []int8{
 	13, 15, 20, 21, 23, 32, 32, 35, 48, 64, 70, 96,
 }
 
+// The LD_TRAN frames of low delay SBR for 16 time slots, indexed by
+// bs_transient_position.  Each row is bs_num_env and the envelope borders
+// between the leading and trailing one, the envelope of the transient
+// starting at it and spanning 4 time slots.
+var ldEnvelopeTable16 = [16][3]uint8{
+	{2, 4, 0},
+	{2, 5, 0},
+	{3, 2, 6},
+	{3, 3, 7},
+	{3, 4, 8},
+	{3, 5, 9},
+	{3, 6, 10},
+	{3, 7, 11},
+	{3, 8, 12},
+	{3, 9, 13},
+	{3, 10, 14},
+	{2, 11, 0},
+	{2, 12, 0},
+	{2, 13, 0},
+	{2, 14, 0},
+	{2, 15, 0},
+}
+
+// This table functions exactly like ldEnvelopeTable16 for 15 time slots
+var ldEnvelopeTable15 = [15][3]uint8{
+	{2, 4, 0},
+	{2, 5, 0},
+	{3, 2, 6},
+	{3, 3, 7},
+	{3, 4, 8},
+	{3, 5, 9},
+	{3, 6, 10},
+	{3, 7, 11},
+	{3, 8, 12},
+	{3, 9, 13},
+	{2, 10, 0},
+	{2, 11, 0},
+	{2, 12, 0},
+	{2, 13, 0},
+	{2, 14, 0},
+}
+
 // Frequency band table derivation is described by ISO-IEC 14496-3 4.6.18.3.2
-func derive_sbr_tables(data *sbr_extension_data, sfi uint8, bs_start_freq uint8, bs_stop_freq uint8,
+func derive_sbr_tables(mem *frame_memory, data *sbr_extension_data, sfi uint8, bs_start_freq uint8, bs_stop_freq uint8,
 	bs_freq_scale uint8, bs_alter_scale uint8, bs_xover_band uint8) error {
 	data.k0 = uint8(qmf_lower_boundary(bs_start_freq, sfi))
 	data.k2 = qmf_upper_boundary(bs_stop_freq, sfi, data.k0)
@@ -81,11 +123,11 @@ func derive_sbr_tables(data *sbr_extension_data, sfi uint8, bs_start_freq uint8,
 	}
 
 	if bs_freq_scale == 0 {
-		freq_master_fs0(data, data.k0, data.k2, bs_alter_scale)
+		freq_master_fs0(mem, data, data.k0, data.k2, bs_alter_scale)
 	} else {
-		freq_master(data, data.k0, data.k2, bs_freq_scale, bs_alter_scale)
+		freq_master(mem, data, data.k0, data.k2, bs_freq_scale, bs_alter_scale)
 	}
-	if err := freq_derived(data, bs_xover_band, data.k2); err != nil {
+	if err := freq_derived(mem, data, bs_xover_band, data.k2); err != nil {
 		return err
 	}
 
@@ -119,7 +161,7 @@ func qmf_upper_boundary(bs_stop_freq uint8, sfi uint8, k0 uint8) uint8 {
 	return uint8(val)
 }
 
-func freq_master_fs0(data *sbr_extension_data, k0 uint8, k2 uint8, bs_alter_scale uint8) {
+func freq_master_fs0(mem *frame_memory, data *sbr_extension_data, k0 uint8, k2 uint8, bs_alter_scale uint8) {
 	dk := 1
 	numBands := 0
 	if bs_alter_scale == 0 {
@@ -135,7 +177,7 @@ func freq_master_fs0(data *sbr_extension_data, k0 uint8, k2 uint8, bs_alter_scal
 	k2Achieved := int(k0) + numBands*dk
 	k2Diff := int(k2) - k2Achieved
 
-	vDk := make([]int, numBands, numBands)
+	vDk := mem.ints.make(numBands)
 	for k := 0; k < numBands; k++ {
 		vDk[k] = dk
 	}
@@ -157,7 +199,7 @@ func freq_master_fs0(data *sbr_extension_data, k0 uint8, k2 uint8, bs_alter_scal
 		}
 	}
 
-	data.f_master = make([]int, numBands)
+	data.f_master = mem.ints.make(numBands)
 	data.f_master[0] = int(k0)
 	for k := 1; k < numBands; k++ {
 		data.f_master[k] = data.f_master[k-1] + vDk[k-1]
@@ -165,7 +207,7 @@ func freq_master_fs0(data *sbr_extension_data, k0 uint8, k2 uint8, bs_alter_scal
 	data.N_master = uint8(numBands)
 }
 
-func freq_master(data *sbr_extension_data, k0 uint8, k2 uint8, bs_freq_scale uint8, bs_alter_scale uint8) {
+func freq_master(mem *frame_memory, data *sbr_extension_data, k0 uint8, k2 uint8, bs_freq_scale uint8, bs_alter_scale uint8) {
 	twoRegions := 0
 	k1 := k2
 
@@ -182,16 +224,16 @@ func freq_master(data *sbr_extension_data, k0 uint8, k2 uint8, bs_freq_scale uin
 	// 2 * NINT( bands * log(k1/k2) / (2*log(2)))
 	numBands0 := 2 * aacRound(bands*math.Log10(k1_f/k0_f)/(2.0*math.Log10(2)))
 
-	vDk0 := make([]int, numBands0+1)
+	vDk0 := mem.ints.make(numBands0 + 1)
 	// ew this is ugly...
 	for k, _ := range vDk0 {
 		// NINT( k0*(k1/k0)^((k+1)/numBands0) ) - NINT( k0*(k1/k0)^(k/numBands0) )
 		vDk0[k] = aacRound(k0_f*(math.Pow((k1_f/k0_f), float64(k+1)/float64(numBands0)))) -
 			aacRound(k0_f*math.Pow((k1_f/k0_f), float64(k)/float64(numBands0)))
 	}
-	sort.Sort(sort.IntSlice(vDk0))
+	sort.Ints(vDk0)
 
-	vk0 := make([]int, numBands0+1)
+	vk0 := mem.ints.make(numBands0 + 1)
 	vk0[0] = int(k0)
 	for k := 1; k <= numBands0; k++ {
 		vk0[k] = vk0[k-1] + vDk0[k-1]
@@ -211,13 +253,13 @@ func freq_master(data *sbr_extension_data, k0 uint8, k2 uint8, bs_freq_scale uin
 
 	// 2 * NINT( bands * log(k2/k1) / (2*log(2)*warp) )
 	numBands1 := 2 * aacRound(bands*math.Log10(k2_f/k1_f)/(2.0*math.Log10(2.0)*warp))
-	vDk1 := make([]int, numBands1-1)
+	vDk1 := mem.ints.make(numBands1 - 1)
 	for k, _ := range vDk1 {
 		// NINT( k1* (k2/k1)^((k+1)/numBands1) ) - NINT( k1* (k2/k1)^(k/numBands1) )
 		vDk1[k] = aacRound(k1_f*math.Pow(k2_f/k1_f, float64((k+1))/float64(numBands1))) -
 			aacRound(k1_f*math.Pow(k2_f/k1_f, (float64(k)/float64(numBands1))))
 	}
-	sort.Sort(sort.IntSlice(vDk1))
+	sort.Ints(vDk1)
 
 	// if min(vDk1) < max(vDk0)
 	if vDk1[0] < vDk0[cap(vDk0)-1] {
@@ -229,23 +271,23 @@ func freq_master(data *sbr_extension_data, k0 uint8, k2 uint8, bs_freq_scale uin
 		vDk1[numBands1-1] -= change
 	}
 
-	vk1 := make([]int, numBands1)
+	vk1 := mem.ints.make(numBands1)
 	vk1[0] = int(k1)
 	for k := 1; k < numBands1; k++ {
 		vk1[k] = vk1[k-1] + vDk1[k-1]
 	}
 
 	data.N_master = uint8(numBands0 + numBands1)
-	data.f_master = make([]int, 0)
-	data.f_master = append(data.f_master, vk0...)
-	data.f_master = append(data.f_master, vk1...)
+	data.f_master = mem.ints.make_cap(0, len(vk0)+len(vk1))
+	data.f_master = mem.ints.append(data.f_master, vk0...)
+	data.f_master = mem.ints.append(data.f_master, vk1...)
 }
 
-func freq_derived(data *sbr_extension_data, bs_xover_band uint8, k2 uint8) error {
+func freq_derived(mem *frame_memory, data *sbr_extension_data, bs_xover_band uint8, k2 uint8) error {
 	data.N_high = data.N_master - bs_xover_band
 	data.N_low = (data.N_high >> 1) + (data.N_high - (data.N_high>>1)<<1)
 
-	data.n = make([]uint8, 2)
+	data.n = mem.uint8s.make(2)
 	data.n[0] = data.N_low
 	data.n[1] = data.N_high
 
@@ -263,7 +305,7 @@ func freq_derived(data *sbr_extension_data, bs_xover_band uint8, k2 uint8) error
 	data.M = uint8(data.f_tablehigh[data.N_high] - data.f_tablehigh[0])
 	data.k_x = data.f_tablehigh[0]
 
-	data.f_tablelow = make([]int, int(data.N_low+1))
+	data.f_tablelow = mem.ints.make(int(data.N_low + 1))
 	i := 0
 	for k, _ := range data.f_tablelow {
 		if k != 0 {
@@ -278,7 +320,7 @@ func freq_derived(data *sbr_extension_data, bs_xover_band uint8, k2 uint8) error
 	k_x_f := float64(data.k_x)
 	data.N_Q = uint8(maxInt(1, aacRound(float64(bs_noise_bands)*(math.Log10(k2_f/k_x_f)/math.Log10(2)))))
 
-	data.f_tablenoise = make([]int, int(data.N_Q+1))
+	data.f_tablenoise = mem.ints.make(int(data.N_Q + 1))
 	i = 0
 	for k, _ := range data.f_tablenoise {
 		if k != 0 {
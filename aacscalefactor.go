@@ -0,0 +1,86 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"fmt"
+)
+
+// Ranges of the decoded values.  Intensity positions and noise energies
+// outside them can't be represented by the dequantization tables.
+const (
+	MAX_SCALE_FACTOR    = 255
+	MIN_IS_POSITION     = -155
+	MAX_IS_POSITION     = 100
+	MIN_NOISE_ENERGY    = -100
+	MAX_NOISE_ENERGY    = 155
+	SF_INDEX_OFFSET     = 60
+	NOISE_ENERGY_OFFSET = 90
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// 4.6.2.3.3 Decoding of scalefactors
+////////////////////////////////////////////////////////////////////////////////
+// The absolute value of each scale factor band indexed [group][sfb]: the
+// scale factor, the noise energy of noise bands or the intensity position
+// of intensity bands.  Bands of the zero codebook are 0.
+func (ics *individual_channel_stream) ScaleFactors() ([][]int16, error) {
+	info := ics.Ics_info
+	data := ics.Scale_factor_data
+	if info == nil || data == nil {
+		return nil, fmt.Errorf("Error: individual_channel_stream has no scale_factor_data")
+	}
+
+	scale_factor := int(ics.Global_gain)
+	noise_energy := int(ics.Global_gain) - NOISE_ENERGY_OFFSET
+	is_position := 0
+	noise_pcm_flag := true
+
+	sf := make([][]int16, info.num_window_groups)
+	for g := uint8(0); g < info.num_window_groups; g++ {
+		sf[g] = make([]int16, info.Max_sfb)
+		for sfb := uint8(0); sfb < info.Max_sfb; sfb++ {
+			switch {
+			case info.sfb_cb[g][sfb] == ZERO_HCB:
+			case is_intensity(info, g, sfb) != 0:
+				is_position += int(data.Dcpm_is_position[g][sfb]) - SF_INDEX_OFFSET
+				if is_position < MIN_IS_POSITION || is_position > MAX_IS_POSITION {
+					return sf, fmt.Errorf("Error: intensity position (%d) of band %d out of range", is_position, sfb)
+				}
+				sf[g][sfb] = int16(is_position)
+			case is_noise(info, g, sfb):
+				if noise_pcm_flag {
+					noise_pcm_flag = false
+					noise_energy += int(data.Dcpm_noise_nrg[g][sfb]) - NOISE_OFFSET
+				} else {
+					noise_energy += int(data.Dcpm_noise_nrg[g][sfb]) - SF_INDEX_OFFSET
+				}
+				if noise_energy < MIN_NOISE_ENERGY || noise_energy > MAX_NOISE_ENERGY {
+					return sf, fmt.Errorf("Error: noise energy (%d) of band %d out of range", noise_energy, sfb)
+				}
+				sf[g][sfb] = int16(noise_energy)
+			default:
+				scale_factor += int(data.Dcpm_sf[g][sfb]) - SF_INDEX_OFFSET
+				if scale_factor < 0 || scale_factor > MAX_SCALE_FACTOR {
+					return sf, fmt.Errorf("Error: scale factor (%d) of band %d out of range", scale_factor, sfb)
+				}
+				sf[g][sfb] = int16(scale_factor)
+			}
+		}
+	}
+	return sf, nil
+}
@@ -0,0 +1,107 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Comcast/gaad/bitreader"
+)
+
+// Writes the hcod_sf codeword of the difference
+func writeHcodSf(t *testing.T, w *bitWriter, diff int) {
+	for length := uint(1); length <= 19; length++ {
+		for code := uint64(0); code < 1<<length; code++ {
+			c := &bitWriter{}
+			c.write(code, length)
+			c.write(0, 24)
+			reader := bitreader.NewBitReader(c.buf)
+			if val, err := hcod_sf(reader); err == nil && int(val) == diff+SF_INDEX_OFFSET &&
+				uint(len(c.buf))*8-reader.BitsLeft() == length {
+				w.write(code, length)
+				return
+			}
+		}
+	}
+	t.Fatalf("hcod_sf has no codeword of %d", diff)
+}
+
+// A long window SCE with scale factor, noise, zero, intensity and scale
+// factor bands, all spectral data silent
+func scaleFactorFrame(t *testing.T, global_gain uint64, diffs []int) []byte {
+	return adtsFrame(1, 3, 1, func(w *bitWriter) {
+		w.write(ID_SCE, 3)
+		w.write(0, 4) // element_instance_tag
+		w.write(global_gain, 8)
+		w.write(0, 1) // ics_reserved_bit
+		w.write(ONLY_LONG_SEQUENCE, 2)
+		w.write(0, 1) // window_shape
+		w.write(6, 6) // max_sfb
+		w.write(0, 1) // predictor_data_present
+		for _, cb := range []uint64{1, NOISE_HCB, ZERO_HCB, INTENSITY_HCB, NOISE_HCB, 1} {
+			w.write(cb, 4)
+			w.write(1, 5)
+		}
+		writeHcodSf(t, w, diffs[0])
+		w.write(300, 9) // first noise energy
+		writeHcodSf(t, w, diffs[1])
+		writeHcodSf(t, w, diffs[2])
+		writeHcodSf(t, w, diffs[3])
+		w.write(0, 1) // pulse_data_present
+		w.write(0, 1) // tns_data_present
+		w.write(0, 1) // gain_control_data_present
+		for sfb := 0; sfb < 2; sfb++ {
+			w.write(0, 1) // hcod of a zero quad
+		}
+	})
+}
+
+func TestScaleFactors(t *testing.T) {
+	adts, err := ParseADTS(scaleFactorFrame(t, 100, []int{5, -3, 7, -2}))
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	sf, err := adts.Single_channel_elements[0].Channel_stream.ScaleFactors()
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	// Noise energies start from global_gain - 90 plus the 9 bit PCM value
+	want := [][]int16{{105, 100 - 90 + 300 - 256, 0, -3, 54 + 7, 103}}
+	if !reflect.DeepEqual(sf, want) {
+		t.Errorf("scale factors %v must be %v", sf, want)
+	}
+
+	// Out of range after the largest differences
+	adts, err = ParseADTS(scaleFactorFrame(t, 250, []int{10, 0, 0, 0}))
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	if _, err := adts.Single_channel_elements[0].Channel_stream.ScaleFactors(); err == nil {
+		t.Errorf("a scale factor above %d must return an error", MAX_SCALE_FACTOR)
+	}
+
+	ics := adts.Single_channel_elements[0].Channel_stream
+	ics.Global_gain = 100
+	for _, sfb := range []int{1, 3, 4} {
+		ics.Ics_info.sfb_cb[0][sfb] = INTENSITY_HCB
+		ics.Scale_factor_data.Dcpm_is_position[0][sfb] = 0
+	}
+	if _, err := ics.ScaleFactors(); err == nil {
+		t.Errorf("an intensity position below %d must return an error", MIN_IS_POSITION)
+	}
+}
@@ -0,0 +1,110 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"fmt"
+)
+
+// The quantized spectral coefficients of the channel indexed [window][bin],
+// one window of the frame length for long windows and eight of an eighth of
+// it for EIGHT_SHORT_SEQUENCE.  Pulses are added, bands of the zero, noise
+// and intensity codebooks are left at zero.
+func (ics *individual_channel_stream) QuantizedSpectrum() ([][]int16, error) {
+	info := ics.Ics_info
+	if info == nil || ics.Section_data == nil || ics.Spectral_data == nil {
+		return nil, fmt.Errorf("Error: individual_channel_stream has no spectral data")
+	}
+	sec_data := ics.Section_data
+
+	spec := make([][]int16, info.num_windows)
+	for w := range spec {
+		spec[w] = make([]int16, info.swb_offset[info.num_swb])
+	}
+
+	// Lines of a band in a window group are sent window after window
+	tuples := ics.Spectral_data.Hcod
+	window := 0
+	for g := uint8(0); g < info.num_window_groups; g++ {
+		group_length := uint16(info.window_group_length[g])
+		for i := uint8(0); i < sec_data.num_sec[g]; i++ {
+			switch sec_data.Sect_cb[g][i] {
+			case ZERO_HCB, NOISE_HCB, INTENSITY_HCB, INTENSITY_HCB2:
+				continue
+			}
+
+			sfb := sec_data.sect_start[g][i]
+			start := info.sect_sfb_offset[g][sfb]
+			end := info.sect_sfb_offset[g][sec_data.sect_end[g][i]]
+			for k := start; k < end; {
+				if len(tuples) == 0 {
+					return spec, fmt.Errorf("Error: spectral_data has too few tuples")
+				}
+				for _, v := range tuples[0] {
+					for k >= info.sect_sfb_offset[g][sfb+1] {
+						sfb++
+					}
+					width := info.swb_offset[sfb+1] - info.swb_offset[sfb]
+					line := k - info.sect_sfb_offset[g][sfb]
+					spec[window+int(line/width)][info.swb_offset[sfb]+line%width] = v
+					k++
+				}
+				tuples = tuples[1:]
+			}
+		}
+		window += int(group_length)
+	}
+	if len(tuples) != 0 {
+		return spec, fmt.Errorf("Error: spectral_data has %d tuples beyond its sections", len(tuples))
+	}
+
+	if ics.Pulse_data_present && ics.Pulse_data != nil && info.num_windows == 1 {
+		pulse := ics.Pulse_data
+		if pulse.Pulse_start_sfb >= info.num_swb {
+			return spec, fmt.Errorf("Error: pulse_start_sfb (%d) beyond num_swb (%d)", pulse.Pulse_start_sfb, info.num_swb)
+		}
+		k := int(info.swb_offset[pulse.Pulse_start_sfb])
+		for i := range pulse.Pulse_offset {
+			k += int(pulse.Pulse_offset[i])
+			if k >= len(spec[0]) {
+				return spec, fmt.Errorf("Error: pulse at line %d beyond the spectrum", k)
+			}
+			if spec[0][k] > 0 {
+				spec[0][k] += int16(pulse.Pulse_amp[i])
+			} else {
+				spec[0][k] -= int16(pulse.Pulse_amp[i])
+			}
+		}
+	}
+	return spec, nil
+}
+
+// Start of each scale factor band of a window, up to the window length
+func (info *ics_info) SwbOffset() []uint16 {
+	return info.swb_offset[:info.num_swb+1]
+}
+
+// Codebook of each scale factor band indexed [window][sfb], up to max_sfb
+func (info *ics_info) SfbCodebooks() [][]uint8 {
+	cbs := make([][]uint8, 0, info.num_windows)
+	for g := range info.sfb_cb {
+		for w := uint8(0); w < info.window_group_length[g]; w++ {
+			cbs = append(cbs, info.sfb_cb[g])
+		}
+	}
+	return cbs
+}
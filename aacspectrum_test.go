@@ -0,0 +1,213 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Writes the pair with its sign bits and escapes
+func writePair(t *testing.T, w *bitWriter, x int16, y int16) {
+	abs := func(v int16) int16 {
+		if v < 0 {
+			return -v
+		}
+		return v
+	}
+	esc := func(v int16) int16 {
+		if abs(v) >= 16 {
+			return 16
+		}
+		return abs(v)
+	}
+
+	code, length := hcodCodeword(t, ESC_HCB, []int16{esc(x), esc(y)})
+	w.write(code, length)
+	for _, v := range []int16{x, y} {
+		if v < 0 {
+			w.write(1, 1)
+		} else if v > 0 {
+			w.write(0, 1)
+		}
+	}
+	for _, v := range []int16{x, y} {
+		if abs(v) >= 16 {
+			// escape_prefix of 4, then the 8 bit escape_word
+			w.write(0x1e, 5)
+			w.write(uint64(abs(v)-256), 8)
+		}
+	}
+}
+
+func TestQuantizedSpectrumShortWindows(t *testing.T) {
+	// The first two windows grouped, their first two bands coded with
+	// codebook 11 and every other group silent
+	pairs := [][2]int16{{300, 1}, {2, -3}, {0, 4}, {-5, 6}, {7, 0}, {-8, -9}, {10, 11}, {12, -260}}
+	buf := adtsFrame(1, 3, 1, func(w *bitWriter) {
+		w.write(ID_SCE, 3)
+		w.write(0, 4)   // element_instance_tag
+		w.write(100, 8) // global_gain
+		w.write(0, 1)   // ics_reserved_bit
+		w.write(EIGHT_SHORT_SEQUENCE, 2)
+		w.write(0, 1)    // window_shape
+		w.write(2, 4)    // max_sfb
+		w.write(0x40, 7) // scale_factor_grouping
+		w.write(ESC_HCB, 4)
+		w.write(2, 3)
+		for g := 1; g < 7; g++ {
+			w.write(ZERO_HCB, 4)
+			w.write(2, 3)
+		}
+		w.write(0, 2) // hcod_sf of no difference, twice
+		w.write(0, 1) // pulse_data_present
+		w.write(0, 1) // tns_data_present
+		w.write(0, 1) // gain_control_data_present
+		for _, p := range pairs {
+			writePair(t, w, p[0], p[1])
+		}
+	})
+
+	adts, err := ParseADTS(buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	ics := adts.Single_channel_elements[0].Channel_stream
+	spec, err := ics.QuantizedSpectrum()
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	if len(spec) != 8 || len(spec[0]) != 128 {
+		t.Fatalf("spectrum must be 8 windows of 128 bins, is %d of %d", len(spec), len(spec[0]))
+	}
+
+	// Band 0 of windows 0 and 1, then band 1 of windows 0 and 1
+	want := make([][]int16, 8)
+	for w := range want {
+		want[w] = make([]int16, 128)
+	}
+	for i, p := range pairs {
+		for j, v := range p {
+			line := 2*i + j
+			want[line%8/4][line/8*4+line%4] = v
+		}
+	}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("spectrum %v must be %v", spec[:2], want[:2])
+	}
+
+	if !reflect.DeepEqual(ics.Ics_info.SwbOffset(), []uint16{0, 4, 8, 12, 16, 20, 28, 36, 44, 56, 68, 80, 96, 112, 128}) {
+		t.Errorf("SwbOffset %v must be the 48000 Hz short window bands", ics.Ics_info.SwbOffset())
+	}
+	cbs := ics.Ics_info.SfbCodebooks()
+	if len(cbs) != 8 || !reflect.DeepEqual(cbs[1], []uint8{ESC_HCB, ESC_HCB}) || !reflect.DeepEqual(cbs[2], []uint8{ZERO_HCB, ZERO_HCB}) {
+		t.Errorf("SfbCodebooks %v must be codebook 11 for the first two windows", cbs)
+	}
+}
+
+func TestQuantizedSpectrumPulses(t *testing.T) {
+	code, length := hcodCodeword(t, 1, []int16{0, 1, 0, -1})
+	buf := adtsFrame(1, 3, 1, func(w *bitWriter) {
+		w.write(ID_SCE, 3)
+		w.write(0, 4)   // element_instance_tag
+		w.write(100, 8) // global_gain
+		w.write(0, 1)   // ics_reserved_bit
+		w.write(ONLY_LONG_SEQUENCE, 2)
+		w.write(0, 1) // window_shape
+		w.write(1, 6) // max_sfb
+		w.write(0, 1) // predictor_data_present
+		w.write(1, 4) // sect_cb
+		w.write(1, 5) // sect_len
+		w.write(0, 1) // hcod_sf of no difference
+		w.write(1, 1) // pulse_data_present
+		w.write(1, 2) // number_pulse, two pulses
+		w.write(0, 6) // pulse_start_sfb
+		w.write(1, 5) // pulse_offset
+		w.write(3, 4) // pulse_amp
+		w.write(2, 5)
+		w.write(5, 4)
+		w.write(0, 1) // tns_data_present
+		w.write(0, 1) // gain_control_data_present
+		w.write(code, length)
+	})
+
+	adts, err := ParseADTS(buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	spec, err := adts.Single_channel_elements[0].Channel_stream.QuantizedSpectrum()
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	if len(spec) != 1 || len(spec[0]) != 1024 {
+		t.Fatalf("spectrum must be 1 window of 1024 bins")
+	}
+	if !reflect.DeepEqual(spec[0][:5], []int16{0, 4, 0, -6, 0}) {
+		t.Errorf("spectrum %v must be [0 4 0 -6 0] with the pulses added", spec[0][:5])
+	}
+}
+
+// number_pulse is one less than the pulses sent, a single pulse must be
+// read and the syntax after it still line up
+func TestPulseDataParse(t *testing.T) {
+	code, length := hcodCodeword(t, 1, []int16{1, 0, 0, 0})
+	buf := adtsFrame(1, 3, 1, func(w *bitWriter) {
+		w.write(ID_SCE, 3)
+		w.write(0, 4)   // element_instance_tag
+		w.write(100, 8) // global_gain
+		w.write(0, 1)   // ics_reserved_bit
+		w.write(ONLY_LONG_SEQUENCE, 2)
+		w.write(0, 1) // window_shape
+		w.write(1, 6) // max_sfb
+		w.write(0, 1) // predictor_data_present
+		w.write(1, 4) // sect_cb
+		w.write(1, 5) // sect_len
+		w.write(0, 1) // hcod_sf of no difference
+		w.write(1, 1) // pulse_data_present
+		w.write(0, 2) // number_pulse, one pulse
+		w.write(0, 6) // pulse_start_sfb
+		w.write(0, 5) // pulse_offset
+		w.write(7, 4) // pulse_amp
+		w.write(0, 1) // tns_data_present
+		w.write(0, 1) // gain_control_data_present
+		w.write(code, length)
+	})
+
+	adts, err := ParseADTS(buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	ics := adts.Single_channel_elements[0].Channel_stream
+	if !ics.Pulse_data_present || ics.Pulse_data == nil {
+		t.Fatalf("Pulse_data must be parsed")
+	}
+	pulse := ics.Pulse_data
+	if pulse.Number_pulse != 0 || !reflect.DeepEqual(pulse.Pulse_offset, []uint8{0}) || !reflect.DeepEqual(pulse.Pulse_amp, []uint8{7}) {
+		t.Errorf("pulse_data (%d, %v, %v) must be (0, [0], [7])", pulse.Number_pulse, pulse.Pulse_offset, pulse.Pulse_amp)
+	}
+	if ics.Tns_data_present || ics.Gain_control_data_present {
+		t.Errorf("tns_data_present and gain_control_data_present after pulse_data must be 0")
+	}
+
+	spec, err := ics.QuantizedSpectrum()
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	if !reflect.DeepEqual(spec[0][:4], []int16{8, 0, 0, 0}) {
+		t.Errorf("spectrum %v must be [8 0 0 0] with the pulse added", spec[0][:4])
+	}
+}
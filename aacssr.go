@@ -0,0 +1,308 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"math"
+	"sync"
+)
+
+// The SSR filterbank splits the signal into four PQF bands, each with its
+// own quarter length MDCT
+const SSR_BANDS = 4
+
+// alevcode of unity gain, alev = 2^(alevcode - ID_GAIN)
+const ID_GAIN = 4
+
+// Gain changes are interpolated over 8 samples
+const SSR_TRANSITION = 8
+
+// Length of the PQF prototype filter
+const ssr_pqf_taps = 96
+
+// SSR decoding state of a channel, kept across frames so each decoded
+// channel needs its own SSRState.
+type SSRState struct {
+	frame_length int
+	windows      *filterbank_windows
+
+	// Per band: the second half of the last IMDCT, the gain function of the
+	// last frame and the last subband samples for the synthesis filter
+	overlap   [SSR_BANDS][]float32
+	prev_gain [SSR_BANDS][]float32
+	pqf       [SSR_BANDS][]float32
+
+	window_shape_prev uint8
+}
+
+func NewSSRState(frame_length int) *SSRState {
+	nb := frame_length / SSR_BANDS
+	s := &SSRState{
+		frame_length: frame_length,
+		windows:      new_filterbank_windows(nb),
+	}
+	for b := 0; b < SSR_BANDS; b++ {
+		s.overlap[b] = make([]float32, nb)
+		s.prev_gain[b] = unity_gain(nb)
+		s.pqf[b] = make([]float32, ssr_pqf_taps/SSR_BANDS)
+	}
+	return s
+}
+
+// Decodes a channel's dequantized spectrum into frame_length time samples.
+// Each band's quarter of the spectrum is transformed with its own IMDCT and
+// windowed, gain control, when gc is not nil, is undone on the result and
+// the bands are overlap-added and recombined with the inverse PQF.  The
+// output is delayed by the PQF's ssr_pqf_taps-1 samples.
+//
+// The PQF prototype is not yet the coefficient table of ISO/IEC 14496-3,
+// see ssr_prototype, so the output doesn't match a conforming decoder's.
+func (s *SSRState) Decode(info *ics_info, gc *gain_control_data, spec []float32) []float32 {
+	nb := s.frame_length / SSR_BANDS
+	bands := make([][]float32, SSR_BANDS)
+
+	for b := range bands {
+		block := s.band_imdct(info, b, spec)
+
+		gain := unity_gain(nb)
+		if gc != nil && b > 0 && b <= int(gc.Max_band) {
+			gain = ssr_gain(info, gc, b, nb)
+		}
+		for i := 0; i < nb; i++ {
+			block[i] /= s.prev_gain[b][i]
+			block[nb+i] /= gain[i]
+		}
+		s.prev_gain[b] = gain
+
+		bands[b] = make([]float32, nb)
+		for i := 0; i < nb; i++ {
+			bands[b][i] = block[i] + s.overlap[b][i]
+		}
+		copy(s.overlap[b], block[nb:])
+	}
+	s.window_shape_prev = info.Window_shape
+
+	return s.ipqf(bands)
+}
+
+// Windowed 2*nb sample IMDCT of one band.  A band is a quarter of each
+// window's coefficients, odd bands are spectrally inverted by the PQF so
+// their coefficients come in reverse order.
+func (s *SSRState) band_imdct(info *ics_info, b int, spec []float32) []float32 {
+	nb := s.frame_length / SSR_BANDS
+	out := make([]float32, 2*nb)
+
+	coefs := func(offset, n int) []float32 {
+		c := make([]float32, n)
+		for k := range c {
+			i := k
+			if b%2 == 1 {
+				i = n - 1 - k
+			}
+			if offset+i < len(spec) {
+				c[k] = spec[offset+i]
+			}
+		}
+		return c
+	}
+
+	if info.Window_sequence != EIGHT_SHORT_SEQUENCE {
+		imdct(coefs(b*nb, nb), out)
+		s.windows.window(info.Window_sequence, info.Window_shape, s.window_shape_prev, out, out)
+		return out
+	}
+
+	ns := nb / 8
+	short := s.windows.short[info.Window_shape&1]
+	buf := make([]float32, 2*ns)
+	for w := 0; w < 8; w++ {
+		imdct(coefs(w*s.frame_length/8+b*ns, ns), buf)
+		rising := short
+		if w == 0 {
+			rising = s.windows.short[s.window_shape_prev&1]
+		}
+		offset := (nb-ns)/2 + w*ns
+		for i := 0; i < ns; i++ {
+			out[offset+i] += buf[i] * rising[i]
+			out[offset+ns+i] += buf[ns+i] * short[ns-1-i]
+		}
+	}
+	return out
+}
+
+func unity_gain(n int) []float32 {
+	g := make([]float32, n)
+	for i := range g {
+		g[i] = 1
+	}
+	return g
+}
+
+// A gain control area of the frame, per window of the window_sequence:
+// where it starts, its length and the bits of its aloccode
+type ssr_area struct {
+	offset int
+	length int
+	bits   uint
+}
+
+func ssr_areas(window_sequence uint8, nb int) []ssr_area {
+	switch window_sequence {
+	case LONG_START_SEQUENCE:
+		return []ssr_area{{0, nb * 7 / 16, 4}, {nb * 7 / 16, nb / 8, 2}}
+	case EIGHT_SHORT_SEQUENCE:
+		areas := make([]ssr_area, 8)
+		for w := range areas {
+			areas[w] = ssr_area{w * nb / 8, nb / 8, 2}
+		}
+		return areas
+	case LONG_STOP_SEQUENCE:
+		return []ssr_area{{0, nb / 2, 4}, {nb / 2, nb / 2, 5}}
+	default:
+		return []ssr_area{{0, nb, 5}}
+	}
+}
+
+// Gain function of band b over the frame's nb samples.  In each window's
+// area the gain starts at the first alevcode's level, moves to the next
+// level at each aloccode and is back to unity after the last one.  Level
+// changes are log-linear over SSR_TRANSITION samples.
+func ssr_gain(info *ics_info, gc *gain_control_data, b int, nb int) []float32 {
+	gain := unity_gain(nb)
+	if b >= len(gc.Adjust_num) {
+		return gain
+	}
+
+	for wd, area := range ssr_areas(info.Window_sequence, nb) {
+		if wd >= len(gc.Adjust_num[b]) {
+			break
+		}
+		n := int(gc.Adjust_num[b][wd])
+		if n == 0 {
+			continue
+		}
+
+		levels := make([]float64, n+1)
+		for m := 0; m < n; m++ {
+			levels[m] = math.Pow(2, float64(int(gc.Alevcode[b][wd][m])-ID_GAIN))
+		}
+		levels[n] = 1
+
+		step := area.length >> area.bits
+		m := 0
+		for i := 0; i < area.length; i++ {
+			for m < n && i >= int(gc.Aloccode[b][wd][m])*step+SSR_TRANSITION {
+				m++
+			}
+			level := levels[m]
+			if m < n {
+				if t := i - int(gc.Aloccode[b][wd][m])*step; t >= 0 {
+					x := float64(t) / SSR_TRANSITION
+					level = math.Pow(levels[m], 1-x) * math.Pow(levels[m+1], x)
+				}
+			}
+			if area.offset+i < nb {
+				gain[area.offset+i] = float32(level)
+			}
+		}
+	}
+	return gain
+}
+
+// Recombines nb samples of each band into SSR_BANDS*nb output samples,
+//
+//	y[n] = M * sum(x_k[m] * f_k[n - mM])
+//
+// with the cosine modulated synthesis filters f_k of the PQF.
+func (s *SSRState) ipqf(bands [][]float32) []float32 {
+	f := ssr_synthesis_filters()
+	taps := ssr_pqf_taps / SSR_BANDS
+	nb := len(bands[0])
+	out := make([]float32, SSR_BANDS*nb)
+
+	for m := 0; m < nb; m++ {
+		for b := 0; b < SSR_BANDS; b++ {
+			hist := s.pqf[b]
+			copy(hist[1:], hist[:taps-1])
+			hist[0] = bands[b][m]
+		}
+		for r := 0; r < SSR_BANDS; r++ {
+			var y float64
+			for b := 0; b < SSR_BANDS; b++ {
+				for j := 0; j < taps; j++ {
+					y += float64(s.pqf[b][j]) * f[b][r+j*SSR_BANDS]
+				}
+			}
+			out[m*SSR_BANDS+r] = float32(SSR_BANDS * y)
+		}
+	}
+	return out
+}
+
+var (
+	ssr_synthesis_once sync.Once
+	ssr_synthesis      [SSR_BANDS][]float64
+)
+
+// The PQF's synthesis filters, f_k[n] = 2h[n]cos(pi/M(k + 1/2)(n - (L-1)/2)
+// - theta_k) with theta_k = (-1)^k pi/4, modulated from the prototype h.
+// The matching analysis filters use + theta_k.
+func ssr_synthesis_filters() [SSR_BANDS][]float64 {
+	ssr_synthesis_once.Do(func() {
+		h := ssr_prototype()
+		for k := range ssr_synthesis {
+			ssr_synthesis[k] = ssr_modulate(h, k, -ssr_phase(k))
+		}
+	})
+	return ssr_synthesis
+}
+
+func ssr_phase(k int) float64 {
+	if k%2 == 1 {
+		return -math.Pi / 4
+	}
+	return math.Pi / 4
+}
+
+func ssr_modulate(h []float64, k int, theta float64) []float64 {
+	c := float64(len(h)-1) / 2
+	f := make([]float64, len(h))
+	for n := range h {
+		f[n] = 2 * h[n] * math.Cos(math.Pi/SSR_BANDS*(float64(k)+0.5)*(float64(n)-c)+theta)
+	}
+	return f
+}
+
+// Lowpass prototype of the PQF.  This is not the coefficient table of the
+// standard, which has to replace it for conforming output, but a filter
+// designed to the same constraints: the response
+// cos(M*w/2) up to pi/M and 0 above is power complementary around the
+// pi/2M cutoff, so adjacent bands cancel each other's aliasing.  Truncated
+// to ssr_pqf_taps it reconstructs to around -58 dB.
+//
+//	h[n] = 1/pi * integral(cos(M*w/2) * cos(w*(n - (L-1)/2)), w = 0..pi/M)
+func ssr_prototype() []float64 {
+	a := float64(SSR_BANDS) / 2
+	c := float64(ssr_pqf_taps-1) / 2
+	h := make([]float64, ssr_pqf_taps)
+	for n := range h {
+		// n - c is never +-a with an even number of taps
+		b := float64(n) - c
+		h[n] = (math.Sin((a-b)*math.Pi/SSR_BANDS)/(a-b) + math.Sin((a+b)*math.Pi/SSR_BANDS)/(a+b)) / (2 * math.Pi)
+	}
+	return h
+}
@@ -0,0 +1,222 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"math"
+	"testing"
+)
+
+// Gain control data with one level change in band 1 of a long frame
+func ssrGainControl(alevcode uint8, aloccode uint8) *gain_control_data {
+	return &gain_control_data{
+		Max_band:   1,
+		Adjust_num: [][]uint8{nil, {1}},
+		Alevcode:   [][][]uint8{nil, {{alevcode}}},
+		Aloccode:   [][][]uint8{nil, {{aloccode}}},
+	}
+}
+
+func TestSSRGain(t *testing.T) {
+	info := &ics_info{Window_sequence: ONLY_LONG_SEQUENCE}
+	gain := ssr_gain(info, ssrGainControl(ID_GAIN+2, 16), 1, 256)
+
+	// 4 up to location 16 * 8, back to 1 over SSR_TRANSITION samples
+	for i := 0; i < 128; i++ {
+		if !approx(gain[i], 4) {
+			t.Fatalf("gain[%d] (%f) must be 4", i, gain[i])
+		}
+	}
+	if !approx(gain[132], 2) {
+		t.Errorf("gain[132] (%f) must be 2 halfway through the transition", gain[132])
+	}
+	for i := 128 + SSR_TRANSITION; i < 256; i++ {
+		if !approx(gain[i], 1) {
+			t.Fatalf("gain[%d] (%f) must be 1", i, gain[i])
+		}
+	}
+
+	// Short windows each have their own 32 sample area
+	info.Window_sequence = EIGHT_SHORT_SEQUENCE
+	gc := &gain_control_data{
+		Max_band:   1,
+		Adjust_num: [][]uint8{nil, {0, 0, 1, 0, 0, 0, 0, 0}},
+		Alevcode:   [][][]uint8{nil, {nil, nil, {ID_GAIN - 1}, nil, nil, nil, nil, nil}},
+		Aloccode:   [][][]uint8{nil, {nil, nil, {1}, nil, nil, nil, nil, nil}},
+	}
+	gain = ssr_gain(info, gc, 1, 256)
+	if !approx(gain[63], 1) || !approx(gain[64], 0.5) || !approx(gain[71], 0.5) || !approx(gain[80], 1) {
+		t.Errorf("short window gain (%f, %f, %f, %f) must be (1, 0.5, 0.5, 1)", gain[63], gain[64], gain[71], gain[80])
+	}
+}
+
+// Splits x into SSR_BANDS subbands with the PQF's analysis filters
+func pqfAnalysis(x []float32) [][]float32 {
+	h := ssr_prototype()
+	bands := make([][]float32, SSR_BANDS)
+	for k := range bands {
+		a := ssr_modulate(h, k, ssr_phase(k))
+		bands[k] = make([]float32, len(x)/SSR_BANDS)
+		for m := range bands[k] {
+			var y float64
+			for n := range a {
+				if i := m*SSR_BANDS - n; i >= 0 {
+					y += a[n] * float64(x[i])
+				}
+			}
+			bands[k][m] = float32(y)
+		}
+	}
+	return bands
+}
+
+// Encodes frames of SSR_BANDS*nb samples with the PQF and per band MDCTs,
+// applying each frame's gains[frame][band] to the band before transforming
+// as an encoder's gain control does, and decodes them again
+func ssrRoundtrip(x []float32, gains []map[int]*gain_control_data) []float32 {
+	const frame_length = 1024
+	const nb = frame_length / SSR_BANDS
+	info := &ics_info{Window_sequence: ONLY_LONG_SEQUENCE}
+	fw := new_filterbank_windows(nb)
+
+	bands := pqfAnalysis(x)
+	frames := len(x) / frame_length
+	for b := range bands {
+		for f := 0; f < frames; f++ {
+			if gc := gains[f][b]; gc != nil {
+				gain := ssr_gain(info, gc, b, nb)
+				for i := 0; i < nb; i++ {
+					bands[b][f*nb+i] *= gain[i]
+				}
+			}
+		}
+	}
+
+	s := NewSSRState(frame_length)
+	var out []float32
+	for f := 0; f < frames; f++ {
+		spec := make([]float32, frame_length)
+		for b := range bands {
+			block := make([]float32, 2*nb)
+			for i := range block {
+				if j := (f-1)*nb + i; j >= 0 && j < len(bands[b]) {
+					block[i] = bands[b][j]
+				}
+			}
+			fw.window(ONLY_LONG_SEQUENCE, SINE_WINDOW, SINE_WINDOW, block, block)
+			coefs := make([]float32, nb)
+			mdct(block, coefs)
+			for k := range coefs {
+				if b%2 == 1 {
+					spec[b*nb+nb-1-k] = coefs[k]
+				} else {
+					spec[b*nb+k] = coefs[k]
+				}
+			}
+		}
+
+		var gc *gain_control_data
+		for b, data := range gains[f] {
+			if data != nil && b > 0 {
+				gc = data
+			}
+		}
+		out = append(out, s.Decode(info, gc, spec)...)
+	}
+	return out
+}
+
+func TestSSRReconstruction(t *testing.T) {
+	const frames = 6
+	x := make([]float32, frames*1024)
+	for i := range x {
+		// One tone in each band
+		x[i] = float32(0.3*math.Sin(float64(i)*0.05) + 0.2*math.Sin(float64(i)*1.1) +
+			0.2*math.Sin(float64(i)*1.9) + 0.2*math.Sin(float64(i)*2.8))
+	}
+
+	gains := make([]map[int]*gain_control_data, frames)
+	for name, gains := range map[string][]map[int]*gain_control_data{
+		"no gain control": gains,
+		"gain control":    append(append([]map[int]*gain_control_data{}, gains[:2]...), map[int]*gain_control_data{1: ssrGainControl(ID_GAIN+3, 20)}, nil, nil, nil),
+	} {
+		y := ssrRoundtrip(x, gains)
+
+		// One band frame of MDCT delay and the PQF's
+		delay := 1024 + ssr_pqf_taps - 1
+		worst := 0.0
+		for i := 2 * 1024; i < len(y); i++ {
+			if e := math.Abs(float64(y[i] - x[i-delay])); e > worst {
+				worst = e
+			}
+		}
+		if worst > 5e-3 {
+			t.Errorf("%s: reconstruction error (%f) must be below 5e-3", name, worst)
+		}
+	}
+}
+
+func TestSSRGainControlDataParse(t *testing.T) {
+	buf := adtsFrame(2, 4, 1, func(w *bitWriter) {
+		w.write(ID_SCE, 3)
+		w.write(0, 4)   // element_instance_tag
+		w.write(100, 8) // global_gain
+		w.write(0, 1)   // ics_reserved_bit
+		w.write(ONLY_LONG_SEQUENCE, 2)
+		w.write(0, 1) // window_shape
+		w.write(2, 6) // max_sfb
+		w.write(0, 1) // predictor_data_present
+		w.write(ZERO_HCB, 4)
+		w.write(2, 5) // sect_len
+		w.write(0, 1) // pulse_data_present
+		w.write(0, 1) // tns_data_present
+		w.write(1, 1) // gain_control_data_present
+		w.write(2, 2) // max_band
+		for _, adjust := range [][][2]uint64{{{6, 16}}, {{3, 4}, {5, 30}}} {
+			w.write(uint64(len(adjust)), 3)
+			for _, a := range adjust {
+				w.write(a[0], 4) // alevcode
+				w.write(a[1], 5) // aloccode
+			}
+		}
+	})
+
+	adts, err := ParseADTS(buf)
+	if err != nil {
+		t.Fatalf("err (%s) must be nil", err)
+	}
+	if adts.Profile != AUDIO_OBJECT_TYPE_SSR {
+		t.Fatalf("Profile (%d) must be AAC SSR", adts.Profile)
+	}
+	sce, ok := adts.Raw_data_blocks[0].Elements[0].(*single_channel_element)
+	if !ok {
+		t.Fatalf("element (%T) must be a single_channel_element", adts.Raw_data_blocks[0].Elements[0])
+	}
+	gc := sce.Channel_stream.Gain_control_data
+	if gc == nil {
+		t.Fatalf("Gain_control_data must not be nil")
+	}
+	if gc.Max_band != 2 || len(gc.Adjust_num) != 3 {
+		t.Fatalf("Max_band (%d) must be 2 with 3 bands (%d)", gc.Max_band, len(gc.Adjust_num))
+	}
+	if gc.Adjust_num[1][0] != 1 || gc.Alevcode[1][0][0] != 6 || gc.Aloccode[1][0][0] != 16 {
+		t.Errorf("band 1 (%v, %v, %v) must be (1, 6, 16)", gc.Adjust_num[1], gc.Alevcode[1], gc.Aloccode[1])
+	}
+	if gc.Adjust_num[2][0] != 2 || gc.Alevcode[2][0][1] != 5 || gc.Aloccode[2][0][1] != 30 {
+		t.Errorf("band 2 (%v, %v, %v) must be (2, [3 5], [4 30])", gc.Adjust_num[2], gc.Alevcode[2], gc.Aloccode[2])
+	}
+}
@@ -0,0 +1,150 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"errors"
+	"io"
+
+	"github.com/Comcast/gaad/bitreader"
+)
+
+// ADTSReader parses the ADTS frames of a stream, e.g. a pipe or a network
+// connection, one at a time.  The parser reads each frame from the stream
+// through a bitreader.LimitedReader bounded to its aac_frame_length.
+type ADTSReader struct {
+	reader  *bitreader.StreamReader
+	options Options
+	err     error // returned by every call once the stream has ended
+}
+
+// Return a reader of the ADTS frames of r
+func NewADTSReader(r io.Reader) *ADTSReader {
+	return NewADTSReaderWithOptions(r, Options{})
+}
+
+// Return a reader of the ADTS frames of r, parsed with the given options
+func NewADTSReaderWithOptions(r io.Reader, options Options) *ADTSReader {
+	return &ADTSReader{
+		reader:  bitreader.NewStreamReader(r),
+		options: options,
+	}
+}
+
+// Next reads and parses the next frame.  Bytes before its syncword are
+// skipped.  A frame is confirmed by the syncword aac_frame_length bytes on,
+// or by the stream ending there.  One followed by other data, like a tag at
+// the end of the stream, is taken if it parses and skipped as a syncword in
+// other data if it doesn't.
+//
+// Next returns io.EOF at the end of the stream, io.ErrUnexpectedEOF if it
+// ends inside a frame and the error of r if r fails, later calls return
+// io.EOF or that error again.  A frame that can't be parsed is returned
+// with its error, the next call reads the frame after it.  The Offset of a
+// ParseError counts from the start of the frame, like ParseADTS.
+func (r *ADTSReader) Next() (*ADTS, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	for {
+		if !r.reader.HasBitLeft() {
+			// The failed read tells the end of the stream from an error
+			r.err = io.EOF
+			if _, err := r.reader.ReadBit(); err != bitreader.ErrReaderOutOfBounds {
+				r.err = err
+			}
+			return nil, r.err
+		}
+
+		// syncword and aac_frame_length of the fixed and variable headers,
+		// zero past the end of the stream
+		header, _ := r.reader.PeekBits(56)
+		frame_length := uint((header >> 13) & 0x1fff)
+		if header>>44 != 0xfff || frame_length < 7 {
+			r.reader.SkipBytes(1)
+			continue
+		}
+
+		next, _ := r.reader.PeekBytes(frame_length + 2)
+		if uint(len(next)) < frame_length {
+			err := r.reader.SkipBytes(frame_length)
+			if err == bitreader.ErrReaderOutOfBounds {
+				r.err = io.EOF
+				return nil, io.ErrUnexpectedEOF
+			}
+			r.err = err
+			return nil, err
+		}
+		if uint(len(next)) == frame_length || uint(len(next)) == frame_length+2 &&
+			uint(next[frame_length])<<4|uint(next[frame_length+1])>>4 == 0xfff {
+			return r.parse(frame_length)
+		}
+
+		// Other data after the frame leaves it unconfirmed.  It's a
+		// syncword in other data if a confirmed frame starts inside it or
+		// it doesn't parse, tried from the buffer so the frames after it
+		// aren't lost.
+		if !r.confirmed_within(frame_length) {
+			next, _ = r.reader.PeekBytes(frame_length)
+			if adts, err := ParseADTSWithOptions(next, r.options); err == nil {
+				r.reader.SkipBytes(frame_length)
+				return adts, nil
+			}
+		}
+		r.reader.SkipBytes(1)
+	}
+}
+
+// Whether a frame confirmed by the syncword after it, or by the stream
+// ending there, starts within the next n bytes after the current one
+func (r *ADTSReader) confirmed_within(n uint) bool {
+	for at := uint(1); at < n; at++ {
+		header, _ := r.reader.PeekBytes(at + 7)
+		if uint(len(header)) < at+7 {
+			return false
+		}
+		header = header[at:]
+		frame_length := uint(header[3]&3)<<11 | uint(header[4])<<3 | uint(header[5])>>5
+		if header[0] != 0xff || header[1]>>4 != 0xf || frame_length < 7 {
+			continue
+		}
+
+		next, _ := r.reader.PeekBytes(at + frame_length + 2)
+		end := at + frame_length
+		if uint(len(next)) == end || uint(len(next)) == end+2 && next[end] == 0xff && next[end+1]>>4 == 0xf {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses the frame of frame_length bytes at the reader from the stream
+func (r *ADTSReader) parse(frame_length uint) (*ADTS, error) {
+	start := r.reader.BitPos()
+	adts := &ADTS{options: r.options}
+	adts.reader = bitreader.NewLimitedReader(r.reader, 8*frame_length)
+	err := adts.adts_frame()
+
+	// The next frame starts after this one, whatever was parsed of it
+	r.reader.SkipBits(uint(start + 8*uint64(frame_length) - r.reader.BitPos()))
+
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		perr.Offset -= start
+	}
+	return adts, err
+}
@@ -0,0 +1,171 @@
+/**
+* Copyright 2016 Comcast Cable Communications Management, LLC
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+* http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package gaad
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+func TestADTSReader(t *testing.T) {
+	cpe := adtsFrame(1, 3, 2, func(w *bitWriter) {
+		w.write(ID_CPE, 3)
+		w.write(0, 4) // element_instance_tag
+		w.write(0, 1) // common_window
+		writeLcIcs(w, 0)
+		writeLcIcs(w, 0)
+	})
+	fill := adtsFrame(1, 3, 1, func(w *bitWriter) {
+		writeFillData(w, 0, 0xa5)
+	})
+	reserved := adtsFrame(1, 3, 2, func(w *bitWriter) {
+		w.write(ID_CPE, 3)
+		w.write(0, 4) // element_instance_tag
+		w.write(0, 1) // common_window
+		writeLcIcs(w, 1)
+	})
+
+	// Garbage, a syncword with a frame length under the header's and
+	// frames in between the ones to find, the last one cut short
+	var stream []byte
+	stream = append(stream, 0x00, 0xff, 0x12)
+	stream = append(stream, cpe...)
+	stream = append(stream, 0xff, 0xf1, 0x4c, 0x80, 0x00, 0x1f, 0xfc)
+	stream = append(stream, fill...)
+	stream = append(stream, reserved...)
+	stream = append(stream, fill[:len(fill)-1]...)
+	reader := NewADTSReader(iotest.OneByteReader(bytes.NewReader(stream)))
+
+	// Test 1: Frames are found after garbage and parsed from the stream
+	// like from memory
+	adts, err := reader.Next()
+	if err != nil || len(adts.Channel_pair_elements) != 1 {
+		t.Fatalf("Next() returned %v, want the channel_pair_element frame", err)
+	}
+	if want, _ := ParseADTS(cpe); !reflect.DeepEqual(parsedFrame(adts), parsedFrame(want)) {
+		t.Errorf("Next() differs from ParseADTS()")
+	}
+	adts, err = reader.Next()
+	if err != nil || len(adts.Fill_elements) != 1 {
+		t.Fatalf("Next() returned %v, want the fill_element frame", err)
+	}
+
+	// Test 2: A frame that can't be parsed doesn't end the stream
+	adts, err = reader.Next()
+	if !errors.Is(err, ErrReserved) || adts == nil {
+		t.Errorf("Next() returned %v, want ErrReserved", err)
+	}
+	if _, want := ParseADTS(reserved); err == nil || err.Error() != want.Error() {
+		t.Errorf("Next() returned %v, want the error of ParseADTS(), %v", err, want)
+	}
+
+	// Test 3: The stream ends inside a frame, then it has ended
+	if _, err = reader.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Next() returned %v, want io.ErrUnexpectedEOF", err)
+	}
+	if _, err = reader.Next(); err != io.EOF {
+		t.Errorf("Next() returned %v, want io.EOF", err)
+	}
+}
+
+func TestADTSReaderEOF(t *testing.T) {
+	fill := adtsFrame(1, 3, 1, func(w *bitWriter) {
+		writeFillData(w, 0, 0xa5)
+	})
+	reader := NewADTSReaderWithOptions(bytes.NewReader(append(fill, 0x00)), Options{Mode: PARSE_STRICT})
+
+	if _, err := reader.Next(); err != nil {
+		t.Fatalf("Next() returned %v", err)
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Next() returned %v, want io.EOF", err)
+	}
+}
+
+func TestADTSReaderFalseSync(t *testing.T) {
+	cpe := adtsFrame(1, 3, 2, func(w *bitWriter) {
+		w.write(ID_CPE, 3)
+		w.write(0, 4) // element_instance_tag
+		w.write(0, 1) // common_window
+		writeLcIcs(w, 0)
+		writeLcIcs(w, 0)
+	})
+	fill := adtsFrame(1, 3, 1, func(w *bitWriter) {
+		writeFillData(w, 0, 0xa5)
+	})
+
+	// A header in the garbage before the frames whose frame_length ends
+	// inside the first one
+	garbage := &bitWriter{buf: append([]byte(nil), fill[:7]...)}
+	garbage.patch(30, 7+9, 13)
+	if cpe[9] == 0xff && cpe[10]>>4 == 0xf {
+		t.Fatalf("the false frame must not end at a syncword")
+	}
+
+	var stream []byte
+	stream = append(stream, garbage.buf...)
+	stream = append(stream, cpe...)
+	stream = append(stream, fill...)
+	reader := NewADTSReader(iotest.OneByteReader(bytes.NewReader(stream)))
+
+	// The false syncword is skipped, not the frames it covers
+	adts, err := reader.Next()
+	if err != nil || len(adts.Channel_pair_elements) != 1 {
+		t.Fatalf("Next() returned %v, want the channel_pair_element frame", err)
+	}
+	adts, err = reader.Next()
+	if err != nil || len(adts.Fill_elements) != 1 {
+		t.Fatalf("Next() returned %v, want the fill_element frame", err)
+	}
+	if _, err = reader.Next(); err != io.EOF {
+		t.Errorf("Next() returned %v, want io.EOF", err)
+	}
+}
+
+func TestADTSReaderTrailingData(t *testing.T) {
+	fill := adtsFrame(1, 3, 1, func(w *bitWriter) {
+		writeFillData(w, 0, 0xa5)
+	})
+	tag := append([]byte("TAG"), bytes.Repeat([]byte{' '}, 125)...)
+
+	for _, test := range []struct {
+		name   string
+		stream [][]byte
+		frames int
+		end    error
+	}{
+		{"tag after the last frame", [][]byte{fill, fill, tag}, 2, io.EOF},
+		{"data between frames", [][]byte{fill, tag[:5], fill}, 2, io.EOF},
+		{"padding", [][]byte{fill, make([]byte, 3)}, 1, io.EOF},
+		{"cut in the syncword", [][]byte{fill, fill[:1]}, 1, io.EOF},
+		{"cut in the next header", [][]byte{fill, fill[:6]}, 1, io.ErrUnexpectedEOF},
+	} {
+		reader := NewADTSReader(iotest.OneByteReader(bytes.NewReader(bytes.Join(test.stream, nil))))
+		for i := 0; i < test.frames; i++ {
+			if adts, err := reader.Next(); err != nil || len(adts.Fill_elements) != 1 {
+				t.Fatalf("%s: Next() returned %v, want fill_element frame %d", test.name, err, i)
+			}
+		}
+		if _, err := reader.Next(); err != test.end {
+			t.Errorf("%s: Next() returned %v, want %v", test.name, err, test.end)
+		}
+	}
+}
@@ -114,6 +114,63 @@ var swb_offset_128_8 = []uint16{
 	0, 4, 8, 12, 16, 20, 24, 28, 36, 44, 52, 60, 72, 88, 108, 128,
 }
 
+// Scalefactor bands of the 512 and 480 sample frames of ER AAC LD and ELD,
+// which only have long windows and are only defined from 22050 to 48000 Hz
+var num_swb_512_window = []uint8{0, 0, 0, 36, 36, 37, 31, 31, 0, 0, 0, 0}
+var num_swb_480_window = []uint8{0, 0, 0, 35, 35, 37, 30, 30, 0, 0, 0, 0}
+
+var swb_offset_512_48 = []uint16{
+	0, 4, 8, 12, 16, 20, 24, 28, 32, 36, 40, 44, 48, 52, 56,
+	60, 68, 76, 84, 92, 100, 112, 124, 136, 148, 164, 184, 208, 236,
+	268, 300, 332, 364, 396, 428, 460, 512,
+}
+
+var swb_offset_512_32 = []uint16{
+	0, 4, 8, 12, 16, 20, 24, 28, 32, 36, 40, 44, 48, 52, 56,
+	64, 72, 80, 88, 96, 108, 120, 132, 144, 160, 176, 192, 212, 236,
+	260, 288, 320, 352, 384, 416, 448, 480, 512,
+}
+
+var swb_offset_512_24 = []uint16{
+	0, 4, 8, 12, 16, 20, 24, 28, 32, 36, 40, 44, 52, 60, 68,
+	80, 92, 104, 120, 140, 164, 192, 224, 256, 288, 320, 352, 384, 416,
+	448, 480, 512,
+}
+
+var swb_offset_480_48 = []uint16{
+	0, 4, 8, 12, 16, 20, 24, 28, 32, 36, 40, 44, 48, 52, 56,
+	64, 72, 80, 88, 96, 108, 120, 132, 144, 156, 172, 188, 212, 240,
+	272, 304, 336, 368, 400, 432, 480,
+}
+
+var swb_offset_480_32 = []uint16{
+	0, 4, 8, 12, 16, 20, 24, 28, 32, 36, 40, 44, 48, 52, 56,
+	60, 64, 72, 80, 88, 96, 104, 112, 124, 136, 148, 164, 180, 200,
+	224, 256, 288, 320, 352, 384, 416, 448, 480,
+}
+
+var swb_offset_480_24 = []uint16{
+	0, 4, 8, 12, 16, 20, 24, 28, 32, 36, 40, 44, 52, 60, 68,
+	80, 92, 104, 120, 140, 164, 192, 224, 256, 288, 320, 352, 384, 416,
+	448, 480,
+}
+
+var swb_offset_512_window = [][]uint16{
+	nil, nil, nil, // 96000, 88200, 64000
+	swb_offset_512_48, swb_offset_512_48, // 48000, 44100
+	swb_offset_512_32,                    // 32000
+	swb_offset_512_24, swb_offset_512_24, // 24000, 22050
+	nil, nil, nil, nil, // 16000, 12000, 11025, 8000
+}
+
+var swb_offset_480_window = [][]uint16{
+	nil, nil, nil, // 96000, 88200, 64000
+	swb_offset_480_48, swb_offset_480_48, // 48000, 44100
+	swb_offset_480_32,                    // 32000
+	swb_offset_480_24, swb_offset_480_24, // 24000, 22050
+	nil, nil, nil, nil, // 16000, 12000, 11025, 8000
+}
+
 var swb_offset_long_window = [][]uint16{
 	swb_offset_1024_96, swb_offset_1024_96, // 96000, 88200
 	swb_offset_1024_64,                     // 64000
@@ -136,7 +193,7 @@ var swb_offset_short_window = [][]uint16{
 ////////////////////////////////////////////////////////////////////////////////
 // 4.5.2.3.4 - Scalefactor bands and grouping
 ////////////////////////////////////////////////////////////////////////////////
-func window_grouping(info *ics_info, sfi uint8, framelength uint16) {
+func window_grouping(mem *frame_memory, info *ics_info, sfi uint8, framelength uint16) {
 	idx := 1
 	if framelength == 960 {
 		idx = 0
@@ -146,49 +203,56 @@ func window_grouping(info *ics_info, sfi uint8, framelength uint16) {
 	case ONLY_LONG_SEQUENCE, LONG_START_SEQUENCE, LONG_STOP_SEQUENCE:
 		info.num_windows = 1
 		info.num_window_groups = 1
-		info.window_group_length = make([]uint8, info.num_window_groups)
+		info.window_group_length = mem.uint8s.make(int(info.num_window_groups))
 		info.window_group_length[info.num_window_groups-1] = 1
 		info.num_swb = num_swb_long_windows[idx][sfi]
+		swb_offset := swb_offset_long_window[sfi]
+		switch framelength {
+		case 512:
+			info.num_swb, swb_offset = num_swb_512_window[sfi], swb_offset_512_window[sfi]
+		case 480:
+			info.num_swb, swb_offset = num_swb_480_window[sfi], swb_offset_480_window[sfi]
+		}
 
-		t := make([]uint16, len(swb_offset_long_window[sfi][:info.num_swb]))
-		copy(t, swb_offset_long_window[sfi][:info.num_swb])
-		info.sect_sfb_offset = append(info.sect_sfb_offset, t)
+		t := mem.uint16s.make_cap(int(info.num_swb), int(info.num_swb)+1)
+		copy(t, swb_offset[:info.num_swb])
+		info.sect_sfb_offset = mem.uint16s_2d.append(info.sect_sfb_offset, t)
 
-		t = make([]uint16, len(swb_offset_long_window[sfi][:info.num_swb]))
-		copy(t, swb_offset_long_window[sfi][:info.num_swb])
+		t = mem.uint16s.make_cap(int(info.num_swb), int(info.num_swb)+1)
+		copy(t, swb_offset[:info.num_swb])
 		info.swb_offset = t
 
 		// Special cases for 960's final values so we don't have to duplicate tables
-		info.sect_sfb_offset[0] = append(info.sect_sfb_offset[0], framelength)
-		info.swb_offset = append(info.swb_offset, framelength)
+		info.sect_sfb_offset[0] = mem.uint16s.append(info.sect_sfb_offset[0], framelength)
+		info.swb_offset = mem.uint16s.append(info.swb_offset, framelength)
 	case EIGHT_SHORT_SEQUENCE:
 		info.num_windows = 8
 		info.num_window_groups = 1
-		info.window_group_length = make([]uint8, info.num_window_groups)
+		info.window_group_length = mem.uint8s.make_cap(int(info.num_window_groups), int(info.num_windows))
 		info.window_group_length[info.num_window_groups-1] = 1
 		info.num_swb = num_swb_short_window[sfi]
 
-		t := make([]uint16, len(swb_offset_short_window[sfi][:info.num_swb]))
+		t := mem.uint16s.make_cap(int(info.num_swb), int(info.num_swb)+1)
 		copy(t, swb_offset_short_window[sfi][:info.num_swb])
 		info.swb_offset = t
-		info.swb_offset = append(info.swb_offset, framelength/8)
+		info.swb_offset = mem.uint16s.append(info.swb_offset, framelength/8)
 
 		for i := uint8(0); i < info.num_windows-1; i++ {
 			bit := 6 - i
 			if ((info.Scale_factor_grouping >> bit) & 0x1) == 0 {
 				info.num_window_groups++
-				info.window_group_length = append(info.window_group_length, 1)
+				info.window_group_length = mem.uint8s.append(info.window_group_length, 1)
 			} else {
 				info.window_group_length[info.num_window_groups-1]++
 			}
 		}
 
-		info.sect_sfb_offset = make([][]uint16, info.num_window_groups)
+		info.sect_sfb_offset = mem.uint16s_2d.make(int(info.num_window_groups))
 		for g := range info.sect_sfb_offset {
 			sect_sfb := 0
 			offset := uint16(0)
 
-			info.sect_sfb_offset[g] = make([]uint16, info.num_swb)
+			info.sect_sfb_offset[g] = mem.uint16s.make_cap(int(info.num_swb), int(info.num_swb)+1)
 			for i := range info.sect_sfb_offset[g] {
 				width := swb_offset_short_window[sfi][i+1] - swb_offset_short_window[sfi][i]
 				// account for special table cases for 120
@@ -200,7 +264,7 @@ func window_grouping(info *ics_info, sfi uint8, framelength uint16) {
 				sect_sfb++
 				offset += width
 			}
-			info.sect_sfb_offset[g] = append(info.sect_sfb_offset[g], offset)
+			info.sect_sfb_offset[g] = mem.uint16s.append(info.sect_sfb_offset[g], offset)
 		}
 	}
 }
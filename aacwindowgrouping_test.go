@@ -15,7 +15,7 @@ func TestWindowGroupingRaceCondition(t *testing.T) {
 			defer wg.Done()
 			info := &ics_info{}
 			info.Window_sequence = ONLY_LONG_SEQUENCE
-			window_grouping(info, 0, 960)
+			window_grouping(new(frame_memory), info, 0, 960)
 
 		}()
 	}
@@ -30,7 +30,7 @@ func TestRaceConditionEightShort(t *testing.T) {
 			defer wg.Done()
 			info := &ics_info{}
 			info.Window_sequence = EIGHT_SHORT_SEQUENCE
-			window_grouping(info, 0, 960)
+			window_grouping(new(frame_memory), info, 0, 960)
 
 		}()
 	}
@@ -1,51 +1,122 @@
 package bitreader
 
 import (
+	"encoding/binary"
 	"fmt"
-	"math"
 )
 
-type BitReader struct {
-	bytes            []byte
-	currentByte      byte
-	currentByteIndex uint
-	posInCurrentByte uint
-	length           uint
-}
+// Reads of up to MaxCacheBits bits are served from the cache of a
+// BitReader with at most one refill
+const MaxCacheBits = 57
 
-var netmasks = []byte{
-	0x01, // 0000 0001
-	0x02, // 0000 0010
-	0x04, // 0000 0100
-	0x08, // 0000 1000
-	0x10, // 0001 0000
-	0x20, // 0010 0000
-	0x40, // 0100 0000
-	0x80, // 1000 0000
+type BitReader struct {
+	bytes  []byte
+	length uint
+	pos    uint // bits read since the start of the input
+
+	// The bits of the input the reader is bounded to, all of it unless it
+	// was returned by Limit
+	start uint
+	end   uint
+
+	// The bits following pos, left aligned.  pos+cacheBits is always at a
+	// byte boundary.  Bits below the cacheBits counted are either zero or
+	// the input's own, so refills can OR whole words over them.
+	cache     uint64
+	cacheBits uint
+
+	// The first read past the end of the input and the bit it started at
+	err       error
+	errOffset uint64
 }
 
 func NewBitReader(input []byte) *BitReader {
 	return &BitReader{
-		bytes:            input,
-		currentByte:      input[0],
-		currentByteIndex: 0,
-		posInCurrentByte: 7,
-		length:           uint(len(input)),
+		bytes:  input,
+		length: uint(len(input)),
+		end:    uint(len(input)) * 8,
+	}
+}
+
+// Fill the cache with whole bytes of the input, at least MaxCacheBits bits
+// of it unless the input ends first
+func (p *BitReader) refill() {
+	next := (p.pos + p.cacheBits) >> 3
+	if next+8 <= p.length {
+		p.cache |= binary.BigEndian.Uint64(p.bytes[next:]) >> p.cacheBits
+		p.cacheBits += (64 - p.cacheBits) &^ 7
+		return
+	}
+	for p.cacheBits <= 56 && next < p.length {
+		p.cache |= uint64(p.bytes[next]) << (56 - p.cacheBits)
+		p.cacheBits += 8
+		next++
 	}
 }
 
+// The next n <= MaxCacheBits bits, zero past the end of the input
+func (p *BitReader) peek(n uint) uint64 {
+	if p.cacheBits < n {
+		p.refill()
+	}
+	return p.cache >> (64 - n)
+}
+
+// Move past n bits already in the cache
+func (p *BitReader) consume(n uint) {
+	p.cache <<= n
+	p.cacheBits -= n
+	p.pos += n
+}
+
+// Move to bit pos of the input, pos is at most the end of the reader
+func (p *BitReader) seek(pos uint) {
+	p.pos = pos &^ 7
+	p.cache = 0
+	p.cacheBits = 0
+	if pos&7 != 0 {
+		p.refill()
+		p.consume(pos & 7)
+	}
+}
+
+// Records the first read past the end of the input, the reader doesn't move
+// on a failed read
+func (p *BitReader) fail() error {
+	if p.err == nil {
+		p.err = ErrReaderOutOfBounds
+		p.errOffset = uint64(p.pos)
+	}
+	return ErrReaderOutOfBounds
+}
+
+// Return an error unless n bits are left
+func (p *BitReader) need(n uint) error {
+	if p.BitsLeft() < n {
+		return p.fail()
+	}
+	return nil
+}
+
+// Return ErrReaderOutOfBounds if any read or skip has gone past the end of
+// the input since the reader was created or reset
+func (p *BitReader) Err() error {
+	return p.err
+}
+
+// Return the bit offset of the first read past the end of the input
+func (p *BitReader) ErrOffset() uint64 {
+	return p.errOffset
+}
+
 // Return the total number of bits left in the stream
 func (p *BitReader) BitsLeft() uint {
-	return ((p.BytesLeft() - 1) * 8) + (uint(p.posInCurrentByte) + 1)
+	return p.end - p.pos
 }
 
 // Return the number of bytes left (even if partially read)
 func (p *BitReader) BytesLeft() uint {
-	bytesLeft := p.length - p.currentByteIndex
-	if bytesLeft < 0 {
-		return 0
-	}
-	return uint(bytesLeft)
+	return (p.end - p.pos&^7) / 8
 }
 
 func (p *BitReader) ReadBitAsBool() (bool, error) {
@@ -59,92 +130,102 @@ func (p *BitReader) ReadBitAsBool() (bool, error) {
 	return true, nil
 }
 
-// Return the number of bits as an unsigned integer
+// Return the number of bits as an unsigned integer, only the last 64 bits
+// are kept if n is over 64
 func (p *BitReader) ReadBitsAsUInt(n uint) (uint, error) {
-	var result uint
-	for i := uint(0); i < n; i++ {
-		bit, err := p.ReadBit()
-		if err != nil {
-			return 0, err
+	if err := p.need(n); err != nil {
+		return 0, err
+	}
+	if n <= MaxCacheBits {
+		result := p.peek(n)
+		p.consume(n)
+		return uint(result), nil
+	}
+
+	var result uint64
+	for n > 0 {
+		take := n
+		if take > 32 {
+			take = 32
 		}
-		result += uint(bit) << uint(n-i-1)
+		result = result<<take | p.peek(take)
+		p.consume(take)
+		n -= take
 	}
-	return result, nil
+	return uint(result), nil
 }
 
 // Return the number of bits as an unsigned integer
 func (p *BitReader) ReadBitsAsUInt8(n uint) (uint8, error) {
-	var result uint8
-	for i := uint(0); i < n; i++ {
-		bit, err := p.ReadBit()
-		if err != nil {
-			return 0, err
-		}
-		result += uint8(bit) << uint(n-i-1)
-	}
-	return result, nil
+	result, err := p.ReadBitsAsUInt(n)
+	return uint8(result), err
 }
 
 // Return the number of bits as an unsigned integer
 func (p *BitReader) ReadBitsAsUInt32(n uint) (uint32, error) {
-	var result uint32
-	for i := uint(0); i < n; i++ {
-		bit, err := p.ReadBit()
-		if err != nil {
-			return 0, err
-		}
-		result += uint32(bit) << uint(n-i-1)
-	}
-	return result, nil
+	result, err := p.ReadBitsAsUInt(n)
+	return uint32(result), err
 }
 
 // Return the number of bits as an unsigned integer
 func (p *BitReader) ReadBitsAsUInt16(n uint) (uint16, error) {
-	var result uint16
-	for i := uint(0); i < n; i++ {
-		bit, err := p.ReadBit()
-		if err != nil {
-			return 0, err
-		}
-		result += uint16(bit) << uint(n-i-1)
-	}
-	return result, nil
+	result, err := p.ReadBitsAsUInt(n)
+	return uint16(result), err
 }
 
 // Return the number of bits as a signed integer
 func (p *BitReader) ReadBitsAsInt(n uint) (int, error) {
-	var result int
-	for i := uint(0); i < n; i++ {
-		bit, err := p.ReadBit()
-		if err != nil {
-			return 0, err
-		}
-		result += int(bit) << uint(n-i-1)
-	}
-	return result, nil
+	result, err := p.ReadBitsAsUInt(n)
+	return int(result), err
 }
 
-// Return n number of bits into a byte array
+// Return n number of bits into a byte array.  Whole bytes read from a byte
+// boundary are the input's own and don't allocate, they must not be
+// modified.  Other reads allocate the array, AppendBitsToByteArray reads
+// them into one the caller keeps.
 func (p *BitReader) ReadBitsToByteArray(n uint) ([]byte, error) {
-	result := make([]byte, int(math.Ceil(float64(n)/8)))
-
-	temp := make([]byte, n)
-	for i := uint(0); i < n; i++ {
-		bit, err := p.ReadBit()
-		if err != nil {
-			return nil, err
-		}
-		temp[i] = bit
+	if err := p.need(n); err != nil {
+		return nil, err
+	}
+	if p.pos&7 == 0 && n&7 == 0 {
+		start, end := p.pos>>3, (p.pos+n)>>3
+		p.seek(p.pos + n)
+		return p.bytes[start:end:end], nil
 	}
+	return p.AppendBitsToByteArray(make([]byte, 0, (n+7)/8), n)
+}
 
-	bitmask := 0
-	for i := n; i > 0; i-- {
-		index := len(result) - (bitmask / 8) - 1
-		result[index] |= temp[i-1] << uint(bitmask%8)
-		bitmask++
+// Append n number of bits to dst the way ReadBitsToByteArray returns them,
+// right aligned so the first byte holds the n%8 bits that don't make a
+// whole byte.  It doesn't allocate if dst has room for them.
+func (p *BitReader) AppendBitsToByteArray(dst []byte, n uint) ([]byte, error) {
+	if err := p.need(n); err != nil {
+		return dst, err
+	}
+	if r := n & 7; r != 0 {
+		dst = append(dst, byte(p.peek(r)))
+		p.consume(r)
+		n -= r
 	}
 
-	return result, nil
+	// Whole bytes are copied if the reader is byte aligned
+	if p.pos&7 == 0 {
+		start := p.pos >> 3
+		dst = append(dst, p.bytes[start:start+n>>3]...)
+		p.seek(p.pos + n)
+		return dst, nil
+	}
+	for ; n >= 56; n -= 56 {
+		var word [8]byte
+		binary.BigEndian.PutUint64(word[:], p.peek(56))
+		p.consume(56)
+		dst = append(dst, word[1:]...)
+	}
+	for ; n > 0; n -= 8 {
+		dst = append(dst, byte(p.peek(8)))
+		p.consume(8)
+	}
+	return dst, nil
 }
 
 // Return n number of bits
@@ -153,172 +234,107 @@ func (p *BitReader) ReadBits(n uint) (byte, error) {
 		fmt.Printf("ReadBits(n) can only handle upto 8 bits, use ReadBitsToByteArray(n)")
 	}
 
-	var r byte
-
-	for i := n; i > 0; i-- {
-		r <<= 1
-		bit, err := p.ReadBit()
-		if err != nil {
-			return 0, err
-		}
-		r |= bit
-	}
-	return r, nil
+	result, err := p.ReadBitsAsUInt(n)
+	return byte(result), err
 }
 
 // Return the next bit from the buffer
 func (p *BitReader) ReadBit() (byte, error) {
 	if p.BitsLeft() == 0 {
-		return 0, fmt.Errorf("Not enough bits left to read")
+		return 0, p.fail()
 	}
-	r := (p.currentByte & netmasks[p.posInCurrentByte]) >> p.posInCurrentByte
-	p.SkipBits(1)
+	r := byte(p.peek(1))
+	p.consume(1)
 	return r, nil
 }
 
-// Return n number of bytes read from the buffer
+// Return n number of bytes read from the buffer, starting at the current
+// byte even if it's partially read
 func (p *BitReader) ReadBytes(n uint) ([]byte, error) {
-	arr := make([]byte, n)
-
 	if p.BytesLeft() < n {
-		return nil, fmt.Errorf("Not enough bytes left to read")
-	} else {
-		copy(arr, p.bytes[p.currentByteIndex:p.currentByteIndex+n])
-		p.currentByteIndex += n
-		p.posInCurrentByte = 7
+		return nil, p.fail()
 	}
-
+	start := p.pos >> 3
+	arr := make([]byte, n)
+	copy(arr, p.bytes[start:start+n])
+	p.seek((start + n) * 8)
 	return arr, nil
 }
 
 // Read Unsigned Exp-Golomb
 func (p *BitReader) ReadUE() (uint32, error) {
-	zeros := 0
-	val := uint32(0)
-
-	// Count leading zeros
-	for {
-		bit, err := p.ReadBit()
-		if err != nil {
-			return 0, err
-		}
-		if bit == 0 {
-			zeros++
-		} else {
-			break
-		}
-	}
-
-	if zeros == 0 {
-		return 0, nil
-	} else {
-		val = 1
-	}
-
-	// Shift bits
-	for {
-		bit, err := p.ReadBit()
-		if err != nil {
-			return 0, err
-		}
-		val <<= 1
-		val |= uint32(bit)
-		zeros--
-		if zeros == 0 {
-			return val - 1, nil // Subtract onebecause we stole a bit for 0.
-		}
-	}
-
-	// Should not get here
-	return 0, ErrExpGolombDecode
+	return readUE(p)
 }
 
 // Read Signed Exp-Golomb
 func (p *BitReader) ReadSE() (int32, error) {
-	u, err := p.ReadUE()
-	if err != nil {
-		return 0, err
-	}
-	s := int32(u)
-	sign := ((s & 0x1) << 1) - 1
-	return ((s >> 1) + (s & 0x1)) * sign, nil
+	return readSE(p)
 }
 
-// Return n number of bits from the buffer, do not adv. the cursor
+// Return n number of bits from the buffer, do not adv. the cursor.  Bits
+// past the end of the input are zero.
 func (p *BitReader) PeekBits(n uint) (uint, error) {
-	var r uint
-
-	currentByte := p.currentByte
-	posInCurrentByte := p.posInCurrentByte
-	currentByteIndex := p.currentByteIndex
-	length := p.length
-	for i := n; i > 0; i-- {
-		r <<= 1
-		bit := (currentByte & netmasks[posInCurrentByte]) >> posInCurrentByte
-		r |= uint(bit)
-
-		if posInCurrentByte > 0 {
-			posInCurrentByte--
-		} else {
-			currentByteIndex++
-			if currentByteIndex <= length-1 {
-				currentByte = p.bytes[currentByteIndex]
-			} else {
-				currentByte = byte(0)
-			}
-			posInCurrentByte = 7
+	if n <= MaxCacheBits {
+		r := p.peek(n)
+		if left := p.BitsLeft(); n > left {
+			r &^= 1<<(n-left) - 1
 		}
+		return uint(r), nil
 	}
 
-	return r, nil
+	// Longer peeks go a byte at a time and keep the last 64 bits
+	var r uint64
+	for i := uint(0); i < n; i += 8 {
+		take := n - i
+		if take > 8 {
+			take = 8
+		}
+		pos := p.pos + i
+		window := uint64(p.byteAt(pos>>3))<<8 | uint64(p.byteAt(pos>>3+1))
+		r = r<<take | window>>(16-pos&7-take)&(1<<take-1)
+	}
+	return uint(r), nil
+}
+
+// The byte at index i of the input, zero past the end of the reader
+func (p *BitReader) byteAt(i uint) byte {
+	switch {
+	case i*8 >= p.end:
+		return 0
+	case i*8+8 > p.end:
+		return p.bytes[i] &^ (0xff >> (p.end - i*8))
+	}
+	return p.bytes[i]
 }
 
 // Return the next bit from the buffer, do not adv. the cursor
 func (p *BitReader) PeekBit() (byte, error) {
 	if p.BitsLeft() == 0 {
-		return 0, fmt.Errorf("Not enough bits left to read")
+		return 0, ErrReaderOutOfBounds
 	}
-	r := (p.currentByte & netmasks[p.posInCurrentByte]) >> p.posInCurrentByte
-	return r, nil
+	return byte(p.peek(1)), nil
 }
 
 // Skip n number of bits in the buffer
 func (p *BitReader) SkipBits(n uint) error {
-	if p.BitsLeft() < n {
-		return fmt.Errorf("Not enough bits left to skip")
+	if err := p.need(n); err != nil {
+		return err
+	}
+	if n <= p.cacheBits {
+		p.consume(n)
 	} else {
-		for i := n; i > 0; i-- {
-			if p.posInCurrentByte > 0 {
-				p.posInCurrentByte--
-			} else {
-				p.currentByteIndex++
-				if p.currentByteIndex <= p.length-1 {
-					p.currentByte = p.bytes[p.currentByteIndex]
-				} else {
-					p.currentByte = byte(0)
-				}
-				p.posInCurrentByte = 7
-			}
-		}
+		p.seek(p.pos + n)
 	}
-
 	return nil
 }
 
-// Skip n number of bytes in the buffer
+// Skip n number of bytes in the buffer, starting at the current byte even
+// if it's partially read
 func (p *BitReader) SkipBytes(n uint) error {
 	if p.BytesLeft() < n {
-		return fmt.Errorf("Not enough bytes left to skip")
-	} else {
-		p.currentByteIndex += n
-		p.posInCurrentByte = 7
-		if p.currentByteIndex < p.length {
-
-			p.currentByte = p.bytes[p.currentByteIndex]
-		} else {
-			p.currentByte = byte(0)
-		}
+		return p.fail()
 	}
+	p.seek((p.pos>>3 + n) * 8)
 	return nil
 }
 
@@ -339,18 +355,90 @@ func (p *BitReader) HasBytesLeft(n uint) bool {
 
 // Reset the stream reader back to the start of the buffer
 func (p *BitReader) Reset() {
-	p.currentByteIndex = 0
-	p.currentByte = p.bytes[0]
-	p.posInCurrentByte = 7
+	p.seek(p.start)
+	p.err = nil
+	p.errOffset = 0
 }
 
-// Pefrom byte alignment (skip any remaining bits of current byte)
+// Pefrom byte alignment (skip any remaining bits of current byte), stopping
+// at the end of a reader returned by Limit that ends within a byte
 func (p *BitReader) ByteAlign() {
-	if p.posInCurrentByte != 7 {
-		p.SkipBits(p.posInCurrentByte + 1)
+	if r := p.pos & 7; r != 0 {
+		n := 8 - r
+		if n > p.end-p.pos {
+			n = p.end - p.pos
+		}
+		p.consume(n)
 	}
 }
 
 func (p *BitReader) ByteOffset() uint64 {
-	return uint64(p.currentByteIndex)
+	return uint64(p.pos >> 3)
+}
+
+// Return the number of bits read since the start of the input, a reader
+// returned by Limit counts from the start of its parent's
+func (p *BitReader) BitPos() uint64 {
+	return uint64(p.pos)
+}
+
+// Move to bit pos of the input, an error if it's outside of the reader
+func (p *BitReader) SeekBit(pos uint64) error {
+	if pos < uint64(p.start) || pos > uint64(p.end) {
+		return p.fail()
+	}
+	if pos >= uint64(p.pos) && pos-uint64(p.pos) <= uint64(p.cacheBits) {
+		p.consume(uint(pos) - p.pos)
+	} else {
+		p.seek(uint(pos))
+	}
+	return nil
+}
+
+// Mark is a snapshot of a BitReader to return to with Restore
+type Mark struct {
+	pos       uint
+	cache     uint64
+	cacheBits uint
+	err       error
+	errOffset uint64
+}
+
+// Return a snapshot of the position and error of the reader
+func (p *BitReader) Mark() Mark {
+	return Mark{
+		pos:       p.pos,
+		cache:     p.cache,
+		cacheBits: p.cacheBits,
+		err:       p.err,
+		errOffset: p.errOffset,
+	}
+}
+
+// Return to a snapshot of the reader, reads past the end since it was taken
+// are forgotten
+func (p *BitReader) Restore(m Mark) {
+	p.pos = m.pos
+	p.cache = m.cache
+	p.cacheBits = m.cacheBits
+	p.err = m.err
+	p.errOffset = m.errOffset
+}
+
+// Return a reader of the next n bits, reads past them fail with
+// ErrReaderOutOfBounds even if the input goes on.  The reader doesn't
+// move, seek it to the BitPos of the one returned to carry on after it.
+func (p *BitReader) Limit(n uint) (*BitReader, error) {
+	if err := p.need(n); err != nil {
+		return nil, err
+	}
+	return &BitReader{
+		bytes:     p.bytes,
+		length:    p.length,
+		pos:       p.pos,
+		start:     p.pos,
+		end:       p.pos + n,
+		cache:     p.cache,
+		cacheBits: p.cacheBits,
+	}, nil
 }
@@ -2,6 +2,7 @@ package bitreader
 
 import (
 	"encoding/hex"
+	"math/rand"
 	"reflect"
 	"testing"
 )
@@ -170,6 +171,23 @@ func TestReadBitsToByteArray(t *testing.T) {
 	if reflect.DeepEqual([]byte{0x55, 0x55}, buf) == false {
 		t.Errorf("buf %#v must equal {0x55, 0x55}", buf)
 	}
+
+	// Whole bytes from a byte boundary are the input's, appending to them
+	// doesn't write over it
+	reader.Reset()
+	if allocs := testing.AllocsPerRun(10, func() {
+		reader.Reset()
+		buf, _ = reader.ReadBitsToByteArray(8)
+	}); allocs != 0 {
+		t.Errorf("ReadBitsToByteArray(8) made %.0f allocations, must make none", allocs)
+	}
+	if &buf[0] != &byteArray[0] || cap(buf) != 1 {
+		t.Errorf("buf must be the first byte of the input")
+	}
+	reader.ReadBitsToByteArray(8)
+	if reader.BitsLeft() != 0 {
+		t.Errorf("BitsLeft() must return 0")
+	}
 }
 
 func TestReadBytes(t *testing.T) {
@@ -216,10 +234,10 @@ func TestSkipBitsBytes(t *testing.T) {
 		t.Errorf("r1 (%d) must equal 1", r1)
 	}
 
-	// Test 2: Skip 1 byte, verify posInCurrentByte = 7
+	// Test 2: Skip 1 byte, verify the reader is at the start of the next
 	reader.SkipBytes(1)
-	if reader.posInCurrentByte != uint(7) {
-		t.Errorf("Position in byte (%d) must equal 7", reader.posInCurrentByte)
+	if reader.pos != uint(8) {
+		t.Errorf("Position (%d) must equal 8", reader.pos)
 	}
 
 	// Test 3: Read 8 bytes (should be 0x55)
@@ -353,22 +371,37 @@ func TestByteAlignment(t *testing.T) {
 
 	// Test 1: ByteAlign on bit7 should have no change
 	reader.ByteAlign()
-	if reader.posInCurrentByte != uint(7) {
-		t.Errorf("Position in current byte (%d) must be 7", reader.posInCurrentByte)
+	if reader.pos&7 != 0 {
+		t.Errorf("Position in current byte (%d) must be 0", reader.pos&7)
 	}
-	if reader.currentByteIndex != uint(0) {
-		t.Errorf("Current Byte Index (%d) must be 0", reader.currentByteIndex)
+	if reader.ByteOffset() != 0 {
+		t.Errorf("Current Byte Index (%d) must be 0", reader.ByteOffset())
 	}
 
 	// Test 2: ByteAlign after skipping 1 bits should align to next byte
 	reader.Reset()
 	reader.SkipBits(1)
 	reader.ByteAlign()
-	if reader.posInCurrentByte != uint(7) {
-		t.Errorf("Position in current byte (%d) must be 7", reader.posInCurrentByte)
+	if reader.pos&7 != 0 {
+		t.Errorf("Position in current byte (%d) must be 0", reader.pos&7)
+	}
+	if reader.ByteOffset() != 1 {
+		t.Errorf("Current Byte Index (%d) must be 1", reader.ByteOffset())
+	}
+
+	// Test 3: ByteAlign stops at the end of a limit within a byte
+	reader.Reset()
+	reader.SkipBits(1)
+	limited, _ := reader.Limit(5)
+	limited.ByteAlign()
+	if limited.BitsLeft() != 0 || limited.BitPos() != 6 {
+		t.Errorf("BitsLeft (%d) must be 0 at bit 6, is at bit %d", limited.BitsLeft(), limited.BitPos())
+	}
+	if limited.Err() != nil {
+		t.Errorf("Err (%v) must be nil", limited.Err())
 	}
-	if reader.currentByteIndex != uint(1) {
-		t.Errorf("Current Byte Index (%d) must be 1", reader.currentByteIndex)
+	if _, err := limited.ReadBit(); err != ErrReaderOutOfBounds {
+		t.Errorf("ReadBit past the limit returned %v, must return ErrReaderOutOfBounds", err)
 	}
 }
 
@@ -398,3 +431,335 @@ func TestErrors(t *testing.T) {
 		t.Errorf("err cannot be nil")
 	}
 }
+
+func TestStickyError(t *testing.T) {
+
+	// 10100101 11110000
+	reader := NewBitReader([]byte{0xa5, 0xf0})
+
+	reader.SkipBits(10)
+	if reader.Err() != nil {
+		t.Errorf("Err() must be nil before reading past the end")
+	}
+
+	// Test 1: A failed read leaves the reader where it was
+	if _, err := reader.ReadBitsAsUInt16(7); err != ErrReaderOutOfBounds {
+		t.Errorf("ReadBitsAsUInt16(7) returned %v, want ErrReaderOutOfBounds", err)
+	}
+	if reader.BitsLeft() != 6 {
+		t.Errorf("BitsLeft() (%d) must return 6 after a failed read", reader.BitsLeft())
+	}
+	if reader.Err() != ErrReaderOutOfBounds || reader.ErrOffset() != 10 {
+		t.Errorf("Err() = %v at bit %d, want ErrReaderOutOfBounds at bit 10", reader.Err(), reader.ErrOffset())
+	}
+
+	// Test 2: Later reads succeed but the first failure is kept
+	v, err := reader.ReadBitsAsUInt8(6)
+	if err != nil || v != 0x30 {
+		t.Errorf("ReadBitsAsUInt8(6) = 0x%x, %v, want 0x30", v, err)
+	}
+	reader.ReadBit()
+	if reader.Err() != ErrReaderOutOfBounds || reader.ErrOffset() != 10 {
+		t.Errorf("Err() = %v at bit %d, want ErrReaderOutOfBounds at bit 10", reader.Err(), reader.ErrOffset())
+	}
+
+	// Test 3: Reset clears the error
+	reader.Reset()
+	if reader.Err() != nil || reader.BitsLeft() != 16 {
+		t.Errorf("Reset() must clear the error and rewind")
+	}
+}
+
+func TestEmptyInput(t *testing.T) {
+	reader := NewBitReader(nil)
+	if reader.BitsLeft() != 0 || reader.HasBitLeft() {
+		t.Errorf("BitsLeft() (%d) must return 0 for empty input", reader.BitsLeft())
+	}
+	if _, err := reader.ReadBits(1); err != ErrReaderOutOfBounds {
+		t.Errorf("ReadBits(1) returned %v, want ErrReaderOutOfBounds", err)
+	}
+	reader.Reset()
+}
+
+func TestSeekBit(t *testing.T) {
+
+	// 10100101 11110000 00001111
+	reader := NewBitReader([]byte{0xa5, 0xf0, 0x0f})
+
+	// Test 1: Seek forward within the cache and back before it
+	reader.SkipBits(3)
+	if reader.BitPos() != 3 {
+		t.Errorf("BitPos() (%d) must return 3", reader.BitPos())
+	}
+	reader.SeekBit(9)
+	if v, _ := reader.ReadBits(7); v != 0x70 || reader.BitPos() != 16 {
+		t.Errorf("ReadBits(7) at bit 9 = 0x%x, want 0x70", v)
+	}
+	reader.SeekBit(1)
+	if v, _ := reader.ReadBitsAsUInt16(12); v != 0x4be {
+		t.Errorf("ReadBitsAsUInt16(12) at bit 1 = 0x%x, want 0x4be", v)
+	}
+
+	// Test 2: Seeking past the end fails and the reader stays
+	if err := reader.SeekBit(25); err != ErrReaderOutOfBounds || reader.BitPos() != 13 {
+		t.Errorf("SeekBit(25) returned %v at bit %d, want ErrReaderOutOfBounds at bit 13", err, reader.BitPos())
+	}
+	if err := reader.SeekBit(24); err != nil || reader.HasBitLeft() {
+		t.Errorf("SeekBit(24) returned %v, want the end of the input", err)
+	}
+}
+
+func TestMarkRestore(t *testing.T) {
+	reader := NewBitReader([]byte{0xa5, 0xf0})
+	reader.SkipBits(5)
+
+	// Reads after a mark, including one past the end, are rolled back
+	mark := reader.Mark()
+	reader.ReadBitsAsUInt(9)
+	reader.ReadBits(4)
+	reader.Restore(mark)
+	if reader.BitPos() != 5 || reader.Err() != nil {
+		t.Errorf("Restore() must return to bit 5 without an error, at %d with %v", reader.BitPos(), reader.Err())
+	}
+	if v, _ := reader.ReadBitsAsUInt(11); v != 0x5f0 {
+		t.Errorf("ReadBitsAsUInt(11) = 0x%x, want 0x5f0", v)
+	}
+}
+
+func TestLimit(t *testing.T) {
+
+	// 10100101 11110000 00001111
+	reader := NewBitReader([]byte{0xa5, 0xf0, 0x0f})
+	reader.SkipBits(4)
+
+	// Test 1: The limited reader ends within the input
+	limited, err := reader.Limit(10)
+	if err != nil || limited.BitsLeft() != 10 || limited.BytesLeft() != 1 {
+		t.Fatalf("Limit(10) returned %v, %d bits left", err, limited.BitsLeft())
+	}
+	if v, _ := limited.PeekBits(16); v != 0x5f00 {
+		t.Errorf("PeekBits(16) = 0x%x, want 0x5f00 with the bits past the limit zero", v)
+	}
+	if v, _ := limited.PeekBits(60); v != 0x5f0<<48 {
+		t.Errorf("PeekBits(60) = 0x%x, want 0x%x", v, uint(0x5f0)<<48)
+	}
+	limited.SkipBits(6)
+	if _, err := limited.ReadBitsAsUInt8(5); err != ErrReaderOutOfBounds || limited.ErrOffset() != 10 {
+		t.Errorf("ReadBitsAsUInt8(5) returned %v at bit %d, want ErrReaderOutOfBounds at bit 10", err, limited.ErrOffset())
+	}
+	if err := limited.SeekBit(2); err != ErrReaderOutOfBounds {
+		t.Errorf("SeekBit(2) returned %v, want ErrReaderOutOfBounds before the limit", err)
+	}
+
+	// Test 2: The parent doesn't move or fail until seeked past the limit
+	if reader.BitPos() != 4 || reader.Err() != nil {
+		t.Errorf("The parent must stay at bit 4 without an error")
+	}
+	reader.SeekBit(limited.BitPos())
+	if v, _ := reader.ReadBitsAsUInt16(14); v != 0x300f {
+		t.Errorf("ReadBitsAsUInt16(14) = 0x%x, want 0x300f", v)
+	}
+	if _, err := reader.Limit(1); err != ErrReaderOutOfBounds {
+		t.Errorf("Limit(1) returned %v at the end, want ErrReaderOutOfBounds", err)
+	}
+
+	// Test 3: Reset returns to the start of the limit
+	limited.Reset()
+	if limited.BitPos() != 4 || limited.Err() != nil {
+		t.Errorf("Reset() must return to bit 4, at %d", limited.BitPos())
+	}
+}
+
+// The bit at index i of buf, zero past its end
+func bitAt(buf []byte, i uint) uint64 {
+	if i/8 >= uint(len(buf)) {
+		return 0
+	}
+	return uint64(buf[i/8]>>(7-i%8)) & 1
+}
+
+// The n bits of buf at index i a bit at a time, keeping the last 64
+func bitsAt(buf []byte, i uint, n uint) uint64 {
+	var r uint64
+	for j := uint(0); j < n; j++ {
+		r = r<<1 | bitAt(buf, i+j)
+	}
+	return r
+}
+
+func TestCacheRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for run := 0; run < 200; run++ {
+		buf := make([]byte, rnd.Intn(64))
+		rnd.Read(buf)
+		reader := NewBitReader(buf)
+
+		pos := uint(0)
+		for op := 0; op < 100; op++ {
+			n := uint(rnd.Intn(80))
+			left := uint(len(buf))*8 - pos
+			switch rnd.Intn(6) {
+			case 0:
+				v, err := reader.ReadBitsAsUInt(n)
+				if n > left {
+					if err != ErrReaderOutOfBounds {
+						t.Fatalf("ReadBitsAsUInt(%d) at bit %d returned %v, want ErrReaderOutOfBounds", n, pos, err)
+					}
+				} else if err != nil || uint64(v) != bitsAt(buf, pos, n) {
+					t.Fatalf("ReadBitsAsUInt(%d) at bit %d = 0x%x, %v, want 0x%x", n, pos, v, err, bitsAt(buf, pos, n))
+				} else {
+					pos += n
+				}
+			case 1:
+				if v, _ := reader.PeekBits(n); uint64(v) != bitsAt(buf, pos, n) {
+					t.Fatalf("PeekBits(%d) at bit %d = 0x%x, want 0x%x", n, pos, v, bitsAt(buf, pos, n))
+				}
+			case 2:
+				n *= 3
+				if err := reader.SkipBits(n); n <= left {
+					pos += n
+				} else if err != ErrReaderOutOfBounds {
+					t.Fatalf("SkipBits(%d) at bit %d returned %v, want ErrReaderOutOfBounds", n, pos, err)
+				}
+			case 3:
+				reader.ByteAlign()
+				pos = (pos + 7) &^ 7
+			case 4:
+				n *= 2
+				arr, err := reader.AppendBitsToByteArray([]byte{0xee}, n)
+				if n > left {
+					if err != ErrReaderOutOfBounds || len(arr) != 1 {
+						t.Fatalf("AppendBitsToByteArray(%d) at bit %d returned %v, want ErrReaderOutOfBounds", n, pos, err)
+					}
+					break
+				}
+				want := []byte{0xee}
+				for i, j := uint(0), n%8; i < (n+7)/8; i++ {
+					if i > 0 || j == 0 {
+						j = 8
+					}
+					want = append(want, byte(bitsAt(buf, pos, j)))
+					pos += j
+				}
+				if err != nil || !reflect.DeepEqual(arr, want) {
+					t.Fatalf("AppendBitsToByteArray(%d) = %x, %v, want %x", n, arr, err, want)
+				}
+			case 5:
+				pos = uint(rnd.Intn(len(buf)*8 + 1))
+				if err := reader.SeekBit(uint64(pos)); err != nil {
+					t.Fatalf("SeekBit(%d) returned %v", pos, err)
+				}
+			}
+			if reader.BitsLeft() != uint(len(buf))*8-pos {
+				t.Fatalf("BitsLeft() (%d) must return %d", reader.BitsLeft(), uint(len(buf))*8-pos)
+			}
+		}
+	}
+}
+
+func TestAppendBitsToByteArrayAllocs(t *testing.T) {
+	buf := make([]byte, 512)
+	dst := make([]byte, 0, len(buf))
+	reader := NewBitReader(buf)
+	allocs := testing.AllocsPerRun(100, func() {
+		reader.Reset()
+		reader.SkipBits(3)
+		dst, _ = reader.AppendBitsToByteArray(dst[:0], 8*400+5)
+	})
+	if allocs != 0 {
+		t.Errorf("AppendBitsToByteArray() made %v allocations, want 0", allocs)
+	}
+}
+
+// Frame sizes of 44.1 kHz AAC at 96 and 320 kbps, 1024 samples a frame
+const (
+	frameBytes96k  = 96000 / 8 * 1024 / 44100
+	frameBytes320k = 320000 / 8 * 1024 / 44100
+)
+
+// Reads a frame the way Huffman decoding of spectral data does, peeking
+// at a codeword, skipping its length and reading sign and escape bits
+func benchmarkSpectralData(b *testing.B, size int) {
+	buf := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(buf)
+	reader := NewBitReader(buf)
+
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader.Reset()
+		for reader.BitsLeft() > 32 {
+			codeword, _ := reader.PeekBits(16)
+			reader.SkipBits(1 + codeword&15)
+			reader.ReadBitsAsUInt(codeword >> 14)
+			reader.ReadBit()
+		}
+	}
+}
+
+func BenchmarkSpectralData96k(b *testing.B) {
+	benchmarkSpectralData(b, frameBytes96k)
+}
+
+func BenchmarkSpectralData320k(b *testing.B) {
+	benchmarkSpectralData(b, frameBytes320k)
+}
+
+// Reads a frame as the unaligned fill bytes of an extension payload
+func benchmarkFillBytes(b *testing.B, size int) {
+	buf := make([]byte, size)
+	dst := make([]byte, 0, size)
+	reader := NewBitReader(buf)
+
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader.Reset()
+		reader.SkipBits(4)
+		dst, _ = reader.AppendBitsToByteArray(dst[:0], reader.BitsLeft())
+	}
+}
+
+// Reads a frame as the data of a data_stream_element(), whole bytes from
+// a byte boundary when aligned or 4 bits past it when not
+func benchmarkReadBitsToByteArray(b *testing.B, size int, aligned bool) {
+	buf := make([]byte, size)
+	reader := NewBitReader(buf)
+
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader.Reset()
+		if !aligned {
+			reader.SkipBits(4)
+		}
+		reader.ReadBitsToByteArray(reader.BitsLeft() &^ 7)
+	}
+}
+
+func BenchmarkReadBitsToByteArray96k(b *testing.B) {
+	benchmarkReadBitsToByteArray(b, frameBytes96k, true)
+}
+
+func BenchmarkReadBitsToByteArray320k(b *testing.B) {
+	benchmarkReadBitsToByteArray(b, frameBytes320k, true)
+}
+
+func BenchmarkReadBitsToByteArrayUnaligned96k(b *testing.B) {
+	benchmarkReadBitsToByteArray(b, frameBytes96k, false)
+}
+
+func BenchmarkReadBitsToByteArrayUnaligned320k(b *testing.B) {
+	benchmarkReadBitsToByteArray(b, frameBytes320k, false)
+}
+
+func BenchmarkFillBytes96k(b *testing.B) {
+	benchmarkFillBytes(b, frameBytes96k)
+}
+
+func BenchmarkFillBytes320k(b *testing.B) {
+	benchmarkFillBytes(b, frameBytes320k)
+}
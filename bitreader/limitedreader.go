@@ -0,0 +1,223 @@
+package bitreader
+
+// LimitedReader reads the next n bits of a Reader, reads past them fail
+// with ErrReaderOutOfBounds even if the input goes on.  Unlike a reader
+// returned by BitReader.Limit it reads through the Reader it limits, which
+// is left where the reads stopped.  Nothing past the limit is read or
+// peeked, so a StreamReader doesn't wait for data beyond it.
+type LimitedReader struct {
+	r   Reader
+	end uint64 // BitPos of r the limit ends at
+
+	// The first read past the limit, or failure of r, and the bit it
+	// started at
+	err       error
+	errOffset uint64
+}
+
+// Return a reader of the next n bits of r
+func NewLimitedReader(r Reader, n uint) *LimitedReader {
+	return &LimitedReader{r: r, end: r.BitPos() + uint64(n)}
+}
+
+// Records the first read past the limit, the reader doesn't move on a
+// failed read
+func (p *LimitedReader) fail() error {
+	if p.err == nil {
+		p.err = ErrReaderOutOfBounds
+		p.errOffset = p.r.BitPos()
+	}
+	return ErrReaderOutOfBounds
+}
+
+// Records the first failure of r, the input ending before the limit
+func (p *LimitedReader) check(err error) error {
+	if err != nil && p.err == nil {
+		p.err = err
+		p.errOffset = p.r.ErrOffset()
+	}
+	return err
+}
+
+// Return an error unless n bits are left
+func (p *LimitedReader) need(n uint) error {
+	if p.BitsLeft() < n {
+		return p.fail()
+	}
+	return nil
+}
+
+// Return ErrReaderOutOfBounds if any read or skip has gone past the limit,
+// or the error of the Reader if it failed first
+func (p *LimitedReader) Err() error {
+	return p.err
+}
+
+// Return the bit offset of the first read past the limit
+func (p *LimitedReader) ErrOffset() uint64 {
+	return p.errOffset
+}
+
+// Return the number of bits left before the limit
+func (p *LimitedReader) BitsLeft() uint {
+	return uint(p.end - p.r.BitPos())
+}
+
+// Return the number of bytes left before the limit (even if partially read)
+func (p *LimitedReader) BytesLeft() uint {
+	return (p.BitsLeft() + uint(p.r.BitPos()&7)) / 8
+}
+
+// Return if there's a bit left before the limit
+func (p *LimitedReader) HasBitLeft() bool {
+	return p.BitsLeft() > 0 && p.r.HasBitLeft()
+}
+
+func (p *LimitedReader) ReadBit() (byte, error) {
+	if err := p.need(1); err != nil {
+		return 0, err
+	}
+	val, err := p.r.ReadBit()
+	return val, p.check(err)
+}
+
+func (p *LimitedReader) ReadBitAsBool() (bool, error) {
+	val, err := p.ReadBit()
+	return val == 1, err
+}
+
+// Return n number of bits, n can be up to 8
+func (p *LimitedReader) ReadBits(n uint) (byte, error) {
+	if err := p.need(n); err != nil {
+		return 0, err
+	}
+	val, err := p.r.ReadBits(n)
+	return val, p.check(err)
+}
+
+// Return the number of bits as an unsigned integer
+func (p *LimitedReader) ReadBitsAsUInt(n uint) (uint, error) {
+	if err := p.need(n); err != nil {
+		return 0, err
+	}
+	val, err := p.r.ReadBitsAsUInt(n)
+	return val, p.check(err)
+}
+
+func (p *LimitedReader) ReadBitsAsUInt8(n uint) (uint8, error) {
+	val, err := p.ReadBitsAsUInt(n)
+	return uint8(val), err
+}
+
+func (p *LimitedReader) ReadBitsAsUInt16(n uint) (uint16, error) {
+	val, err := p.ReadBitsAsUInt(n)
+	return uint16(val), err
+}
+
+func (p *LimitedReader) ReadBitsAsUInt32(n uint) (uint32, error) {
+	val, err := p.ReadBitsAsUInt(n)
+	return uint32(val), err
+}
+
+func (p *LimitedReader) ReadBitsAsInt(n uint) (int, error) {
+	val, err := p.ReadBitsAsUInt(n)
+	return int(val), err
+}
+
+// Return n number of bits into a byte array, right aligned like BitReader
+func (p *LimitedReader) ReadBitsToByteArray(n uint) ([]byte, error) {
+	if err := p.need(n); err != nil {
+		return nil, err
+	}
+	val, err := p.r.ReadBitsToByteArray(n)
+	return val, p.check(err)
+}
+
+// Append n number of bits to dst the way ReadBitsToByteArray returns them
+func (p *LimitedReader) AppendBitsToByteArray(dst []byte, n uint) ([]byte, error) {
+	if err := p.need(n); err != nil {
+		return dst, err
+	}
+	val, err := p.r.AppendBitsToByteArray(dst, n)
+	return val, p.check(err)
+}
+
+// Return n number of bytes starting at the current byte, the bits of it
+// already read are read again
+func (p *LimitedReader) ReadBytes(n uint) ([]byte, error) {
+	if p.BytesLeft() < n {
+		return nil, p.fail()
+	}
+	val, err := p.r.ReadBytes(n)
+	return val, p.check(err)
+}
+
+// Read Unsigned Exp-Golomb
+func (p *LimitedReader) ReadUE() (uint32, error) {
+	return readUE(p)
+}
+
+// Read Signed Exp-Golomb
+func (p *LimitedReader) ReadSE() (int32, error) {
+	return readSE(p)
+}
+
+// Return the next bit, do not adv. the cursor
+func (p *LimitedReader) PeekBit() (byte, error) {
+	if p.BitsLeft() == 0 {
+		return 0, ErrReaderOutOfBounds
+	}
+	return p.r.PeekBit()
+}
+
+// Return n number of bits, do not adv. the cursor.  Bits past the limit
+// are zero.
+func (p *LimitedReader) PeekBits(n uint) (uint, error) {
+	m := n
+	if left := p.BitsLeft(); m > left {
+		m = left
+	}
+	if m == 0 {
+		return 0, nil
+	}
+	val, err := p.r.PeekBits(m)
+	return val << (n - m), err
+}
+
+// Skip n number of bits
+func (p *LimitedReader) SkipBits(n uint) error {
+	if err := p.need(n); err != nil {
+		return err
+	}
+	return p.check(p.r.SkipBits(n))
+}
+
+// Skip n number of bytes starting at the current byte, the bits of it
+// already read count towards them
+func (p *LimitedReader) SkipBytes(n uint) error {
+	if p.BytesLeft() < n {
+		return p.fail()
+	}
+	return p.check(p.r.SkipBytes(n))
+}
+
+// Pefrom byte alignment (skip any remaining bits of current byte), stopping
+// at the limit if it ends within the byte
+func (p *LimitedReader) ByteAlign() {
+	if r := uint(p.r.BitPos() & 7); r != 0 {
+		n := 8 - r
+		if left := p.BitsLeft(); n > left {
+			n = left
+		}
+		p.check(p.r.SkipBits(n))
+	}
+}
+
+func (p *LimitedReader) ByteOffset() uint64 {
+	return p.r.ByteOffset()
+}
+
+// Return the bit position of the Reader
+func (p *LimitedReader) BitPos() uint64 {
+	return p.r.BitPos()
+}
@@ -0,0 +1,65 @@
+package bitreader
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestLimitedReader(t *testing.T) {
+	// 10100101 11110000 00001111
+	input := []byte{0xa5, 0xf0, 0x0f}
+
+	// The stream fails when read past the bytes of the limit
+	errStream := errors.New("read past the limit")
+	readers := []Reader{
+		NewBitReader(input),
+		NewStreamReader(iotest.OneByteReader(io.MultiReader(bytes.NewReader(input[:2]), iotest.ErrReader(errStream)))),
+	}
+	for i, reader := range readers {
+		reader.SkipBits(4)
+
+		// Test 1: The limit ends within the input, peeks past it are zero
+		limited := NewLimitedReader(reader, 10)
+		if limited.BitsLeft() != 10 || limited.BytesLeft() != 1 {
+			t.Fatalf("reader %d: %d bits, %d bytes left, want 10 and 1", i, limited.BitsLeft(), limited.BytesLeft())
+		}
+		if v, _ := limited.PeekBits(16); v != 0x5f00 {
+			t.Errorf("reader %d: PeekBits(16) = 0x%x, want 0x5f00 with the bits past the limit zero", i, v)
+		}
+		if v, _ := limited.PeekBits(60); v != 0x5f0<<48 {
+			t.Errorf("reader %d: PeekBits(60) = 0x%x, want 0x%x", i, v, uint(0x5f0)<<48)
+		}
+		if v, _ := limited.ReadBitsAsUInt8(6); v != 0x17 {
+			t.Errorf("reader %d: ReadBitsAsUInt8(6) = 0x%x, want 0x17", i, v)
+		}
+		if _, err := limited.ReadBitsAsUInt8(5); err != ErrReaderOutOfBounds || limited.ErrOffset() != 10 {
+			t.Errorf("reader %d: ReadBitsAsUInt8(5) returned %v at bit %d, want ErrReaderOutOfBounds at bit 10", i, err, limited.ErrOffset())
+		}
+		if _, err := limited.ReadBytes(1); err != ErrReaderOutOfBounds {
+			t.Errorf("reader %d: ReadBytes(1) returned %v, want ErrReaderOutOfBounds across the limit", i, err)
+		}
+
+		// Test 2: ByteAlign stops at the limit within the byte
+		limited.ByteAlign()
+		if limited.BitsLeft() != 0 || limited.HasBitLeft() {
+			t.Errorf("reader %d: ByteAlign() must stop at the limit, %d bits left", i, limited.BitsLeft())
+		}
+		if _, err := limited.PeekBit(); err != ErrReaderOutOfBounds {
+			t.Errorf("reader %d: PeekBit() returned %v at the limit, want ErrReaderOutOfBounds", i, err)
+		}
+
+		// Test 3: The reader it limits moved on without an error
+		if reader.BitPos() != 14 || reader.Err() != nil {
+			t.Errorf("reader %d: the Reader must be at bit 14 without an error, at %d with %v", i, reader.BitPos(), reader.Err())
+		}
+	}
+
+	// Test 4: The input ending before the limit fails with its error
+	limited := NewLimitedReader(NewStreamReader(iotest.ErrReader(errStream)), 8)
+	if _, err := limited.ReadBit(); err != errStream || limited.Err() != errStream {
+		t.Errorf("ReadBit() returned %v, Err() %v, want the error of the stream", err, limited.Err())
+	}
+}
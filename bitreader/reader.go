@@ -0,0 +1,86 @@
+package bitreader
+
+// Reader is implemented by BitReader over a byte slice, StreamReader over
+// an io.Reader and LimitedReader over the next bits of another Reader.
+// Reads past the end fail with ErrReaderOutOfBounds without moving the
+// reader, Err returns the first such failure.
+type Reader interface {
+	ReadBit() (byte, error)
+	ReadBitAsBool() (bool, error)
+	ReadBits(n uint) (byte, error)
+	ReadBitsAsUInt(n uint) (uint, error)
+	ReadBitsAsUInt8(n uint) (uint8, error)
+	ReadBitsAsUInt16(n uint) (uint16, error)
+	ReadBitsAsUInt32(n uint) (uint32, error)
+	ReadBitsAsInt(n uint) (int, error)
+	ReadBitsToByteArray(n uint) ([]byte, error)
+	AppendBitsToByteArray(dst []byte, n uint) ([]byte, error)
+	ReadBytes(n uint) ([]byte, error)
+	ReadUE() (uint32, error)
+	ReadSE() (int32, error)
+
+	PeekBit() (byte, error)
+	PeekBits(n uint) (uint, error)
+	SkipBits(n uint) error
+	SkipBytes(n uint) error
+	ByteAlign()
+	ByteOffset() uint64
+	BitPos() uint64
+	HasBitLeft() bool
+
+	Err() error
+	ErrOffset() uint64
+}
+
+var (
+	_ Reader = (*BitReader)(nil)
+	_ Reader = (*StreamReader)(nil)
+	_ Reader = (*LimitedReader)(nil)
+)
+
+// Read Unsigned Exp-Golomb
+func readUE(p Reader) (uint32, error) {
+	zeros := 0
+	val := uint32(0)
+
+	// Count leading zeros
+	for {
+		bit, err := p.ReadBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit == 0 {
+			zeros++
+		} else {
+			break
+		}
+	}
+
+	if zeros == 0 {
+		return 0, nil
+	} else {
+		val = 1
+	}
+
+	// Shift bits
+	for ; zeros > 0; zeros-- {
+		bit, err := p.ReadBit()
+		if err != nil {
+			return 0, err
+		}
+		val <<= 1
+		val |= uint32(bit)
+	}
+	return val - 1, nil // Subtract onebecause we stole a bit for 0.
+}
+
+// Read Signed Exp-Golomb
+func readSE(p Reader) (int32, error) {
+	u, err := p.ReadUE()
+	if err != nil {
+		return 0, err
+	}
+	s := int32(u)
+	sign := ((s & 0x1) << 1) - 1
+	return ((s >> 1) + (s & 0x1)) * sign, nil
+}
@@ -0,0 +1,304 @@
+package bitreader
+
+import (
+	"io"
+)
+
+// Size of the buffer of a StreamReader, it grows for longer reads
+const streamBufferSize = 4096
+
+// StreamReader reads bits from an io.Reader, e.g. a pipe or a network
+// connection, buffering only what hasn't been read yet
+type StreamReader struct {
+	r      io.Reader
+	ioErr  error // the error that ended r, io.EOF at its end
+	buf    []byte
+	start  int    // buf[start] is the current byte
+	bit    uint   // bits of the current byte already read
+	offset uint64 // bytes dropped from the front of buf
+
+	// The first read past the end of the stream and the bit it started at
+	err       error
+	errOffset uint64
+}
+
+// Return a reader of the bits of r
+func NewStreamReader(r io.Reader) *StreamReader {
+	return &StreamReader{
+		r:   r,
+		buf: make([]byte, 0, streamBufferSize),
+	}
+}
+
+// Number of bits buffered and not read yet
+func (p *StreamReader) buffered() uint {
+	return uint(len(p.buf)-p.start)*8 - p.bit
+}
+
+// Read from the stream until n bits are buffered, false if it ends first
+func (p *StreamReader) fill(n uint) bool {
+	for p.buffered() < n && p.ioErr == nil {
+		// Drop the bytes read, growing the buffer if they don't make room
+		p.offset += uint64(p.start)
+		p.buf = p.buf[:copy(p.buf, p.buf[p.start:])]
+		p.start = 0
+		if need := int((n+p.bit+7)/8) - len(p.buf); cap(p.buf)-len(p.buf) < need {
+			buf := make([]byte, len(p.buf), len(p.buf)+need+streamBufferSize)
+			copy(buf, p.buf)
+			p.buf = buf
+		}
+
+		read, err := p.r.Read(p.buf[len(p.buf):cap(p.buf)])
+		p.buf = p.buf[:len(p.buf)+read]
+		if err != nil {
+			p.ioErr = err
+		}
+	}
+	return p.buffered() >= n
+}
+
+// Records the first read past the end of the stream, the reader doesn't
+// move on a failed read.  An error of the stream other than io.EOF is
+// returned as it is.
+func (p *StreamReader) fail() error {
+	err := ErrReaderOutOfBounds
+	if p.ioErr != nil && p.ioErr != io.EOF {
+		err = p.ioErr
+	}
+	if p.err == nil {
+		p.err = err
+		p.errOffset = p.BitPos()
+	}
+	return err
+}
+
+// Return an error unless n bits can be read
+func (p *StreamReader) need(n uint) error {
+	if !p.fill(n) {
+		return p.fail()
+	}
+	return nil
+}
+
+// The next n <= 64 buffered bits
+func (p *StreamReader) peek(n uint) uint64 {
+	var val uint64
+	i, bit := p.start, p.bit
+	for n > 0 {
+		take := 8 - bit
+		if take > n {
+			take = n
+		}
+		val = val<<take | uint64(p.buf[i]>>(8-bit-take))&(1<<take-1)
+		n -= take
+		bit = 0
+		i++
+	}
+	return val
+}
+
+func (p *StreamReader) advance(n uint) {
+	p.bit += n
+	p.start += int(p.bit / 8)
+	p.bit %= 8
+}
+
+// Return ErrReaderOutOfBounds, or the error of the stream, if any read or
+// skip has gone past its end
+func (p *StreamReader) Err() error {
+	return p.err
+}
+
+// Return the bit offset of the first read past the end of the stream
+func (p *StreamReader) ErrOffset() uint64 {
+	return p.errOffset
+}
+
+// Return if there's a bit left in the stream, waiting for it if needed
+func (p *StreamReader) HasBitLeft() bool {
+	return p.fill(1)
+}
+
+// Return the next bit from the stream
+func (p *StreamReader) ReadBit() (byte, error) {
+	if err := p.need(1); err != nil {
+		return 0, err
+	}
+	r := byte(p.peek(1))
+	p.advance(1)
+	return r, nil
+}
+
+func (p *StreamReader) ReadBitAsBool() (bool, error) {
+	val, err := p.ReadBit()
+	return val == 1, err
+}
+
+// Return n number of bits, n can be up to 8
+func (p *StreamReader) ReadBits(n uint) (byte, error) {
+	val, err := p.ReadBitsAsUInt(n)
+	return byte(val), err
+}
+
+// Return the number of bits as an unsigned integer
+func (p *StreamReader) ReadBitsAsUInt(n uint) (uint, error) {
+	if err := p.need(n); err != nil {
+		return 0, err
+	}
+	var result uint64
+	for i := n; i > 0; {
+		take := i
+		if take > 56 {
+			take = 56
+		}
+		result = result<<take | p.peek(take)
+		p.advance(take)
+		i -= take
+	}
+	return uint(result), nil
+}
+
+// Return the number of bits as an unsigned integer
+func (p *StreamReader) ReadBitsAsUInt8(n uint) (uint8, error) {
+	val, err := p.ReadBitsAsUInt(n)
+	return uint8(val), err
+}
+
+// Return the number of bits as an unsigned integer
+func (p *StreamReader) ReadBitsAsUInt16(n uint) (uint16, error) {
+	val, err := p.ReadBitsAsUInt(n)
+	return uint16(val), err
+}
+
+// Return the number of bits as an unsigned integer
+func (p *StreamReader) ReadBitsAsUInt32(n uint) (uint32, error) {
+	val, err := p.ReadBitsAsUInt(n)
+	return uint32(val), err
+}
+
+// Return the number of bits as a signed integer
+func (p *StreamReader) ReadBitsAsInt(n uint) (int, error) {
+	val, err := p.ReadBitsAsUInt(n)
+	return int(val), err
+}
+
+// Return n number of bits into a byte array, right aligned like BitReader
+func (p *StreamReader) ReadBitsToByteArray(n uint) ([]byte, error) {
+	if err := p.need(n); err != nil {
+		return nil, err
+	}
+	return p.AppendBitsToByteArray(make([]byte, 0, (n+7)/8), n)
+}
+
+// Append n number of bits to dst the way ReadBitsToByteArray returns them
+func (p *StreamReader) AppendBitsToByteArray(dst []byte, n uint) ([]byte, error) {
+	if err := p.need(n); err != nil {
+		return dst, err
+	}
+	for i := uint(0); i < (n+7)/8; i++ {
+		take := uint(8)
+		if i == 0 && n%8 != 0 {
+			take = n % 8
+		}
+		dst = append(dst, byte(p.peek(take)))
+		p.advance(take)
+	}
+	return dst, nil
+}
+
+// Return n number of bytes starting at the current byte, like BitReader
+// the bits of it already read are read again
+func (p *StreamReader) ReadBytes(n uint) ([]byte, error) {
+	bit := p.bit
+	p.bit = 0
+	if !p.fill(n * 8) {
+		p.bit = bit
+		return nil, p.fail()
+	}
+	arr := make([]byte, n)
+	copy(arr, p.buf[p.start:])
+	p.start += int(n)
+	return arr, nil
+}
+
+// Read Unsigned Exp-Golomb
+func (p *StreamReader) ReadUE() (uint32, error) {
+	return readUE(p)
+}
+
+// Read Signed Exp-Golomb
+func (p *StreamReader) ReadSE() (int32, error) {
+	return readSE(p)
+}
+
+// Return up to n bytes starting at the current byte, do not adv. the
+// cursor.  Fewer are returned with ErrReaderOutOfBounds if the stream ends
+// first.  The bytes are the reader's buffer, only valid until the next read.
+func (p *StreamReader) PeekBytes(n uint) ([]byte, error) {
+	bit := p.bit
+	p.bit = 0
+	p.fill(n * 8)
+	p.bit = bit
+	if avail := uint(len(p.buf) - p.start); avail < n {
+		return p.buf[p.start:], ErrReaderOutOfBounds
+	}
+	return p.buf[p.start : p.start+int(n)], nil
+}
+
+// Return the next bit from the stream, do not adv. the cursor
+func (p *StreamReader) PeekBit() (byte, error) {
+	if !p.fill(1) {
+		return 0, ErrReaderOutOfBounds
+	}
+	return byte(p.peek(1)), nil
+}
+
+// Return n number of bits from the stream, do not adv. the cursor.  Bits
+// past the end of the stream are zero.
+func (p *StreamReader) PeekBits(n uint) (uint, error) {
+	p.fill(n)
+	avail := p.buffered()
+	if avail >= n {
+		return uint(p.peek(n)), nil
+	}
+	return uint(p.peek(avail) << (n - avail)), nil
+}
+
+// Skip n number of bits in the stream
+func (p *StreamReader) SkipBits(n uint) error {
+	if err := p.need(n); err != nil {
+		return err
+	}
+	p.advance(n)
+	return nil
+}
+
+// Skip n number of bytes starting at the current byte, like BitReader the
+// bits of it already read count towards them
+func (p *StreamReader) SkipBytes(n uint) error {
+	bit := p.bit
+	p.bit = 0
+	if !p.fill(n * 8) {
+		p.bit = bit
+		return p.fail()
+	}
+	p.start += int(n)
+	return nil
+}
+
+// Pefrom byte alignment (skip any remaining bits of current byte)
+func (p *StreamReader) ByteAlign() {
+	if p.bit != 0 {
+		p.advance(8 - p.bit)
+	}
+}
+
+// Return the offset of the current byte from the start of the stream
+func (p *StreamReader) ByteOffset() uint64 {
+	return p.offset + uint64(p.start)
+}
+
+// Return the number of bits read since the start of the stream
+func (p *StreamReader) BitPos() uint64 {
+	return (p.offset+uint64(p.start))*8 + uint64(p.bit)
+}
@@ -0,0 +1,122 @@
+package bitreader
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+func TestStreamReaderParity(t *testing.T) {
+	input := []byte{0xa6, 0x42, 0x98, 0xe2, 0x04, 0x8a, 0x16, 0xff, 0x00, 0x5a, 0xc3, 0x3c}
+
+	// A stream that returns a byte at a time must read like the slice
+	readers := []Reader{
+		NewBitReader(input),
+		NewStreamReader(iotest.OneByteReader(bytes.NewReader(input))),
+	}
+	results := make([][]interface{}, len(readers))
+	for i, p := range readers {
+		var r []interface{}
+		add := func(v interface{}, err error) {
+			r = append(r, v, err)
+		}
+		add(p.ReadBit())
+		add(p.ReadBits(5))
+		add(p.PeekBits(13))
+		add(p.ReadBitsAsUInt16(13))
+		add(p.ReadUE())
+		add(p.ReadSE())
+		add(p.ReadBitsToByteArray(11))
+		p.ByteAlign()
+		add(p.ByteOffset(), nil)
+		add(p.ReadBytes(2))
+		add(nil, p.SkipBits(3))
+		add(p.ReadBitsAsUInt32(20))
+		add(p.PeekBit())
+		add(p.ReadBitsAsInt(9))
+		add(p.ReadBitsAsUInt(64))
+		add(p.HasBitLeft(), p.Err())
+		add(p.ReadBitsAsUInt8(3))
+		add(p.HasBitLeft(), p.Err())
+		add(p.ErrOffset(), nil)
+		results[i] = r
+	}
+
+	for i := 0; i < len(results[0]); i += 2 {
+		if !reflect.DeepEqual(results[0][i:i+2], results[1][i:i+2]) {
+			t.Errorf("Result %d: StreamReader returned %v, BitReader %v", i/2, results[1][i:i+2], results[0][i:i+2])
+		}
+	}
+}
+
+func TestStreamReaderLongRead(t *testing.T) {
+	input := make([]byte, 3*streamBufferSize)
+	for i := range input {
+		input[i] = byte(i)
+	}
+	reader := NewStreamReader(bytes.NewReader(input))
+
+	// Test 1: Reads longer than the buffer grow it
+	reader.SkipBits(4)
+	arr, err := reader.ReadBytes(2 * streamBufferSize)
+	if err != nil || !bytes.Equal(arr, input[:2*streamBufferSize]) {
+		t.Errorf("ReadBytes(%d) returned the wrong bytes, %v", 2*streamBufferSize, err)
+	}
+
+	// Test 2: Offsets count the bytes dropped from the buffer
+	reader.SkipBits(5)
+	if reader.ByteOffset() != 2*streamBufferSize {
+		t.Errorf("ByteOffset() (%d) must return %d", reader.ByteOffset(), 2*streamBufferSize)
+	}
+	reader.SkipBytes(streamBufferSize - 1)
+	if _, err := reader.ReadBitsAsUInt16(9); err != ErrReaderOutOfBounds {
+		t.Errorf("ReadBitsAsUInt16(9) returned %v, want ErrReaderOutOfBounds", err)
+	}
+	if reader.ErrOffset() != (3*streamBufferSize-1)*8 {
+		t.Errorf("ErrOffset() (%d) must return %d", reader.ErrOffset(), (3*streamBufferSize-1)*8)
+	}
+	if v, err := reader.ReadBits(8); err != nil || v != input[len(input)-1] {
+		t.Errorf("ReadBits(8) = 0x%x, %v, want 0x%x", v, err, input[len(input)-1])
+	}
+}
+
+func TestStreamReaderPeekBytes(t *testing.T) {
+	input := []byte{0x01, 0x02, 0x03, 0x04}
+	reader := NewStreamReader(iotest.OneByteReader(bytes.NewReader(input)))
+
+	// Test 1: Peeking waits for the bytes and doesn't move the reader
+	reader.SkipBits(4)
+	if arr, err := reader.PeekBytes(3); err != nil || !bytes.Equal(arr, input[:3]) {
+		t.Errorf("PeekBytes(3) = %v, %v, want %v", arr, err, input[:3])
+	}
+	if reader.BitPos() != 4 {
+		t.Errorf("BitPos() (%d) must return 4", reader.BitPos())
+	}
+
+	// Test 2: Past the end of the stream the bytes left are returned
+	if arr, err := reader.PeekBytes(6); err != ErrReaderOutOfBounds || !bytes.Equal(arr, input) {
+		t.Errorf("PeekBytes(6) = %v, %v, want %v and ErrReaderOutOfBounds", arr, err, input)
+	}
+	if reader.Err() != nil {
+		t.Errorf("Err() (%v) must return nil after a peek", reader.Err())
+	}
+}
+
+func TestStreamReaderError(t *testing.T) {
+	errBroken := errors.New("broken pipe")
+	reader := NewStreamReader(io.MultiReader(bytes.NewReader([]byte{0xa5}), iotest.ErrReader(errBroken)))
+
+	// Errors of the stream are returned as they are, other than io.EOF
+	if v, err := reader.ReadBits(8); err != nil || v != 0xa5 {
+		t.Errorf("ReadBits(8) = 0x%x, %v, want 0xa5", v, err)
+	}
+	if _, err := reader.ReadBit(); err != errBroken {
+		t.Errorf("ReadBit() returned %v, want %v", err, errBroken)
+	}
+	if reader.Err() != errBroken || reader.ErrOffset() != 8 {
+		t.Errorf("Err() = %v at bit %d, want %v at bit 8", reader.Err(), reader.ErrOffset(), errBroken)
+	}
+}